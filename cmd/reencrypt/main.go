@@ -0,0 +1,170 @@
+// reencrypt encrypts the oauth refresh tokens and the totp secrets stored before the encryption was added, and rewraps
+// the ones whose data key is wrapped by an old master key, run it after changing ENCRYPTION_ACTIVE_KEY_VERSION
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/izzanzahrial/skeleton/config"
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/envelope"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	batchSize := flag.Int("batch-size", 500, "number of rows read at once")
+	dryRun := flag.Bool("dry-run", false, "only count the values that would be changed")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Fatalf("failed to load environment variables: %v", err)
+	}
+
+	dbCfg, err := config.NewDatabase()
+	if err != nil {
+		log.Fatalf("failed to initialize database configuration: %v", err)
+	}
+
+	encryptionCfg, err := config.NewEncryption()
+	if err != nil {
+		log.Fatalf("failed to initialize encryption configuration: %v", err)
+	}
+
+	keys, err := envelope.NewKeyring(encryptionCfg.ActiveKeyVersion, encryptionCfg.MasterKeys)
+	if err != nil {
+		log.Fatalf("failed to create encryption keyring: %v", err)
+	}
+
+	ctx := context.Background()
+	conn, err := pgxpool.New(ctx, dbCfg.URL())
+	if err != nil {
+		log.Fatalf("failed to create database connection: %v", err)
+	}
+	defer conn.Close()
+
+	queries := db.New(conn)
+
+	reencryptRefreshTokens(ctx, queries, keys, int32(*batchSize), *dryRun)
+	reencryptTOTPSecrets(ctx, queries, keys, int32(*batchSize), *dryRun)
+}
+
+// reencrypt encrypts the plaintext value or rewraps the encrypted one, the flag tells which was done
+func reencrypt(keys *envelope.Keyring, value, field string) (string, bool, error) {
+	if envelope.IsEncrypted(value) {
+		newValue, err := keys.Rewrap(value)
+		return newValue, false, err
+	}
+
+	newValue, err := keys.Encrypt([]byte(value), []byte(field))
+	return newValue, true, err
+}
+
+func reencryptRefreshTokens(ctx context.Context, queries *db.Queries, keys *envelope.Keyring, batchSize int32, dryRun bool) {
+	var lastID int64
+	var encrypted, rewrapped, skipped int
+	for {
+		rows, err := queries.GetUserRefreshTokens(ctx, db.GetUserRefreshTokensParams{ID: lastID, Limit: batchSize})
+		if err != nil {
+			log.Fatalf("failed to get refresh tokens: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+
+			value := row.RefreshToken.String
+			if !keys.NeedsRewrap(value) {
+				continue
+			}
+
+			newValue, wasPlaintext, err := reencrypt(keys, value, model.RefreshTokenField)
+			if err != nil {
+				log.Fatalf("failed to encrypt refresh token of user %d: %v", row.ID, err)
+			}
+			if wasPlaintext {
+				encrypted++
+			} else {
+				rewrapped++
+			}
+
+			if dryRun {
+				continue
+			}
+
+			// the update is skipped when the token changed since it was read
+			updated, err := queries.UpdateUserRefreshToken(ctx, db.UpdateUserRefreshTokenParams{
+				NewRefreshToken: pgtype.Text{String: newValue, Valid: true},
+				ID:              row.ID,
+				OldRefreshToken: row.RefreshToken,
+			})
+			if err != nil {
+				log.Fatalf("failed to update refresh token of user %d: %v", row.ID, err)
+			}
+			if updated == 0 {
+				skipped++
+			}
+		}
+	}
+
+	log.Printf("encrypted %d plaintext tokens, rewrapped %d tokens, skipped %d changed tokens (dry run: %t)",
+		encrypted, rewrapped, skipped, dryRun)
+}
+
+func reencryptTOTPSecrets(ctx context.Context, queries *db.Queries, keys *envelope.Keyring, batchSize int32, dryRun bool) {
+	var lastID int64
+	var encrypted, rewrapped, skipped int
+	for {
+		rows, err := queries.GetUserTOTPSecrets(ctx, db.GetUserTOTPSecretsParams{UserID: lastID, Limit: batchSize})
+		if err != nil {
+			log.Fatalf("failed to get totp secrets: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			lastID = row.UserID
+
+			if !keys.NeedsRewrap(row.Secret) {
+				continue
+			}
+
+			newValue, wasPlaintext, err := reencrypt(keys, row.Secret, model.TOTPSecretField)
+			if err != nil {
+				log.Fatalf("failed to encrypt totp secret of user %d: %v", row.UserID, err)
+			}
+			if wasPlaintext {
+				encrypted++
+			} else {
+				rewrapped++
+			}
+
+			if dryRun {
+				continue
+			}
+
+			// the update is skipped when the secret was enrolled again since it was read
+			updated, err := queries.UpdateUserTOTPSecret(ctx, db.UpdateUserTOTPSecretParams{
+				NewSecret: newValue,
+				UserID:    row.UserID,
+				OldSecret: row.Secret,
+			})
+			if err != nil {
+				log.Fatalf("failed to update totp secret of user %d: %v", row.UserID, err)
+			}
+			if updated == 0 {
+				skipped++
+			}
+		}
+	}
+
+	log.Printf("encrypted %d plaintext totp secrets, rewrapped %d totp secrets, skipped %d changed totp secrets (dry run: %t)",
+		encrypted, rewrapped, skipped, dryRun)
+}
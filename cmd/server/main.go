@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"log/slog"
+	"net"
 	"os"
 	"strings"
 
@@ -12,17 +13,27 @@ import (
 	db "github.com/izzanzahrial/skeleton/db/sqlc"
 	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
 	"github.com/izzanzahrial/skeleton/internal/domain/post/broker"
-	"github.com/izzanzahrial/skeleton/internal/interface/http/auth0"
 	authhandler "github.com/izzanzahrial/skeleton/internal/interface/http/authentication"
+	authorizationhandler "github.com/izzanzahrial/skeleton/internal/interface/http/authorization"
 	"github.com/izzanzahrial/skeleton/internal/interface/http/handlers"
+	authmiddleware "github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/interface/http/oauth"
 	posthandler "github.com/izzanzahrial/skeleton/internal/interface/http/post"
+	rolehandler "github.com/izzanzahrial/skeleton/internal/interface/http/role"
 	"github.com/izzanzahrial/skeleton/internal/interface/http/router"
 	userhandler "github.com/izzanzahrial/skeleton/internal/interface/http/user"
 	"github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/izzanzahrial/skeleton/internal/service/authorization"
 	"github.com/izzanzahrial/skeleton/internal/service/post"
+	"github.com/izzanzahrial/skeleton/internal/service/role"
 	"github.com/izzanzahrial/skeleton/internal/service/user"
 	"github.com/izzanzahrial/skeleton/otlp"
+	"github.com/izzanzahrial/skeleton/pkg/envelope"
+	"github.com/izzanzahrial/skeleton/pkg/mailer"
+	"github.com/izzanzahrial/skeleton/pkg/password"
+	"github.com/izzanzahrial/skeleton/pkg/token"
 	pkgvalidator "github.com/izzanzahrial/skeleton/pkg/validator"
+	"github.com/izzanzahrial/skeleton/pkg/webauthn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -133,9 +144,29 @@ func main() {
 	db := db.New(conn)
 	cache := cache.New(rdb)
 
-	auht0, err := auth0.New()
+	oidcCfgs, err := config.NewOIDCProviders()
 	if err != nil {
-		slog.Warn("failed to create auth0", slog.String("error", err.Error()))
+		log.Fatalf("failed to initialize oidc providers configuration: %v", err)
+	}
+
+	providers, err := oauth.NewRegistry(context.Background(), oidcCfgs)
+	if err != nil {
+		slog.Warn("failed to create oidc providers", slog.String("error", err.Error()))
+	}
+
+	jwtCfg, err := config.NewJWT()
+	if err != nil {
+		log.Fatalf("failed to initialize jwt configuration: %v", err)
+	}
+
+	keys, err := token.LoadKeys(jwtCfg.KeysDir)
+	if err != nil {
+		log.Fatalf("failed to load jwt keys: %v", err)
+	}
+
+	keyManager, err := token.NewKeyManager(jwtCfg.ActiveKeyID, keys...)
+	if err != nil {
+		log.Fatalf("failed to create jwt key manager: %v", err)
 	}
 
 	producer, err := broker.NewProducer()
@@ -143,18 +174,136 @@ func main() {
 		slog.Warn("failed to create producer", slog.String("error", err.Error()))
 	}
 
-	authService := authentication.NewService(db, cache, logger)
-	authHandler := authhandler.NewHandler(authService, auht0, logger)
+	oauthCfg, err := config.NewOAuth()
+	if err != nil {
+		log.Fatalf("failed to initialize oauth configuration: %v", err)
+	}
+
+	authCfg, err := config.NewAuth()
+	if err != nil {
+		log.Fatalf("failed to initialize auth configuration: %v", err)
+	}
+
+	sessionCfg, err := config.NewSession()
+	if err != nil {
+		log.Fatalf("failed to initialize session configuration: %v", err)
+	}
+
+	passwordCfg, err := config.NewPassword()
+	if err != nil {
+		log.Fatalf("failed to initialize password configuration: %v", err)
+	}
+
+	hasher, err := password.New(password.Params{
+		Algorithm:         password.Algorithm(passwordCfg.Algorithm),
+		Argon2Memory:      passwordCfg.Argon2Memory,
+		Argon2Iterations:  passwordCfg.Argon2Iterations,
+		Argon2Parallelism: passwordCfg.Argon2Parallelism,
+		BcryptCost:        passwordCfg.BcryptCost,
+		ScryptLogN:        passwordCfg.ScryptLogN,
+		ScryptR:           passwordCfg.ScryptR,
+		ScryptP:           passwordCfg.ScryptP,
+	})
+	if err != nil {
+		log.Fatalf("failed to create password hasher: %v", err)
+	}
+
+	mailerCfg, err := config.NewMailer()
+	if err != nil {
+		log.Fatalf("failed to initialize mailer configuration: %v", err)
+	}
 
-	userService := user.NewService(db, logger)
-	userHandler := userhandler.NewHandler(userService, logger)
+	mail, err := newMailer(mailerCfg, logger)
+	if err != nil {
+		log.Fatalf("failed to create mailer: %v", err)
+	}
+
+	encryptionCfg, err := config.NewEncryption()
+	if err != nil {
+		log.Fatalf("failed to initialize encryption configuration: %v", err)
+	}
+
+	fieldKeys, err := envelope.NewKeyring(encryptionCfg.ActiveKeyVersion, encryptionCfg.MasterKeys)
+	if err != nil {
+		log.Fatalf("failed to create encryption keyring: %v", err)
+	}
+
+	webauthnCfg, err := config.NewWebAuthn()
+	if err != nil {
+		log.Fatalf("failed to initialize webauthn configuration: %v", err)
+	}
+
+	authServiceCfgs := []authentication.ServiceConfig{
+		authentication.WithRedirectAllowlist(oauthCfg.RedirectAllowlist),
+		authentication.WithMailer(mail, mailerCfg.From, mailerCfg.LinkBaseURL),
+		authentication.WithRequireVerifiedEmail(authCfg.RequireVerifiedEmail),
+		authentication.WithPasswordHasher(hasher),
+		authentication.WithFieldEncryption(fieldKeys),
+	}
+	if webauthnCfg.RPID != "" {
+		rp, err := webauthn.New(webauthnCfg.RPID, webauthnCfg.RPName, webauthnCfg.Origins)
+		if err != nil {
+			log.Fatalf("failed to create webauthn relying party: %v", err)
+		}
+		authServiceCfgs = append(authServiceCfgs, authentication.WithPasskeys(rp))
+	}
+
+	authService, err := authentication.NewService(db, cache, logger, authServiceCfgs...)
+	if err != nil {
+		log.Fatalf("failed to create authentication service: %v", err)
+	}
+	var authHandlerCfgs []authhandler.HandlerConfig
+	var middlewareCfgs []authmiddleware.Config
+	if sessionCfg.TokenLookup == "cookie" {
+		authHandlerCfgs = append(authHandlerCfgs, authhandler.WithCookies(authhandler.CookieConfig{
+			Domain:   sessionCfg.CookieDomain,
+			Secure:   sessionCfg.CookieSecure,
+			SameSite: sessionCfg.CookieSameSite,
+		}))
+		middlewareCfgs = append(middlewareCfgs, authmiddleware.WithCookieTokens())
+	}
+	authHandler := authhandler.NewHandler(authService, providers, keyManager, logger, authHandlerCfgs...)
+
+	userService := user.NewService(db, hasher, logger)
+	userHandler := userhandler.NewHandler(userService, authService, logger)
 
 	postService := post.NewService(db, producer, logger)
 	postHandler := posthandler.NewHandler(postService, logger)
 
-	handlers := handlers.NewHandlers(authHandler, userHandler, postHandler)
+	roleService := role.NewService(db, conn, logger)
+	roleHandler := rolehandler.NewHandler(roleService, logger)
 
-	cv, err := pkgvalidator.New()
+	oauthServerCfg, err := config.NewOAuthServer()
+	if err != nil {
+		log.Fatalf("failed to initialize oauth server configuration: %v", err)
+	}
+
+	var authorizationHandler *authorizationhandler.Handler
+	if oauthServerCfg.Issuer != "" {
+		authorizationService := authorization.NewService(db, cache, keyManager, oauthServerCfg.Issuer, logger)
+		authorizationHandler = authorizationhandler.NewHandler(authorizationService, keyManager, oauthServerCfg.Issuer, oauthServerCfg.AuthorizationURL, logger)
+		middlewareCfgs = append(middlewareCfgs, authmiddleware.WithOAuthClients(authorizationService))
+	}
+
+	handlers := handlers.NewHandlers(authHandler, userHandler, postHandler, roleHandler, authorizationHandler)
+	mw := authmiddleware.New(keyManager, cache, authService, roleService, authService, logger, middlewareCfgs...)
+
+	policy := password.DefaultPolicy
+	if passwordCfg.MinLength != 0 {
+		policy.MinLength = passwordCfg.MinLength
+	}
+	if passwordCfg.MinScore != nil {
+		policy.MinScore = *passwordCfg.MinScore
+	}
+	if passwordCfg.BreachedDir != "" {
+		breached, err := password.NewBreachedCorpus(passwordCfg.BreachedDir)
+		if err != nil {
+			log.Fatalf("failed to load breached password corpus: %v", err)
+		}
+		policy.Breached = breached
+	}
+
+	cv, err := pkgvalidator.New(pkgvalidator.WithPasswordPolicy(&policy))
 	if err != nil {
 		slog.Warn("failed to create validator", slog.String("error", err.Error()))
 	}
@@ -163,18 +312,48 @@ func main() {
 	// add echo instrumentation library https://github.com/open-telemetry/opentelemetry-go-contrib/tree/main/instrumentation/github.com/labstack/echo
 	server.Use(otelecho.Middleware("skeleton-service"), middleware.Logger())
 	server.Validator = cv
+	server.IPExtractor = newIPExtractor(authCfg.TrustedProxies)
+	if sessionCfg.TokenLookup == "cookie" {
+		server.Use(authmiddleware.CSRF(sessionCfg.CSRFTokenLookup, sessionCfg.CookieDomain, sessionCfg.CookieSecure, sessionCfg.CookieSameSite, "/oauth/token", "/oauth/introspect", "/oauth/revoke"))
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	router.MapRoutes(server, handlers)
+	router.MapRoutes(server, handlers, mw)
 	if err := server.Start(":" + port); err != nil {
 		slog.Warn("failed to start server", slog.String("error", err.Error()))
 	}
 }
 
+// newIPExtractor only reads X-Forwarded-For behind a trusted proxy, otherwise any client could pick the ip
+// the login throttle counts its failures against
+func newIPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipRange := range trustedProxies {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+func newMailer(cfg *config.Mailer, logger *slog.Logger) (mailer.Mailer, error) {
+	switch cfg.Backend {
+	case "smtp":
+		return mailer.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case "file":
+		return mailer.NewFile(cfg.Dir)
+	default:
+		return mailer.NewLog(logger), nil
+	}
+}
+
 func logLevel(level string) slog.Level {
 	switch level {
 	case "debug":
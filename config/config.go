@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -110,6 +113,430 @@ func NewCache() (*Cache, error) {
 	return &ch, nil
 }
 
+type JWT struct {
+	// KeysDir holds the PEM encoded signing keys, the file name is used as the key ID
+	KeysDir string
+	// ActiveKeyID is the key used to sign new tokens, the other keys are only used for verification
+	ActiveKeyID string
+}
+
+func NewJWT() (*JWT, error) {
+	var j JWT
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		return nil, errors.New("environment JWT_KEYS_DIR must be set")
+	}
+	j.KeysDir = keysDir
+
+	activeKeyID := os.Getenv("JWT_ACTIVE_KEY_ID")
+	if activeKeyID == "" {
+		return nil, errors.New("environment JWT_ACTIVE_KEY_ID must be set")
+	}
+	j.ActiveKeyID = activeKeyID
+
+	return &j, nil
+}
+
+// Encryption holds the master keys wrapping the data keys of the encrypted fields, e.g. oauth refresh tokens
+type Encryption struct {
+	// MasterKeys are 32 bytes keys by version, old versions are kept to decrypt values until they are rewrapped
+	MasterKeys map[uint32][]byte
+	// ActiveKeyVersion is the master key used for new values
+	ActiveKeyVersion uint32
+}
+
+func NewEncryption() (*Encryption, error) {
+	e := Encryption{MasterKeys: make(map[uint32][]byte)}
+
+	masterKeysString := os.Getenv("ENCRYPTION_MASTER_KEYS")
+	if masterKeysString == "" {
+		return nil, errors.New("environment ENCRYPTION_MASTER_KEYS must be set")
+	}
+
+	for _, pair := range strings.Split(masterKeysString, ",") {
+		versionString, encodedKey, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, errors.New("environment ENCRYPTION_MASTER_KEYS must be a list of version:base64 key")
+		}
+
+		version, err := strconv.ParseUint(versionString, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("environment ENCRYPTION_MASTER_KEYS has an invalid version %q", versionString)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("environment ENCRYPTION_MASTER_KEYS has an invalid key for version %d", version)
+		}
+		e.MasterKeys[uint32(version)] = key
+	}
+
+	activeKeyVersionString := os.Getenv("ENCRYPTION_ACTIVE_KEY_VERSION")
+	if activeKeyVersionString == "" {
+		return nil, errors.New("environment ENCRYPTION_ACTIVE_KEY_VERSION must be set")
+	}
+	activeKeyVersion, err := strconv.ParseUint(activeKeyVersionString, 10, 32)
+	if err != nil {
+		return nil, errors.New("environment ENCRYPTION_ACTIVE_KEY_VERSION must be a number")
+	}
+	e.ActiveKeyVersion = uint32(activeKeyVersion)
+
+	return &e, nil
+}
+
+type Auth struct {
+	// RequireVerifiedEmail rejects the login of native users until they open the verification link
+	RequireVerifiedEmail bool
+	// TrustedProxies are the proxies whose X-Forwarded-For is trusted, the client ip is the peer address when it's empty
+	TrustedProxies []*net.IPNet
+}
+
+func NewAuth() (*Auth, error) {
+	var a Auth
+	requireVerifiedEmailString := os.Getenv("AUTH_REQUIRE_VERIFIED_EMAIL")
+	if requireVerifiedEmailString != "" {
+		requireVerifiedEmail, err := strconv.ParseBool(requireVerifiedEmailString)
+		if err != nil {
+			return nil, errors.New("environment AUTH_REQUIRE_VERIFIED_EMAIL must be a boolean")
+		}
+		a.RequireVerifiedEmail = requireVerifiedEmail
+	}
+
+	if trustedProxies := os.Getenv("AUTH_TRUSTED_PROXIES"); trustedProxies != "" {
+		for _, cidr := range strings.Split(trustedProxies, ",") {
+			_, ipRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return nil, errors.New("environment AUTH_TRUSTED_PROXIES must be a comma separated list of cidr")
+			}
+			a.TrustedProxies = append(a.TrustedProxies, ipRange)
+		}
+	}
+
+	return &a, nil
+}
+
+// WebAuthn is the relying party of the passkeys, passkeys are disabled when RPID isn't set
+type WebAuthn struct {
+	// RPID is the domain the passkeys are bound to, e.g. example.com, it can't be changed without losing them
+	RPID   string
+	RPName string
+	// Origins are the frontend origins allowed to use the passkeys, e.g. https://app.example.com
+	Origins []string
+}
+
+func NewWebAuthn() (*WebAuthn, error) {
+	w := WebAuthn{RPID: os.Getenv("WEBAUTHN_RP_ID"), RPName: os.Getenv("WEBAUTHN_RP_NAME")}
+	if w.RPID == "" {
+		return &w, nil
+	}
+
+	if w.RPName == "" {
+		w.RPName = w.RPID
+	}
+
+	originsString := os.Getenv("WEBAUTHN_ORIGINS")
+	if originsString == "" {
+		return nil, errors.New("environment WEBAUTHN_ORIGINS must be set when WEBAUTHN_RP_ID is set")
+	}
+	w.Origins = strings.Split(originsString, ",")
+
+	return &w, nil
+}
+
+// Session picks how browser clients hold their tokens, with TokenLookup "cookie" login sets them
+// as HttpOnly cookies and the unsafe requests authenticated by cookie need a double submit csrf token
+type Session struct {
+	// TokenLookup is header (default) or cookie
+	TokenLookup    string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	// CSRFTokenLookup is where the csrf token is read from, e.g. header:X-CSRF-Token or form:_csrf
+	CSRFTokenLookup string
+}
+
+func NewSession() (*Session, error) {
+	s := Session{
+		TokenLookup:     "header",
+		CookieSecure:    true,
+		CookieSameSite:  http.SameSiteLaxMode,
+		CSRFTokenLookup: "header:X-CSRF-Token",
+	}
+
+	if tokenLookup := os.Getenv("SESSION_TOKEN_LOOKUP"); tokenLookup != "" {
+		if tokenLookup != "header" && tokenLookup != "cookie" {
+			return nil, errors.New("environment SESSION_TOKEN_LOOKUP must be header or cookie")
+		}
+		s.TokenLookup = tokenLookup
+	}
+
+	s.CookieDomain = os.Getenv("SESSION_COOKIE_DOMAIN")
+
+	cookieSecureString := os.Getenv("SESSION_COOKIE_SECURE")
+	if cookieSecureString != "" {
+		cookieSecure, err := strconv.ParseBool(cookieSecureString)
+		if err != nil {
+			return nil, errors.New("environment SESSION_COOKIE_SECURE must be a boolean")
+		}
+		s.CookieSecure = cookieSecure
+	}
+
+	switch os.Getenv("SESSION_COOKIE_SAMESITE") {
+	case "", "lax":
+	case "strict":
+		s.CookieSameSite = http.SameSiteStrictMode
+	case "none":
+		// browsers only accept SameSite=None on secure cookies
+		s.CookieSameSite = http.SameSiteNoneMode
+		s.CookieSecure = true
+	default:
+		return nil, errors.New("environment SESSION_COOKIE_SAMESITE must be lax, strict or none")
+	}
+
+	if csrfTokenLookup := os.Getenv("SESSION_CSRF_TOKEN_LOOKUP"); csrfTokenLookup != "" {
+		s.CSRFTokenLookup = csrfTokenLookup
+	}
+
+	return &s, nil
+}
+
+type Password struct {
+	// Algorithm is argon2id, bcrypt or scrypt, a zero cost falls back to the default of pkg/password
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+	ScryptLogN        uint8
+	ScryptR           int
+	ScryptP           int
+	// MinLength and MinScore are the password policy for new passwords, a zero MinLength or a nil MinScore
+	// uses the default policy, a score of 0 is a valid setting that turns the strength check off
+	MinLength int
+	MinScore  *int
+	// BreachedDir holds the Pwned Passwords range files, new passwords aren't checked for breaches when it's empty
+	BreachedDir string
+}
+
+func NewPassword() (*Password, error) {
+	var p Password
+	p.Algorithm = os.Getenv("PASSWORD_HASH_ALGORITHM")
+
+	costs := []struct {
+		env  string
+		bits int
+		set  func(v uint64)
+	}{
+		{"PASSWORD_ARGON2_MEMORY", 32, func(v uint64) { p.Argon2Memory = uint32(v) }},
+		{"PASSWORD_ARGON2_ITERATIONS", 32, func(v uint64) { p.Argon2Iterations = uint32(v) }},
+		{"PASSWORD_ARGON2_PARALLELISM", 8, func(v uint64) { p.Argon2Parallelism = uint8(v) }},
+		{"PASSWORD_BCRYPT_COST", 8, func(v uint64) { p.BcryptCost = int(v) }},
+		{"PASSWORD_SCRYPT_LN", 8, func(v uint64) { p.ScryptLogN = uint8(v) }},
+		{"PASSWORD_SCRYPT_R", 16, func(v uint64) { p.ScryptR = int(v) }},
+		{"PASSWORD_SCRYPT_P", 16, func(v uint64) { p.ScryptP = int(v) }},
+		{"PASSWORD_MIN_LENGTH", 16, func(v uint64) { p.MinLength = int(v) }},
+		{"PASSWORD_MIN_SCORE", 8, func(v uint64) { score := int(v); p.MinScore = &score }},
+	}
+
+	for _, cost := range costs {
+		valueString := os.Getenv(cost.env)
+		if valueString == "" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(valueString, 10, cost.bits)
+		if err != nil {
+			return nil, fmt.Errorf("environment %s must be a positive integer", cost.env)
+		}
+		cost.set(value)
+	}
+
+	if p.MinScore != nil && *p.MinScore > 4 {
+		return nil, errors.New("environment PASSWORD_MIN_SCORE must be between 0 and 4")
+	}
+
+	p.BreachedDir = os.Getenv("PASSWORD_BREACHED_DIR")
+
+	return &p, nil
+}
+
+type OAuth struct {
+	// RedirectAllowlist holds the origins the user may be sent back to after an oauth login
+	RedirectAllowlist []string
+}
+
+func NewOAuth() (*OAuth, error) {
+	var o OAuth
+	allowlistString := os.Getenv("OAUTH_REDIRECT_ALLOWLIST")
+	if allowlistString != "" {
+		o.RedirectAllowlist = strings.Split(allowlistString, ",")
+	}
+
+	return &o, nil
+}
+
+// OAuthServer is the built-in authorization server, it's disabled when Issuer isn't set
+type OAuthServer struct {
+	// Issuer is the public base url of this api, e.g. https://api.example.com, the oauth endpoints are served under it
+	Issuer string
+	// AuthorizationURL is the frontend page asking the user to approve the client, it calls /oauth/authorize
+	AuthorizationURL string
+}
+
+func NewOAuthServer() (*OAuthServer, error) {
+	o := OAuthServer{Issuer: strings.TrimSuffix(os.Getenv("OAUTH_SERVER_ISSUER"), "/")}
+	if o.Issuer == "" {
+		return &o, nil
+	}
+
+	o.AuthorizationURL = os.Getenv("OAUTH_SERVER_AUTHORIZATION_URL")
+	if o.AuthorizationURL == "" {
+		return nil, errors.New("environment OAUTH_SERVER_AUTHORIZATION_URL must be set when OAUTH_SERVER_ISSUER is set")
+	}
+
+	return &o, nil
+}
+
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// AuthParams are extra parameters added to the authorization url, e.g. access_type=offline for google
+	AuthParams map[string]string
+	// Claims maps a user field to the id token claim holding it, when the provider doesn't use the standard claim
+	Claims map[string]string
+}
+
+// claimFields are the user fields that can be mapped to a provider specific claim
+var claimFields = []string{"subject", "email", "email_verified", "name", "given_name", "family_name", "picture"}
+
+// NewOIDCProviders reads every provider listed in OIDC_PROVIDERS,
+// each provider is configured using OIDC_<NAME>_* environment variables
+func NewOIDCProviders() ([]OIDCProvider, error) {
+	providersString := os.Getenv("OIDC_PROVIDERS")
+	if providersString == "" {
+		return nil, nil
+	}
+
+	var providers []OIDCProvider
+	for _, name := range strings.Split(providersString, ",") {
+		name = strings.TrimSpace(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		p := OIDCProvider{
+			Name:       name,
+			Scopes:     []string{"openid", "profile", "email"},
+			AuthParams: make(map[string]string),
+			Claims:     make(map[string]string),
+		}
+
+		p.Issuer = os.Getenv(prefix + "ISSUER")
+		if p.Issuer == "" {
+			return nil, fmt.Errorf("environment %sISSUER must be set", prefix)
+		}
+
+		p.ClientID = os.Getenv(prefix + "CLIENT_ID")
+		if p.ClientID == "" {
+			return nil, fmt.Errorf("environment %sCLIENT_ID must be set", prefix)
+		}
+
+		p.ClientSecret = os.Getenv(prefix + "CLIENT_SECRET")
+		if p.ClientSecret == "" {
+			return nil, fmt.Errorf("environment %sCLIENT_SECRET must be set", prefix)
+		}
+
+		p.RedirectURL = os.Getenv(prefix + "REDIRECT_URL")
+		if p.RedirectURL == "" {
+			return nil, fmt.Errorf("environment %sREDIRECT_URL must be set", prefix)
+		}
+
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			p.Scopes = strings.Split(scopes, ",")
+		}
+
+		if authParams := os.Getenv(prefix + "AUTH_PARAMS"); authParams != "" {
+			for _, param := range strings.Split(authParams, ",") {
+				key, value, ok := strings.Cut(param, "=")
+				if !ok {
+					return nil, fmt.Errorf("environment %sAUTH_PARAMS must be a list of key=value", prefix)
+				}
+				p.AuthParams[key] = value
+			}
+		}
+
+		for _, field := range claimFields {
+			if claim := os.Getenv(prefix + "CLAIM_" + strings.ToUpper(field)); claim != "" {
+				p.Claims[field] = claim
+			}
+		}
+
+		providers = append(providers, p)
+	}
+
+	return providers, nil
+}
+
+type Mailer struct {
+	// Backend is either smtp, file or log, file and log are meant for local and CI use
+	Backend string
+	From    string
+	// LinkBaseURL is the frontend url the links sent by email point to, e.g. the password reset page
+	LinkBaseURL  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// Dir is where the file backend writes the emails
+	Dir string
+}
+
+func NewMailer() (*Mailer, error) {
+	var m Mailer
+	m.Backend = os.Getenv("MAILER_BACKEND")
+	if m.Backend == "" {
+		m.Backend = "log"
+	}
+
+	m.From = os.Getenv("MAILER_FROM")
+	if m.From == "" {
+		return nil, errors.New("environment MAILER_FROM must be set")
+	}
+
+	m.LinkBaseURL = os.Getenv("MAILER_LINK_BASE_URL")
+	if m.LinkBaseURL == "" {
+		return nil, errors.New("environment MAILER_LINK_BASE_URL must be set")
+	}
+
+	switch m.Backend {
+	case "smtp":
+		m.SMTPHost = os.Getenv("MAILER_SMTP_HOST")
+		if m.SMTPHost == "" {
+			return nil, errors.New("environment MAILER_SMTP_HOST must be set")
+		}
+
+		m.SMTPPort = os.Getenv("MAILER_SMTP_PORT")
+		if m.SMTPPort == "" {
+			m.SMTPPort = "587"
+		}
+
+		m.SMTPUsername = os.Getenv("MAILER_SMTP_USERNAME")
+		m.SMTPPassword = os.Getenv("MAILER_SMTP_PASSWORD")
+	case "file":
+		m.Dir = os.Getenv("MAILER_DIR")
+		if m.Dir == "" {
+			return nil, errors.New("environment MAILER_DIR must be set")
+		}
+	case "log":
+	default:
+		return nil, fmt.Errorf("environment MAILER_BACKEND must be smtp, file or log, got %q", m.Backend)
+	}
+
+	return &m, nil
+}
+
 type Producer struct {
 	// Version           [4]uint
 	// FlushBytes        int
@@ -0,0 +1,9 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- ALTER TYPE ... ADD VALUE can't be used in the same transaction that added it
+ALTER TYPE origins ADD VALUE IF NOT EXISTS 'auth0';
+
+-- +goose Down
+-- postgres doesn't support removing a value from an enum,
+-- the value is kept since users with auth0 origin may already exist
+SELECT 1;
@@ -0,0 +1,9 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- origin of users that logged in through a generic oidc provider, e.g. keycloak, gitlab or dex
+ALTER TYPE origins ADD VALUE IF NOT EXISTS 'oidc';
+
+-- +goose Down
+-- postgres doesn't support removing a value from an enum,
+-- the value is kept since users with oidc origin may already exist
+SELECT 1;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- one user can log in with several external identities,
+-- an identity is identified by the provider name and the subject the provider gave to the user
+CREATE TABLE IF NOT EXISTS user_identities (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email citext NOT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE,
+    CONSTRAINT user_identities_provider_subject_key UNIQUE (provider, subject),
+    -- a user can only link one identity per provider
+    CONSTRAINT user_identities_user_id_provider_key UNIQUE (user_id, provider)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_identities;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- totp secret of a native user, it's only used for login once confirmed_at is set
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id bigint PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmed_at TIMESTAMPTZ,
+    secret text NOT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+-- one time recovery codes, only the sha256 hash of a code is stored
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ,
+    code_hash bytea NOT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- oauth users could only sign up with an email verified by their provider,
+-- native users signed up before there was a verification link to open so they are verified as well,
+-- otherwise enabling AUTH_REQUIRE_VERIFIED_EMAIL would lock every existing native user out
+UPDATE users SET email_verified_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+-- +goose StatementEnd
@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- roles were a fixed enum, they're rows now so admins can create them,
+-- the enum is dropped first since the roles table needs its name
+ALTER TABLE users ALTER COLUMN role TYPE text USING role::text;
+DROP TYPE IF EXISTS roles;
+
+CREATE TABLE IF NOT EXISTS roles (
+    name text PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    description text NOT NULL DEFAULT '',
+    -- builtin roles are used by the signup flows and can't be deleted
+    builtin boolean NOT NULL DEFAULT false
+);
+
+-- permissions are checked by the code, so they are only added by migrations
+CREATE TABLE IF NOT EXISTS permissions (
+    name text PRIMARY KEY,
+    description text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role text NOT NULL,
+    permission text NOT NULL,
+    PRIMARY KEY (role, permission),
+    CONSTRAINT fk_role
+        FOREIGN KEY (role)
+            REFERENCES roles (name)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_permission
+        FOREIGN KEY (permission)
+            REFERENCES permissions (name)
+            ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description, builtin) VALUES
+    ('admin', 'Full access', true),
+    ('user', 'Default role of new users', true);
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'List and search users'),
+    ('users:write', 'Create admins, unlock users and revoke their sessions'),
+    ('users:delete', 'Delete users'),
+    ('posts:moderate', 'Update and delete posts of other users'),
+    ('roles:manage', 'Create roles and assign them to users');
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions;
+
+-- a role that is still assigned can't be deleted
+ALTER TABLE users ADD CONSTRAINT fk_role
+    FOREIGN KEY (role)
+        REFERENCES roles (name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_role;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+
+-- fails when a user still has a role created after the up migration
+CREATE TYPE roles AS ENUM (
+    'admin',
+    'user'
+);
+ALTER TABLE users ALTER COLUMN role TYPE roles USING role::roles;
+-- +goose StatementEnd
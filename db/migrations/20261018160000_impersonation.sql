@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Act as another user with a short lived token');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'users:impersonate');
+
+-- every request made with an impersonation token, and the request that issued it
+CREATE TABLE IF NOT EXISTS impersonation_audit_logs (
+    id bigserial PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    -- token_id is the jti of the impersonation token
+    token_id text NOT NULL,
+    method text NOT NULL,
+    path text NOT NULL,
+    ip text NOT NULL,
+    -- users are soft deleted, the audit logs are kept when they are removed for good
+    CONSTRAINT fk_actor
+        FOREIGN KEY (actor_id)
+            REFERENCES users (id)
+            ON DELETE RESTRICT,
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_audit_logs_actor_id_idx ON impersonation_audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS impersonation_audit_logs_user_id_idx ON impersonation_audit_logs (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS impersonation_audit_logs;
+DELETE FROM permissions WHERE name = 'users:impersonate';
+-- +goose StatementEnd
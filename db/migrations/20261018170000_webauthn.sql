@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- passkeys of a user, the public key is the COSE_Key returned when the passkey was registered
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    name text NOT NULL,
+    public_key bytea NOT NULL,
+    sign_count bigint NOT NULL DEFAULT 0,
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id_idx ON webauthn_credentials (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webauthn_credentials;
+-- +goose StatementEnd
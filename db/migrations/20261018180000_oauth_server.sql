@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO permissions (name, description) VALUES
+    ('clients:manage', 'Register and revoke the oauth clients');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'clients:manage');
+
+-- apps allowed to get tokens from the authorization server, public clients (e.g. single page apps) have no secret
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id text PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    -- secret_hash is the sha256 hash of the client secret
+    secret_hash bytea,
+    redirect_uris text[] NOT NULL DEFAULT '{}',
+    grant_types text[] NOT NULL,
+    scopes text[] NOT NULL,
+    -- first party clients are trusted, the user isn't asked for consent
+    first_party boolean NOT NULL DEFAULT false,
+    revoked_at TIMESTAMPTZ
+);
+
+-- scopes a user allowed a client to use, the user isn't asked again for them
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id bigint NOT NULL,
+    client_id text NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    scopes text[] NOT NULL,
+    PRIMARY KEY (user_id, client_id),
+    CONSTRAINT fk_user
+        FOREIGN KEY (user_id)
+            REFERENCES users (id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_client
+        FOREIGN KEY (client_id)
+            REFERENCES oauth_clients (id)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oauth_consents;
+DROP TABLE IF EXISTS oauth_clients;
+DELETE FROM permissions WHERE name = 'clients:manage';
+-- +goose StatementEnd
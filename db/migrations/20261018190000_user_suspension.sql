@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN suspended_at TIMESTAMPTZ,
+    -- suspended_until is NULL for a ban, the user is suspended until a moderator lifts it
+    ADD COLUMN suspended_until TIMESTAMPTZ,
+    ADD COLUMN suspension_reason TEXT;
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:suspend', 'Suspend or ban users, and lift their suspension');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'users:suspend');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'users:suspend';
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS suspended_at,
+    DROP COLUMN IF EXISTS suspended_until,
+    DROP COLUMN IF EXISTS suspension_reason;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the token scopes are only for the oauth clients registered by an admin, the api keys of users lose them
+UPDATE api_keys SET scopes = array_remove(array_remove(scopes, 'tokens:introspect'), 'tokens:revoke');
+-- +goose StatementEnd
+
+-- +goose Down
+-- the removed scopes can't be given back to the keys that had them
+SELECT 1;
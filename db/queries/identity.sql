@@ -0,0 +1,23 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+    user_id,
+    provider,
+    subject,
+    email
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2
+LIMIT 1;
+
+-- name: GetUserIdentitiesByUserID :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities
+WHERE user_id = $1 AND provider = $2;
@@ -0,0 +1,11 @@
+-- name: CreateImpersonationAuditLog :exec
+INSERT INTO impersonation_audit_logs (
+    actor_id,
+    user_id,
+    token_id,
+    method,
+    path,
+    ip
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
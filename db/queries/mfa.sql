@@ -0,0 +1,50 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_totp (
+    user_id,
+    secret
+) VALUES (
+    $1, $2
+) ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = NOW(), confirmed_at = NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1 LIMIT 1;
+
+-- name: ConfirmUserTOTP :exec
+UPDATE user_totp
+SET confirmed_at = NOW()
+WHERE user_id = $1;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_codes (
+    user_id,
+    code_hash
+) VALUES (
+    $1, $2
+);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: GetUserTOTPSecrets :many
+SELECT user_id, secret FROM user_totp
+WHERE user_id > $1
+ORDER BY user_id
+LIMIT $2;
+
+-- name: UpdateUserTOTPSecret :execrows
+UPDATE user_totp
+SET secret = @new_secret
+WHERE user_id = @user_id AND secret = @old_secret;
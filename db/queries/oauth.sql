@@ -0,0 +1,51 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (
+    id,
+    name,
+    secret_hash,
+    redirect_uris,
+    grant_types,
+    scopes,
+    first_party
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1 AND revoked_at IS NULL
+LIMIT 1;
+
+-- name: GetOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: RevokeOAuthClient :execrows
+UPDATE oauth_clients
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: UpsertOAuthConsent :exec
+INSERT INTO oauth_consents (
+    user_id,
+    client_id,
+    scopes
+) VALUES (
+    $1, $2, $3
+) ON CONFLICT (user_id, client_id) DO UPDATE
+SET scopes = EXCLUDED.scopes, updated_at = NOW();
+
+-- name: GetOAuthConsent :one
+SELECT * FROM oauth_consents
+WHERE user_id = $1 AND client_id = $2
+LIMIT 1;
+
+-- name: GetOAuthConsentsByUserID :many
+SELECT * FROM oauth_consents
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteOAuthConsent :execrows
+DELETE FROM oauth_consents
+WHERE user_id = $1 AND client_id = $2;
@@ -1,7 +1,9 @@
 -- name: GetPostsFullText :many
 SELECT * FROM posts
-WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', sqlc.arg(keyword)::text) OR title = '')
-OR (to_tsvector('simple', content) @@ plainto_tsquery('simple', sqlc.arg(keyword)::text) OR content = '')
+WHERE deleted_at IS NULL AND (
+    (to_tsvector('simple', title) @@ plainto_tsquery('simple', sqlc.arg(keyword)::text) OR title = '')
+    OR (to_tsvector('simple', content) @@ plainto_tsquery('simple', sqlc.arg(keyword)::text) OR content = '')
+)
 LIMIT COALESCE(sqlc.narg(limit_param)::int, 10) 
 OFFSET $1;
 
@@ -16,4 +18,19 @@ INSERT INTO posts (
 
 -- name: GetPostByUserID :many
 SELECT * FROM posts 
-WHERE user_id = $1;
\ No newline at end of file
+WHERE user_id = $1 AND deleted_at IS NULL;
+
+-- name: GetPost :one
+SELECT * FROM posts
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: UpdatePost :one
+UPDATE posts
+SET updated_at = NOW(), title = $1, content = $2
+WHERE id = $3 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeletePost :exec
+UPDATE posts
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,49 @@
+-- name: CreateRole :one
+INSERT INTO roles (
+    name,
+    description
+) VALUES (
+    $1, $2
+)
+RETURNING *;
+
+-- name: GetRole :one
+SELECT * FROM roles
+WHERE name = $1 LIMIT 1;
+
+-- name: GetRoles :many
+SELECT * FROM roles
+ORDER BY name;
+
+-- name: DeleteRole :execrows
+DELETE FROM roles
+WHERE name = $1 AND NOT builtin;
+
+-- name: GetPermissions :many
+SELECT * FROM permissions
+ORDER BY name;
+
+-- name: GetRolePermissions :many
+SELECT permission FROM role_permissions
+WHERE role = $1
+ORDER BY permission;
+
+-- name: GetAllRolePermissions :many
+SELECT * FROM role_permissions
+ORDER BY role, permission;
+
+-- name: AddRolePermissions :exec
+INSERT INTO role_permissions (role, permission)
+SELECT @role::text, unnest(@permissions::text[])
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteRolePermissions :exec
+DELETE FROM role_permissions
+WHERE role = $1;
+
+-- name: UserHasPermission :one
+SELECT EXISTS (
+    SELECT 1 FROM users u
+    JOIN role_permissions rp ON rp.role = u.role
+    WHERE u.id = $1 AND u.deleted_at IS NULL AND rp.permission = $2
+);
@@ -53,7 +53,8 @@ RETURNING *;
 
 -- name: UpdateUser :one
 UPDATE users
-SET updated_at = NOW(), email = $1, username = $2, password_hash = $3
+SET updated_at = NOW(), email = $1, username = $2, password_hash = $3,
+    email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
 WHERE id = $4 AND deleted_at IS NULL
 RETURNING *;
 
@@ -70,9 +71,10 @@ INSERT INTO users (
     picture_url,
     refresh_token,
     role,
-    origin
+    origin,
+    email_verified_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, NOW()
 ) RETURNING *;
 
 -- name: GetuserByEmail :one
@@ -80,3 +82,31 @@ SELECT * FROM users
 WHERE (email = $1 OR $1 = '')
 AND deleted_at IS NULL
 LIMIT 1;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND deleted_at IS NULL;
+
+-- name: GetUserRefreshTokens :many
+SELECT id, refresh_token FROM users
+WHERE refresh_token IS NOT NULL AND id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: UpdateUserRefreshToken :execrows
+UPDATE users
+SET refresh_token = @new_refresh_token
+WHERE id = @id AND refresh_token = @old_refresh_token;
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(), suspended_until = $2, suspension_reason = $3, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
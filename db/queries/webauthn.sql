@@ -0,0 +1,28 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (
+    id,
+    user_id,
+    name,
+    public_key,
+    sign_count
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetWebAuthnCredential :one
+SELECT * FROM webauthn_credentials
+WHERE id = $1 LIMIT 1;
+
+-- name: GetWebAuthnCredentialsByUserID :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UpdateWebAuthnCredentialSignCount :exec
+UPDATE webauthn_credentials
+SET sign_count = $2, last_used_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = $1 AND user_id = $2;
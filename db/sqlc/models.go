@@ -16,6 +16,8 @@ type Origins string
 const (
 	OriginsNative Origins = "native"
 	OriginsGoogle Origins = "google"
+	OriginsAuth0  Origins = "auth0"
+	OriginsOidc   Origins = "oidc"
 )
 
 func (e *Origins) Scan(src interface{}) error {
@@ -53,46 +55,54 @@ func (ns NullOrigins) Value() (driver.Value, error) {
 	return string(ns.Origins), nil
 }
 
-type Roles string
-
-const (
-	RolesAdmin Roles = "admin"
-	RolesUser  Roles = "user"
-)
+type ApiKey struct {
+	ID         int64              `json:"id"`
+	UserID     int64              `json:"user_id"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	KeyHash    []byte             `json:"key_hash"`
+	Scopes     []string           `json:"scopes"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	LastUsedIp pgtype.Text        `json:"last_used_ip"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+}
 
-func (e *Roles) Scan(src interface{}) error {
-	switch s := src.(type) {
-	case []byte:
-		*e = Roles(s)
-	case string:
-		*e = Roles(s)
-	default:
-		return fmt.Errorf("unsupported scan type for Roles: %T", src)
-	}
-	return nil
+type ImpersonationAuditLog struct {
+	ID        int64              `json:"id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ActorID   int64              `json:"actor_id"`
+	UserID    int64              `json:"user_id"`
+	TokenID   string             `json:"token_id"`
+	Method    string             `json:"method"`
+	Path      string             `json:"path"`
+	Ip        string             `json:"ip"`
 }
 
-type NullRoles struct {
-	Roles Roles `json:"roles"`
-	Valid bool  `json:"valid"` // Valid is true if Roles is not NULL
+type OauthClient struct {
+	ID           string             `json:"id"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	Name         string             `json:"name"`
+	SecretHash   []byte             `json:"secret_hash"`
+	RedirectUris []string           `json:"redirect_uris"`
+	GrantTypes   []string           `json:"grant_types"`
+	Scopes       []string           `json:"scopes"`
+	FirstParty   bool               `json:"first_party"`
+	RevokedAt    pgtype.Timestamptz `json:"revoked_at"`
 }
 
-// Scan implements the Scanner interface.
-func (ns *NullRoles) Scan(value interface{}) error {
-	if value == nil {
-		ns.Roles, ns.Valid = "", false
-		return nil
-	}
-	ns.Valid = true
-	return ns.Roles.Scan(value)
+type OauthConsent struct {
+	UserID    int64              `json:"user_id"`
+	ClientID  string             `json:"client_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	Scopes    []string           `json:"scopes"`
 }
 
-// Value implements the driver Valuer interface.
-func (ns NullRoles) Value() (driver.Value, error) {
-	if !ns.Valid {
-		return nil, nil
-	}
-	return string(ns.Roles), nil
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
 
 type Post struct {
@@ -105,18 +115,68 @@ type Post struct {
 	Content   string             `json:"content"`
 }
 
+type Role struct {
+	Name        string             `json:"name"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	Description string             `json:"description"`
+	Builtin     bool               `json:"builtin"`
+}
+
+type RolePermission struct {
+	Role       string `json:"role"`
+	Permission string `json:"permission"`
+}
+
 type User struct {
-	ID           int64              `json:"id"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
-	DeletedAt    pgtype.Timestamptz `json:"deleted_at"`
-	Email        string             `json:"email"`
-	Username     pgtype.Text        `json:"username"`
-	PasswordHash []byte             `json:"password_hash"`
-	Role         Roles              `json:"role"`
-	FirstName    pgtype.Text        `json:"first_name"`
-	LastName     pgtype.Text        `json:"last_name"`
-	PictureUrl   pgtype.Text        `json:"picture_url"`
-	RefreshToken pgtype.Text        `json:"refresh_token"`
-	Origin       Origins            `json:"origin"`
+	ID               int64              `json:"id"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt        pgtype.Timestamptz `json:"deleted_at"`
+	Email            string             `json:"email"`
+	Username         pgtype.Text        `json:"username"`
+	PasswordHash     []byte             `json:"password_hash"`
+	Role             string             `json:"role"`
+	FirstName        pgtype.Text        `json:"first_name"`
+	LastName         pgtype.Text        `json:"last_name"`
+	PictureUrl       pgtype.Text        `json:"picture_url"`
+	RefreshToken     pgtype.Text        `json:"refresh_token"`
+	Origin           Origins            `json:"origin"`
+	EmailVerifiedAt  pgtype.Timestamptz `json:"email_verified_at"`
+	SuspendedAt      pgtype.Timestamptz `json:"suspended_at"`
+	SuspendedUntil   pgtype.Timestamptz `json:"suspended_until"`
+	SuspensionReason pgtype.Text        `json:"suspension_reason"`
+}
+
+type UserIdentity struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Provider  string             `json:"provider"`
+	Subject   string             `json:"subject"`
+	Email     string             `json:"email"`
+}
+
+type UserRecoveryCode struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CodeHash  []byte             `json:"code_hash"`
+}
+
+type UserTotp struct {
+	UserID      int64              `json:"user_id"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	ConfirmedAt pgtype.Timestamptz `json:"confirmed_at"`
+	Secret      string             `json:"secret"`
+}
+
+type WebauthnCredential struct {
+	ID         []byte             `json:"id"`
+	UserID     int64              `json:"user_id"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	Name       string             `json:"name"`
+	PublicKey  []byte             `json:"public_key"`
+	SignCount  int64              `json:"sign_count"`
 }
@@ -42,9 +42,40 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 	return i, err
 }
 
+const deletePost = `-- name: DeletePost :exec
+UPDATE posts
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) DeletePost(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deletePost, id)
+	return err
+}
+
+const getPost = `-- name: GetPost :one
+SELECT id, user_id, created_at, updated_at, deleted_at, title, content FROM posts
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetPost(ctx context.Context, id int64) (Post, error) {
+	row := q.db.QueryRow(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Title,
+		&i.Content,
+	)
+	return i, err
+}
+
 const getPostByUserID = `-- name: GetPostByUserID :many
 SELECT id, user_id, created_at, updated_at, deleted_at, title, content FROM posts 
-WHERE user_id = $1
+WHERE user_id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetPostByUserID(ctx context.Context, userID int64) ([]Post, error) {
@@ -77,8 +108,10 @@ func (q *Queries) GetPostByUserID(ctx context.Context, userID int64) ([]Post, er
 
 const getPostsFullText = `-- name: GetPostsFullText :many
 SELECT id, user_id, created_at, updated_at, deleted_at, title, content FROM posts
-WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $2::text) OR title = '')
-OR (to_tsvector('simple', content) @@ plainto_tsquery('simple', $2::text) OR content = '')
+WHERE deleted_at IS NULL AND (
+    (to_tsvector('simple', title) @@ plainto_tsquery('simple', $2::text) OR title = '')
+    OR (to_tsvector('simple', content) @@ plainto_tsquery('simple', $2::text) OR content = '')
+)
 LIMIT COALESCE($3::int, 10) 
 OFFSET $1
 `
@@ -116,3 +149,31 @@ func (q *Queries) GetPostsFullText(ctx context.Context, arg GetPostsFullTextPara
 	}
 	return items, nil
 }
+
+const updatePost = `-- name: UpdatePost :one
+UPDATE posts
+SET updated_at = NOW(), title = $1, content = $2
+WHERE id = $3 AND deleted_at IS NULL
+RETURNING id, user_id, created_at, updated_at, deleted_at, title, content
+`
+
+type UpdatePostParams struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, error) {
+	row := q.db.QueryRow(ctx, updatePost, arg.Title, arg.Content, arg.ID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Title,
+		&i.Content,
+	)
+	return i, err
+}
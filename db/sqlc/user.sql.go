@@ -20,14 +20,14 @@ INSERT INTO users (
     origin
 ) VALUES (
     $1, $2, $3, $4, $5
-) RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin
+) RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
 `
 
 type CreateUserParams struct {
 	Email        string      `json:"email"`
 	Username     pgtype.Text `json:"username"`
 	PasswordHash []byte      `json:"password_hash"`
-	Role         Roles       `json:"role"`
+	Role         string      `json:"role"`
 	Origin       Origins     `json:"origin"`
 }
 
@@ -54,6 +54,10 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
@@ -66,10 +70,11 @@ INSERT INTO users (
     picture_url,
     refresh_token,
     role,
-    origin
+    origin,
+    email_verified_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-) RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin
+    $1, $2, $3, $4, $5, $6, $7, NOW()
+) RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
 `
 
 type CreateUserGoogleParams struct {
@@ -78,7 +83,7 @@ type CreateUserGoogleParams struct {
 	LastName     pgtype.Text `json:"last_name"`
 	PictureUrl   pgtype.Text `json:"picture_url"`
 	RefreshToken pgtype.Text `json:"refresh_token"`
-	Role         Roles       `json:"role"`
+	Role         string      `json:"role"`
 	Origin       Origins     `json:"origin"`
 }
 
@@ -107,6 +112,10 @@ func (q *Queries) CreateUserGoogle(ctx context.Context, arg CreateUserGooglePara
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
@@ -123,7 +132,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin FROM users 
+SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason FROM users 
 WHERE id = $1 LIMIT 1
 `
 
@@ -144,12 +153,16 @@ func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
 const getUserForUpdate = `-- name: GetUserForUpdate :one
-SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin FROM users 
+SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason FROM users 
 WHERE id = $1 LIMIT 1 
 FOR UPDATE
 `
@@ -171,12 +184,53 @@ func (q *Queries) GetUserForUpdate(ctx context.Context, id int64) (User, error)
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
+const getUserRefreshTokens = `-- name: GetUserRefreshTokens :many
+SELECT id, refresh_token FROM users
+WHERE refresh_token IS NOT NULL AND id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type GetUserRefreshTokensParams struct {
+	ID    int64 `json:"id"`
+	Limit int32 `json:"limit"`
+}
+
+type GetUserRefreshTokensRow struct {
+	ID           int64       `json:"id"`
+	RefreshToken pgtype.Text `json:"refresh_token"`
+}
+
+func (q *Queries) GetUserRefreshTokens(ctx context.Context, arg GetUserRefreshTokensParams) ([]GetUserRefreshTokensRow, error) {
+	rows, err := q.db.Query(ctx, getUserRefreshTokens, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserRefreshTokensRow
+	for rows.Next() {
+		var i GetUserRefreshTokensRow
+		if err := rows.Scan(&i.ID, &i.RefreshToken); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUsersByRole = `-- name: GetUsersByRole :many
-SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin FROM users
+SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason FROM users
 WHERE role = $1 AND deleted_at IS NULL
 ORDER BY id DESC
 LIMIT COALESCE($3::int, 10) 
@@ -184,7 +238,7 @@ OFFSET $2
 `
 
 type GetUsersByRoleParams struct {
-	Role       Roles       `json:"role"`
+	Role       string      `json:"role"`
 	Offset     int32       `json:"offset"`
 	LimitParam pgtype.Int4 `json:"limit_param"`
 }
@@ -212,6 +266,10 @@ func (q *Queries) GetUsersByRole(ctx context.Context, arg GetUsersByRoleParams)
 			&i.PictureUrl,
 			&i.RefreshToken,
 			&i.Origin,
+			&i.EmailVerifiedAt,
+			&i.SuspendedAt,
+			&i.SuspendedUntil,
+			&i.SuspensionReason,
 		); err != nil {
 			return nil, err
 		}
@@ -224,7 +282,7 @@ func (q *Queries) GetUsersByRole(ctx context.Context, arg GetUsersByRoleParams)
 }
 
 const getUsersLikeUsername = `-- name: GetUsersLikeUsername :many
-SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin FROM users
+SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason FROM users
 WHERE username ILIKE $1
 ORDER BY id DESC
 LIMIT COALESCE($3::int, 10) 
@@ -260,6 +318,10 @@ func (q *Queries) GetUsersLikeUsername(ctx context.Context, arg GetUsersLikeUser
 			&i.PictureUrl,
 			&i.RefreshToken,
 			&i.Origin,
+			&i.EmailVerifiedAt,
+			&i.SuspendedAt,
+			&i.SuspendedUntil,
+			&i.SuspensionReason,
 		); err != nil {
 			return nil, err
 		}
@@ -272,7 +334,7 @@ func (q *Queries) GetUsersLikeUsername(ctx context.Context, arg GetUsersLikeUser
 }
 
 const getuserByEmail = `-- name: GetuserByEmail :one
-SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin FROM users 
+SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason FROM users 
 WHERE (email = $1 OR $1 = '')
 AND deleted_at IS NULL
 LIMIT 1
@@ -295,12 +357,16 @@ func (q *Queries) GetuserByEmail(ctx context.Context, email string) (User, error
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
 const getuserByEmailOrUsername = `-- name: GetuserByEmailOrUsername :one
-SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin FROM users 
+SELECT id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason FROM users 
 WHERE (email = $1 OR $1 = '')
 AND (username = $2 OR $2 = '')
 AND deleted_at IS NULL
@@ -329,15 +395,90 @@ func (q *Queries) GetuserByEmailOrUsername(ctx context.Context, arg GetuserByEma
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(), suspended_until = $2, suspension_reason = $3, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
+`
+
+type SuspendUserParams struct {
+	ID               int64              `json:"id"`
+	SuspendedUntil   pgtype.Timestamptz `json:"suspended_until"`
+	SuspensionReason pgtype.Text        `json:"suspension_reason"`
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, suspendUser, arg.ID, arg.SuspendedUntil, arg.SuspensionReason)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Email,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.FirstName,
+		&i.LastName,
+		&i.PictureUrl,
+		&i.RefreshToken,
+		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRow(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Email,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.FirstName,
+		&i.LastName,
+		&i.PictureUrl,
+		&i.RefreshToken,
+		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET updated_at = NOW(), email = $1, username = $2, password_hash = $3
+SET updated_at = NOW(), email = $1, username = $2, password_hash = $3,
+    email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
 WHERE id = $4 AND deleted_at IS NULL
-RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin
+RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
 `
 
 type UpdateUserParams struct {
@@ -369,6 +510,10 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
@@ -377,7 +522,7 @@ const updateUserPassword = `-- name: UpdateUserPassword :one
 UPDATE users
 SET password_hash = $1, updated_at = NOW()
 WHERE id = $2 AND deleted_at IS NULL
-RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin
+RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
 `
 
 type UpdateUserPasswordParams struct {
@@ -402,20 +547,44 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
+const updateUserRefreshToken = `-- name: UpdateUserRefreshToken :execrows
+UPDATE users
+SET refresh_token = $1
+WHERE id = $2 AND refresh_token = $3
+`
+
+type UpdateUserRefreshTokenParams struct {
+	NewRefreshToken pgtype.Text `json:"new_refresh_token"`
+	ID              int64       `json:"id"`
+	OldRefreshToken pgtype.Text `json:"old_refresh_token"`
+}
+
+func (q *Queries) UpdateUserRefreshToken(ctx context.Context, arg UpdateUserRefreshTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserRefreshToken, arg.NewRefreshToken, arg.ID, arg.OldRefreshToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateUserRole = `-- name: UpdateUserRole :one
 UPDATE users
 SET role = $1, updated_at = NOW()
 WHERE id = $2 AND deleted_at IS NULL
-RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin
+RETURNING id, created_at, updated_at, deleted_at, email, username, password_hash, role, first_name, last_name, picture_url, refresh_token, origin, email_verified_at, suspended_at, suspended_until, suspension_reason
 `
 
 type UpdateUserRoleParams struct {
-	Role Roles `json:"role"`
-	ID   int64 `json:"id"`
+	Role string `json:"role"`
+	ID   int64  `json:"id"`
 }
 
 func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
@@ -435,6 +604,29 @@ func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams)
 		&i.PictureUrl,
 		&i.RefreshToken,
 		&i.Origin,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND deleted_at IS NULL
+`
+
+type VerifyUserEmailParams struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyUserEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
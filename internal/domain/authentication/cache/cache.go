@@ -2,12 +2,131 @@ package cache
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/izzanzahrial/skeleton/internal/model"
 	"github.com/izzanzahrial/skeleton/pkg/token"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenReused   = errors.New("token has already been used")
+	// ErrSessionRevoked is returned when a session is created for a refresh token family that was revoked
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+const (
+	refreshTokenPrefix  = "refresh:"
+	refreshFamilyPrefix = "refresh_family:"
+	// sessionPrefix holds the device details of a refresh token family, the family is the session id
+	sessionPrefix = "session:"
+	// refreshUserPrefix holds the set of refresh token families of a user
+	refreshUserPrefix   = "refresh_user:"
+	denylistPrefix      = "denylist:"
+	revokedBeforePrefix = "revoked_before:"
+	oauthStatePrefix    = "oauth_state:"
+	loginFailurePrefix  = "login_failures:"
+	loginBlockPrefix    = "login_block:"
+	mfaChallengePrefix  = "mfa_challenge:"
+	totpUsedPrefix      = "totp_used:"
+	passwordResetPrefix = "password_reset:"
+	// passwordResetSentPrefix counts the password reset emails sent to an address
+	passwordResetSentPrefix = "password_reset_sent:"
+	verifyEmailPrefix       = "verify_email:"
+	// verifyEmailSentPrefix counts the verification emails sent to an address
+	verifyEmailSentPrefix = "verify_email_sent:"
+	magicLinkPrefix       = "magic_link:"
+	// magicLinkSentPrefix counts the magic links sent to an address
+	magicLinkSentPrefix = "magic_link_sent:"
+	// webauthnChallengePrefix holds the user of a pending passkey registration or login, by ceremony and challenge
+	webauthnChallengePrefix = "webauthn_challenge:"
+	// authorizationCodePrefix holds the grant of an oauth authorization code until the client exchanges it
+	authorizationCodePrefix = "authorization_code:"
+	// suspendedPrefix mirrors the suspension of a user, so a token can be rejected without reading the user
+	suspendedPrefix = "suspended:"
+)
+
+// OAuthState is what has to be remembered between redirecting the user to a provider and the callback
+type OAuthState struct {
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirect_url"`
+	// LinkUserID is the logged in user the identity is linked to, it's empty for a login
+	LinkUserID int64 `json:"link_user_id,omitempty"`
+	// BindingHash is the hash of the binding kept by the browser that started the flow
+	BindingHash []byte `json:"binding_hash"`
+}
+
+// EmailVerification is the user and the email address a verification token was sent to,
+// the token can't verify the address the user changed to afterwards
+type EmailVerification struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// MagicLink is the user and the email address a magic link was sent to,
+// the link can't log in once the user changed to another address
+type MagicLink struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// AuthorizationCode is what the user granted the oauth client, the client gets it back by exchanging the code
+type AuthorizationCode struct {
+	ClientID    string   `json:"client_id"`
+	UserID      int64    `json:"user_id"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+	// CodeChallenge is the pkce S256 challenge, the client proves it started the authorization with the verifier
+	CodeChallenge string `json:"code_challenge"`
+	Nonce         string `json:"nonce,omitempty"`
+}
+
+// useRefreshToken atomically marks a refresh token as used and returns how many times it has been used,
+// the token key is never recreated once it has expired
+var useRefreshToken = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+local used = redis.call("HINCRBY", KEYS[1], "used", 1)
+return {used, redis.call("HGET", KEYS[1], "user_id"), redis.call("HGET", KEYS[1], "family"), redis.call("HGET", KEYS[1], "session")}
+`)
+
+// createSession stores the session unless its refresh token family was revoked, so a revoked session
+// can't be brought back
+var createSession = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+redis.call("HSET", KEYS[1], unpack(ARGV, 2))
+redis.call("EXPIRE", KEYS[1], ARGV[1])
+return 1
+`)
+
+// touchSession updates when and from where the session was last seen, without recreating a revoked session
+var touchSession = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "last_seen_at", ARGV[1], "ip", ARGV[2])
+return 1
+`)
+
+// countInWindow atomically increments a counter and starts its window on the first increment,
+// a counter left without a window is given one so it can't outlive it
+var countInWindow = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
 type Repository struct {
 	rdb *redis.Client
 }
@@ -23,3 +142,712 @@ func (r *Repository) SetAuthToken(ctx context.Context, token token.Token) error
 
 	return nil
 }
+
+// SetRefreshToken stores the hash of a refresh token together with its owner and family
+func (r *Repository) SetRefreshToken(ctx context.Context, token token.Token) error {
+	key := refreshTokenKey(token.Hash)
+
+	userKey := refreshUserPrefix + strconv.FormatInt(token.User.ID, 10)
+
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		// session marks the tokens whose family has a session, the tokens stored before sessions were recorded don't have it
+		pipe.HSet(ctx, key, "user_id", token.User.ID, "family", token.Family, "used", 0, "session", 1)
+		pipe.Expire(ctx, key, token.Expiry)
+		pipe.SAdd(ctx, userKey, token.Family)
+		pipe.Expire(ctx, userKey, token.Expiry)
+		// the session lives as long as its latest refresh token
+		pipe.Expire(ctx, sessionPrefix+token.Family, token.Expiry)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set refresh token into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// GetRefreshToken returns the refresh token with the given hash without marking it as used,
+// its Expiry is the time left before it expires. It returns ErrTokenReused together with the token
+// when the token was already rotated
+func (r *Repository) GetRefreshToken(ctx context.Context, hash []byte) (token.Token, error) {
+	var valuesCmd *redis.MapStringStringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		valuesCmd = pipe.HGetAll(ctx, refreshTokenKey(hash))
+		ttlCmd = pipe.PTTL(ctx, refreshTokenKey(hash))
+		return nil
+	})
+	if err != nil {
+		return token.Token{}, fmt.Errorf("failed to get refresh token from redis cache: %w", err)
+	}
+
+	values := valuesCmd.Val()
+	if len(values) == 0 {
+		return token.Token{}, ErrTokenNotFound
+	}
+
+	userID, err := strconv.ParseInt(values["user_id"], 10, 64)
+	if err != nil {
+		return token.Token{}, fmt.Errorf("failed to parse refresh token user id: %w", err)
+	}
+
+	tkn := token.Token{
+		User:   &model.User{ID: userID},
+		Hash:   hash,
+		Expiry: ttlCmd.Val(),
+		Family: values["family"],
+	}
+
+	if used, _ := strconv.ParseInt(values["used"], 10, 64); used > 0 {
+		return tkn, ErrTokenReused
+	}
+
+	return tkn, nil
+}
+
+// UseRefreshToken marks the refresh token with the given hash as used.
+// It returns ErrTokenReused together with the token when the token was already used before,
+// so the caller is still able to revoke the whole family
+func (r *Repository) UseRefreshToken(ctx context.Context, hash []byte) (token.Token, error) {
+	result, err := useRefreshToken.Run(ctx, r.rdb, []string{refreshTokenKey(hash)}).Slice()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return token.Token{}, ErrTokenNotFound
+		}
+		return token.Token{}, fmt.Errorf("failed to use refresh token from redis cache: %w", err)
+	}
+
+	used, _ := result[0].(int64)
+	userIDString, _ := result[1].(string)
+	family, _ := result[2].(string)
+	session, _ := result[3].(string)
+
+	userID, err := strconv.ParseInt(userIDString, 10, 64)
+	if err != nil {
+		return token.Token{}, fmt.Errorf("failed to parse refresh token user id: %w", err)
+	}
+
+	tkn := token.Token{
+		User:       &model.User{ID: userID},
+		Hash:       hash,
+		Family:     family,
+		HasSession: session == "1",
+	}
+
+	if used > 1 {
+		return tkn, ErrTokenReused
+	}
+
+	return tkn, nil
+}
+
+// RevokeTokenFamily marks every refresh token of the family as revoked and ends its session,
+// ttl should be at least the lifetime of a refresh token
+func (r *Repository) RevokeTokenFamily(ctx context.Context, family string, ttl time.Duration) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, refreshFamilyPrefix+family, "revoked", ttl)
+		pipe.Del(ctx, sessionPrefix+family)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family in redis cache: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) IsTokenFamilyRevoked(ctx context.Context, family string) (bool, error) {
+	exists, err := r.rdb.Exists(ctx, refreshFamilyPrefix+family).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token family in redis cache: %w", err)
+	}
+
+	return exists > 0, nil
+}
+
+// CreateSession stores the device details of a new session, it expires together with the refresh token family.
+// It returns ErrSessionRevoked when the family was revoked
+func (r *Repository) CreateSession(ctx context.Context, session model.Session, ttl time.Duration) error {
+	keys := []string{sessionPrefix + session.ID, refreshFamilyPrefix + session.ID}
+	created, err := createSession.Run(ctx, r.rdb, keys,
+		int64(ttl.Seconds()),
+		"user_id", session.UserID,
+		"user_agent", session.UserAgent,
+		"ip", session.IP,
+		"created_at", session.CreatedAt.Unix(),
+		"last_seen_at", session.LastSeenAt.Unix(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to set session into redis cache: %w", err)
+	}
+	if created == 0 {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func (r *Repository) GetSession(ctx context.Context, id string) (model.Session, error) {
+	values, err := r.rdb.HGetAll(ctx, sessionPrefix+id).Result()
+	if err != nil {
+		return model.Session{}, fmt.Errorf("failed to get session from redis cache: %w", err)
+	}
+	if len(values) == 0 {
+		return model.Session{}, ErrTokenNotFound
+	}
+
+	return parseSession(id, values)
+}
+
+// GetUserSessions returns the sessions of the user that are still active
+func (r *Repository) GetUserSessions(ctx context.Context, userID int64) ([]model.Session, error) {
+	userKey := refreshUserPrefix + strconv.FormatInt(userID, 10)
+
+	families, err := r.rdb.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions from redis cache: %w", err)
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(families))
+	_, err = r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, family := range families {
+			cmds[i] = pipe.HGetAll(ctx, sessionPrefix+family)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions from redis cache: %w", err)
+	}
+
+	sessions := make([]model.Session, 0, len(families))
+	var ended []any
+	for i, cmd := range cmds {
+		// revoked or expired sessions, and logins from before sessions were recorded
+		if len(cmd.Val()) == 0 {
+			ended = append(ended, families[i])
+			continue
+		}
+
+		session, err := parseSession(families[i], cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if len(ended) > 0 {
+		if err := r.rdb.SRem(ctx, userKey, ended...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove ended sessions from redis cache: %w", err)
+		}
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records the last time and ip the session was used from,
+// it returns false when the session doesn't exist anymore
+func (r *Repository) TouchSession(ctx context.Context, id, ip string, at time.Time) (bool, error) {
+	touched, err := touchSession.Run(ctx, r.rdb, []string{sessionPrefix + id}, at.Unix(), ip).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to touch session in redis cache: %w", err)
+	}
+
+	return touched == 1, nil
+}
+
+// DenyAccessToken stores the jti of an access token until the token expires by itself
+func (r *Repository) DenyAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := r.rdb.Set(ctx, denylistPrefix+jti, "revoked", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to deny access token in redis cache: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	exists, err := r.rdb.Exists(ctx, denylistPrefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check access token in redis cache: %w", err)
+	}
+
+	return exists > 0, nil
+}
+
+// RevokeUserTokens revokes every refresh token family of the user,
+// and every access token that was issued to the user at or before the given time
+func (r *Repository) RevokeUserTokens(ctx context.Context, userID int64, at time.Time, ttl time.Duration) error {
+	userKey := refreshUserPrefix + strconv.FormatInt(userID, 10)
+
+	families, err := r.rdb.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get user token families from redis cache: %w", err)
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, family := range families {
+			pipe.Set(ctx, refreshFamilyPrefix+family, "revoked", ttl)
+			pipe.Del(ctx, sessionPrefix+family)
+		}
+		pipe.Del(ctx, userKey)
+		pipe.Set(ctx, revokedBeforePrefix+strconv.FormatInt(userID, 10), at.UnixMilli(), ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens in redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// legacyRevokedBeforeLimit tells the revocation times stored in seconds apart from the ones in milliseconds,
+// no revocation in seconds reaches it before the year 5138
+const legacyRevokedBeforeLimit = 1e11
+
+// TokensRevokedBefore returns the time at or before which every token of the user is revoked,
+// it returns the zero time when the tokens of the user were never revoked
+func (r *Repository) TokensRevokedBefore(ctx context.Context, userID int64) (time.Time, error) {
+	unixMilli, err := r.rdb.Get(ctx, revokedBeforePrefix+strconv.FormatInt(userID, 10)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get user token revocation from redis cache: %w", err)
+	}
+
+	// revocations stored before the millisecond precision are in seconds, they expire with the refresh tokens
+	if unixMilli < legacyRevokedBeforeLimit {
+		return time.Unix(unixMilli, 0), nil
+	}
+
+	return time.UnixMilli(unixMilli), nil
+}
+
+// SetUserSuspension stores the suspension of the user until it ends, a ban is kept until it's deleted
+func (r *Repository) SetUserSuspension(ctx context.Context, userID int64, suspension model.Suspension) error {
+	data, err := json.Marshal(suspension)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user suspension: %w", err)
+	}
+
+	var ttl time.Duration
+	if !suspension.Until.IsZero() {
+		ttl = time.Until(suspension.Until)
+		if ttl <= 0 {
+			return nil
+		}
+	}
+
+	if err := r.rdb.Set(ctx, suspendedPrefix+strconv.FormatInt(userID, 10), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set user suspension into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// UserSuspension returns the suspension of the user, it's false when the user isn't suspended
+func (r *Repository) UserSuspension(ctx context.Context, userID int64) (model.Suspension, bool, error) {
+	data, err := r.rdb.Get(ctx, suspendedPrefix+strconv.FormatInt(userID, 10)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return model.Suspension{}, false, nil
+		}
+		return model.Suspension{}, false, fmt.Errorf("failed to get user suspension from redis cache: %w", err)
+	}
+
+	var suspension model.Suspension
+	if err := json.Unmarshal(data, &suspension); err != nil {
+		return model.Suspension{}, false, fmt.Errorf("failed to unmarshal user suspension: %w", err)
+	}
+
+	return suspension, true, nil
+}
+
+// DeleteUserSuspension lifts the suspension of the user before it ends
+func (r *Repository) DeleteUserSuspension(ctx context.Context, userID int64) error {
+	if err := r.rdb.Del(ctx, suspendedPrefix+strconv.FormatInt(userID, 10)).Err(); err != nil {
+		return fmt.Errorf("failed to delete user suspension from redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// SetOAuthState stores the state of an oauth flow, the state is only valid for the given provider
+func (r *Repository) SetOAuthState(ctx context.Context, provider, state string, value OAuthState, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal oauth state: %w", err)
+	}
+
+	if err := r.rdb.Set(ctx, oauthStatePrefix+provider+":"+state, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set oauth state into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeOAuthState returns and deletes the state of an oauth flow, so a state can only be used once
+func (r *Repository) ConsumeOAuthState(ctx context.Context, provider, state string) (OAuthState, error) {
+	data, err := r.rdb.GetDel(ctx, oauthStatePrefix+provider+":"+state).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return OAuthState{}, ErrTokenNotFound
+		}
+		return OAuthState{}, fmt.Errorf("failed to get oauth state from redis cache: %w", err)
+	}
+
+	var value OAuthState
+	if err := json.Unmarshal(data, &value); err != nil {
+		return OAuthState{}, fmt.Errorf("failed to unmarshal oauth state: %w", err)
+	}
+
+	return value, nil
+}
+
+// RecordLoginFailure counts a failed login for the key and returns the number of failures inside the window,
+// the window starts at the first failure
+func (r *Repository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	failures, err := countInWindow.Run(ctx, r.rdb, []string{loginFailurePrefix + key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login failure into redis cache: %w", err)
+	}
+
+	return failures, nil
+}
+
+// BlockLogin rejects every login for the key until ttl has passed
+func (r *Repository) BlockLogin(ctx context.Context, key string, ttl time.Duration) error {
+	if err := r.rdb.Set(ctx, loginBlockPrefix+key, "blocked", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to block login in redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// LoginBlockedFor returns how long logins for the key are still blocked, it's zero when logins are allowed
+func (r *Repository) LoginBlockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.rdb.PTTL(ctx, loginBlockPrefix+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get login block from redis cache: %w", err)
+	}
+
+	// a negative ttl means the key doesn't exist or doesn't expire
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// ResetLoginFailures forgets the failures and lifts the block of the keys
+func (r *Repository) ResetLoginFailures(ctx context.Context, keys ...string) error {
+	var redisKeys []string
+	for _, key := range keys {
+		redisKeys = append(redisKeys, loginFailurePrefix+key, loginBlockPrefix+key)
+	}
+
+	if err := r.rdb.Del(ctx, redisKeys...).Err(); err != nil {
+		return fmt.Errorf("failed to reset login failures in redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// SetMFAChallenge stores the hash of the challenge token given after the password of a user with mfa was checked
+func (r *Repository) SetMFAChallenge(ctx context.Context, token token.Token) error {
+	key := mfaChallengePrefix + hex.EncodeToString(token.Hash)
+
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", token.User.ID, "failures", 0)
+		pipe.Expire(ctx, key, token.Expiry)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set mfa challenge into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// GetMFAChallenge returns the user id of the challenge
+func (r *Repository) GetMFAChallenge(ctx context.Context, hash []byte) (int64, error) {
+	userID, err := r.rdb.HGet(ctx, mfaChallengePrefix+hex.EncodeToString(hash), "user_id").Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrTokenNotFound
+		}
+		return 0, fmt.Errorf("failed to get mfa challenge from redis cache: %w", err)
+	}
+
+	return userID, nil
+}
+
+// RecordMFAChallengeFailure counts a wrong code for the challenge and returns the number of wrong codes so far
+func (r *Repository) RecordMFAChallengeFailure(ctx context.Context, hash []byte) (int64, error) {
+	failures, err := r.rdb.HIncrBy(ctx, mfaChallengePrefix+hex.EncodeToString(hash), "failures", 1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record mfa challenge failure into redis cache: %w", err)
+	}
+
+	return failures, nil
+}
+
+// DeleteMFAChallenge deletes the challenge, it returns false when the challenge was already deleted,
+// so only one request is able to exchange a challenge
+func (r *Repository) DeleteMFAChallenge(ctx context.Context, hash []byte) (bool, error) {
+	deleted, err := r.rdb.Del(ctx, mfaChallengePrefix+hex.EncodeToString(hash)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete mfa challenge from redis cache: %w", err)
+	}
+
+	return deleted > 0, nil
+}
+
+// UseTOTPStep marks the time step of a totp code as used by the user,
+// it returns false when a code of the same step was already used
+func (r *Repository) UseTOTPStep(ctx context.Context, userID int64, step int64, ttl time.Duration) (bool, error) {
+	key := totpUsedPrefix + strconv.FormatInt(userID, 10) + ":" + strconv.FormatInt(step, 10)
+
+	ok, err := r.rdb.SetNX(ctx, key, "used", ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step in redis cache: %w", err)
+	}
+
+	return ok, nil
+}
+
+// SetPasswordResetToken stores the hash of a password reset token and the user it resets
+func (r *Repository) SetPasswordResetToken(ctx context.Context, token token.Token) error {
+	if err := r.rdb.Set(ctx, passwordResetPrefix+hex.EncodeToString(token.Hash), token.User.ID, token.Expiry).Err(); err != nil {
+		return fmt.Errorf("failed to set password reset token into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// GetPasswordResetToken returns the user id of the token without deleting it
+func (r *Repository) GetPasswordResetToken(ctx context.Context, hash []byte) (int64, error) {
+	userID, err := r.rdb.Get(ctx, passwordResetPrefix+hex.EncodeToString(hash)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrTokenNotFound
+		}
+		return 0, fmt.Errorf("failed to get password reset token from redis cache: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ConsumePasswordResetToken returns the user id of the token and deletes it, so a token can only be used once
+func (r *Repository) ConsumePasswordResetToken(ctx context.Context, hash []byte) (int64, error) {
+	userID, err := r.rdb.GetDel(ctx, passwordResetPrefix+hex.EncodeToString(hash)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrTokenNotFound
+		}
+		return 0, fmt.Errorf("failed to get password reset token from redis cache: %w", err)
+	}
+
+	return userID, nil
+}
+
+// SetEmailVerificationToken stores the hash of an email verification token
+func (r *Repository) SetEmailVerificationToken(ctx context.Context, token token.Token, email string) error {
+	data, err := json.Marshal(EmailVerification{UserID: token.User.ID, Email: email})
+	if err != nil {
+		return fmt.Errorf("failed to marshal email verification: %w", err)
+	}
+
+	if err := r.rdb.Set(ctx, verifyEmailPrefix+hex.EncodeToString(token.Hash), data, token.Expiry).Err(); err != nil {
+		return fmt.Errorf("failed to set email verification token into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeEmailVerificationToken returns and deletes the email verification of the token, so a token can only be used once
+func (r *Repository) ConsumeEmailVerificationToken(ctx context.Context, hash []byte) (EmailVerification, error) {
+	data, err := r.rdb.GetDel(ctx, verifyEmailPrefix+hex.EncodeToString(hash)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return EmailVerification{}, ErrTokenNotFound
+		}
+		return EmailVerification{}, fmt.Errorf("failed to get email verification token from redis cache: %w", err)
+	}
+
+	var value EmailVerification
+	if err := json.Unmarshal(data, &value); err != nil {
+		return EmailVerification{}, fmt.Errorf("failed to unmarshal email verification: %w", err)
+	}
+
+	return value, nil
+}
+
+// RecordPasswordResetEmail counts a password reset email sent to the address, it returns the number of emails
+// inside the window and how long until the window ends
+func (r *Repository) RecordPasswordResetEmail(ctx context.Context, email string, window time.Duration) (int64, time.Duration, error) {
+	count, ttl, err := r.recordEmail(ctx, passwordResetSentPrefix+email, window)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to record password reset email into redis cache: %w", err)
+	}
+
+	return count, ttl, nil
+}
+
+// RecordVerificationEmail counts a verification email sent to the address, it returns the number of emails
+// inside the window and how long until the window ends
+func (r *Repository) RecordVerificationEmail(ctx context.Context, email string, window time.Duration) (int64, time.Duration, error) {
+	count, ttl, err := r.recordEmail(ctx, verifyEmailSentPrefix+email, window)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to record verification email into redis cache: %w", err)
+	}
+
+	return count, ttl, nil
+}
+
+// SetMagicLinkToken stores the hash of a magic link token, the user and the email address it was sent to
+func (r *Repository) SetMagicLinkToken(ctx context.Context, token token.Token, email string) error {
+	data, err := json.Marshal(MagicLink{UserID: token.User.ID, Email: email})
+	if err != nil {
+		return fmt.Errorf("failed to marshal magic link: %w", err)
+	}
+
+	if err := r.rdb.Set(ctx, magicLinkPrefix+hex.EncodeToString(token.Hash), data, token.Expiry).Err(); err != nil {
+		return fmt.Errorf("failed to set magic link token into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeMagicLinkToken returns the user and email of the token and deletes it, so a link can only be used once
+func (r *Repository) ConsumeMagicLinkToken(ctx context.Context, hash []byte) (MagicLink, error) {
+	data, err := r.rdb.GetDel(ctx, magicLinkPrefix+hex.EncodeToString(hash)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return MagicLink{}, ErrTokenNotFound
+		}
+		return MagicLink{}, fmt.Errorf("failed to get magic link token from redis cache: %w", err)
+	}
+
+	var value MagicLink
+	if err := json.Unmarshal(data, &value); err != nil {
+		return MagicLink{}, fmt.Errorf("failed to unmarshal magic link: %w", err)
+	}
+
+	return value, nil
+}
+
+// RecordMagicLinkEmail counts a magic link sent to the address, it returns the number of emails
+// inside the window and how long until the window ends
+func (r *Repository) RecordMagicLinkEmail(ctx context.Context, email string, window time.Duration) (int64, time.Duration, error) {
+	count, ttl, err := r.recordEmail(ctx, magicLinkSentPrefix+email, window)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to record magic link email into redis cache: %w", err)
+	}
+
+	return count, ttl, nil
+}
+
+// recordEmail counts an email sent under the key, the window starts at the first email
+func (r *Repository) recordEmail(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	// the count and its window are set in one script, so a counter can't be left without a ttl
+	count, err := countInWindow.Run(ctx, r.rdb, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ttl, err := r.rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the window ended between the two calls
+	if ttl <= 0 {
+		ttl = window
+	}
+
+	return count, ttl, nil
+}
+
+// SetWebAuthnChallenge stores the challenge of a passkey ceremony and the user it was started for,
+// the user is 0 for a login since the passkey tells who the user is
+func (r *Repository) SetWebAuthnChallenge(ctx context.Context, ceremony string, challenge []byte, userID int64, ttl time.Duration) error {
+	key := webauthnChallengePrefix + ceremony + ":" + hex.EncodeToString(challenge)
+	if err := r.rdb.Set(ctx, key, userID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set webauthn challenge into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeWebAuthnChallenge returns the user of the challenge and deletes it, so a challenge can only be used once
+func (r *Repository) ConsumeWebAuthnChallenge(ctx context.Context, ceremony string, challenge []byte) (int64, error) {
+	key := webauthnChallengePrefix + ceremony + ":" + hex.EncodeToString(challenge)
+	userID, err := r.rdb.GetDel(ctx, key).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrTokenNotFound
+		}
+		return 0, fmt.Errorf("failed to get webauthn challenge from redis cache: %w", err)
+	}
+
+	return userID, nil
+}
+
+// SetAuthorizationCode stores the hash of an oauth authorization code and the grant it stands for
+func (r *Repository) SetAuthorizationCode(ctx context.Context, token token.Token, code AuthorizationCode) error {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return fmt.Errorf("failed to marshal authorization code: %w", err)
+	}
+
+	if err := r.rdb.Set(ctx, authorizationCodePrefix+hex.EncodeToString(token.Hash), data, token.Expiry).Err(); err != nil {
+		return fmt.Errorf("failed to set authorization code into redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeAuthorizationCode returns the grant of the code and deletes it, so a code can only be exchanged once
+func (r *Repository) ConsumeAuthorizationCode(ctx context.Context, hash []byte) (AuthorizationCode, error) {
+	data, err := r.rdb.GetDel(ctx, authorizationCodePrefix+hex.EncodeToString(hash)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return AuthorizationCode{}, ErrTokenNotFound
+		}
+		return AuthorizationCode{}, fmt.Errorf("failed to get authorization code from redis cache: %w", err)
+	}
+
+	var value AuthorizationCode
+	if err := json.Unmarshal(data, &value); err != nil {
+		return AuthorizationCode{}, fmt.Errorf("failed to unmarshal authorization code: %w", err)
+	}
+
+	return value, nil
+}
+
+func parseSession(id string, values map[string]string) (model.Session, error) {
+	userID, err := strconv.ParseInt(values["user_id"], 10, 64)
+	if err != nil {
+		return model.Session{}, fmt.Errorf("failed to parse session user id: %w", err)
+	}
+
+	createdAt, _ := strconv.ParseInt(values["created_at"], 10, 64)
+	lastSeenAt, _ := strconv.ParseInt(values["last_seen_at"], 10, 64)
+
+	return model.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  values["user_agent"],
+		IP:         values["ip"],
+		CreatedAt:  time.Unix(createdAt, 0),
+		LastSeenAt: time.Unix(lastSeenAt, 0),
+	}, nil
+}
+
+func refreshTokenKey(hash []byte) string {
+	return refreshTokenPrefix + hex.EncodeToString(hash)
+}
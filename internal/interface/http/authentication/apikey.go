@@ -0,0 +1,89 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/labstack/echo/v4"
+)
+
+// CreateAPIKey creates an api key for the logged in user, the key is only shown in this response
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request CreateAPIKeyReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	ttl := time.Duration(request.ExpiresInDays) * 24 * time.Hour
+	apiKey, key, err := h.service.CreateAPIKey(ctx, claims.UserID, request.Name, request.Scopes, ttl)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidScope) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{"api_key": apiKey, "key": key})
+}
+
+func (h *Handler) GetAPIKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	apiKeys, err := h.service.GetAPIKeys(ctx, claims.UserID)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, apiKeys)
+}
+
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request RevokeAPIKeyReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.RevokeAPIKey(ctx, claims.UserID, int64(request.ID)); err != nil {
+		if errors.Is(err, authservice.ErrAPIKeyNotFound) {
+			return echo.ErrNotFound
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
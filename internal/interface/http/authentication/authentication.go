@@ -2,35 +2,83 @@ package authentication
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
 	"time"
 
-	"github.com/izzanzahrial/skeleton/internal/interface/http/auth0"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/interface/http/oauth"
 	"github.com/izzanzahrial/skeleton/internal/model"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
 	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/izzanzahrial/skeleton/pkg/webauthn"
 	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 type authService interface {
-	GetuserByEmailOrUsername(ctx context.Context, email, username, password string) (model.User, error)
-	CreateOrCheckGoogleUser(ctx context.Context, user model.User) (model.User, error)
+	GetuserByEmailOrUsername(ctx context.Context, email, username, password, clientIP string) (model.User, error)
+	UnlockLogin(ctx context.Context, userID int64, clientIP string) error
+	LoginOAuthUser(ctx context.Context, identity model.UserIdentity, user model.User) (model.User, error)
+	LinkOAuthIdentity(ctx context.Context, userID int64, identity model.UserIdentity) (model.UserIdentity, error)
+	GetUserIdentities(ctx context.Context, userID int64) ([]model.UserIdentity, error)
+	UnlinkOAuthIdentity(ctx context.Context, userID int64, provider string) error
+	NewSession(ctx context.Context, userID int64, userAgent, clientIP string) (*token.Token, error)
+	RotateRefreshToken(ctx context.Context, refreshToken, userAgent, clientIP string) (model.User, *token.Token, error)
+	Logout(ctx context.Context, claims *token.JwtCustomClaims, refreshToken string) error
+	RevokeUserSessions(ctx context.Context, userID int64) error
+	NewOAuthState(ctx context.Context, provider, redirectURL string, linkUserID int64) (authservice.OAuthFlow, error)
+	ConsumeOAuthState(ctx context.Context, provider, state, binding string) (cache.OAuthState, error)
+	IsMFAEnabled(ctx context.Context, userID int64) (bool, error)
+	NewMFAChallenge(ctx context.Context, userID int64) (*token.Token, error)
+	VerifyMFAChallenge(ctx context.Context, challenge, code string) (model.User, error)
+	EnrollTOTP(ctx context.Context, userID int64) (authservice.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int64, code string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, resetToken, newPassword string) error
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, ttl time.Duration) (model.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id int64) error
+	GetSessions(ctx context.Context, userID int64, currentSessionID string) ([]model.Session, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, userID int64, currentSessionID string) error
+	StartImpersonation(ctx context.Context, actorID, userID int64) (model.User, error)
+	RecordImpersonation(ctx context.Context, audit model.ImpersonationAudit) error
+	SuspendUser(ctx context.Context, actorID, userID int64, until time.Time, reason string) (model.User, error)
+	UnsuspendUser(ctx context.Context, actorID, userID int64) (model.User, error)
+	SendMagicLink(ctx context.Context, email string) error
+	LoginMagicLink(ctx context.Context, magicLinkToken string) (model.User, error)
+	BeginPasskeyRegistration(ctx context.Context, userID int64) (webauthn.CreationOptions, error)
+	FinishPasskeyRegistration(ctx context.Context, userID int64, name string, resp webauthn.RegistrationResponse) (model.Passkey, error)
+	GetPasskeys(ctx context.Context, userID int64) ([]model.Passkey, error)
+	DeletePasskey(ctx context.Context, userID int64, id string) error
+	BeginPasskeyLogin(ctx context.Context) (webauthn.RequestOptions, error)
+	LoginPasskey(ctx context.Context, resp webauthn.AssertionResponse) (model.User, error)
 }
 
 type Handler struct {
-	service authService
-	auht0   *auth0.Authenticator
-	slog    *slog.Logger
+	service   authService
+	providers *oauth.Registry
+	keys      *token.KeyManager
+	slog      *slog.Logger
+	// cookies is set in the cookie session mode
+	cookies *CookieConfig
 }
 
-func NewHandler(service authService, auth0 *auth0.Authenticator, slog *slog.Logger) *Handler {
-	return &Handler{service: service, auht0: auth0, slog: slog}
+func NewHandler(service authService, providers *oauth.Registry, keys *token.KeyManager, slog *slog.Logger, cfgs ...HandlerConfig) *Handler {
+	h := &Handler{service: service, providers: providers, keys: keys, slog: slog}
+	for _, cfg := range cfgs {
+		cfg(h)
+	}
+
+	return h
 }
 
 func (h *Handler) Login(c echo.Context) error {
@@ -51,11 +99,50 @@ func (h *Handler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err)
 	}
 
-	user, err := h.service.GetuserByEmailOrUsername(ctx, request.Email, request.Username, request.Password)
+	user, err := h.service.GetuserByEmailOrUsername(ctx, request.Email, request.Username, request.Password, c.RealIP())
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		var lockedErr *authservice.LoginLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			loginFailureCounter.Add(ctx, 1, loginFailureLocked)
+			return tooManyAttempts(c, lockedErr)
+		case errors.Is(err, pgx.ErrNoRows):
+			loginFailureCounter.Add(ctx, 1, loginFailureNotFound)
 			return c.JSON(http.StatusNotFound, errors.New("user not found"))
+		case errors.Is(err, authservice.ErrInvalidCredentials):
+			loginFailureCounter.Add(ctx, 1, loginFailureInvalidPassword)
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		case errors.Is(err, authservice.ErrEmailNotVerified):
+			loginFailureCounter.Add(ctx, 1, loginFailureEmailNotVerified)
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case errors.As(err, new(*authservice.SuspendedError)):
+			loginFailureCounter.Add(ctx, 1, loginFailureSuspended)
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.ErrInternalServerError
 		}
+	}
+
+	// the jwt is only issued at /login/mfa once the second factor is checked
+	mfaEnabled, err := h.service.IsMFAEnabled(ctx, user.ID)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+	if mfaEnabled {
+		challenge, err := h.service.NewMFAChallenge(ctx, user.ID)
+		if err != nil {
+			return echo.ErrInternalServerError
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"mfa_required": true,
+			"mfa_token":    challenge.PlainText,
+			"expires_in":   int(challenge.Expiry.Seconds()),
+		})
+	}
+
+	refreshToken, err := h.service.NewSession(ctx, user.ID, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
 		return echo.ErrInternalServerError
 	}
 
@@ -64,13 +151,13 @@ func (h *Handler) Login(c echo.Context) error {
 		_, span := tracer.Start(ctx, "auth.Login.JWT")
 		defer span.End()
 
-		return token.NewJWT(user.ID, model.Roles(user.Role))
+		return h.keys.NewJWT(user.ID, model.Roles(user.Role), refreshToken.Family)
 	}(ctx)
 	if err != nil {
 		return echo.ErrInternalServerError
 	}
 
-	// tkn, err := token.NewJWT(user.ID, model.Roles(user.Role))
+	// tkn, err := h.keys.NewJWT(user.ID, model.Roles(user.Role))
 	// if err != nil {
 	// 	h.slog.Error("failed to create token", slog.String("error", err.Error()))
 	// 	return echo.ErrInternalServerError
@@ -78,133 +165,351 @@ func (h *Handler) Login(c echo.Context) error {
 
 	duration := time.Since(start)
 	loginDuration.Record(ctx, duration.Seconds())
-	return c.JSON(http.StatusFound, echo.Map{"user": user, "token": tkn})
+	return c.JSON(http.StatusFound, h.withTokens(c, echo.Map{"user": user}, tkn, refreshToken))
 }
 
-var googleOauthConfig = &oauth2.Config{
-	ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-	ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-	RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-	// Learn more: https://developers.google.com/identity/protocols/oauth2/scopes
-	Scopes:   []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
-	Endpoint: google.Endpoint,
+// LoginOAuth redirects the user to the oauth provider given in the path
+func (h *Handler) LoginOAuth(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	provider, err := h.providers.Get(c.Param("provider"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	// the state is scoped to the provider, so it can't be used for another provider callback
+	flow, err := h.service.NewOAuthState(ctx, provider.Name, c.QueryParam("redirect_url"), 0)
+	if err != nil {
+		if errors.Is(err, authservice.ErrRedirectNotAllowed) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	c.SetCookie(h.newOAuthBindingCookie(c, flow.Binding, authservice.OAuthStateTTL))
+
+	return c.Redirect(http.StatusTemporaryRedirect, provider.AuthCodeURL(flow.State, oauth2.S256ChallengeOption(flow.Verifier)))
 }
 
-func (h *Handler) LoginGoogleOAuth(c echo.Context) error {
-	// TODO: generate state
-	state := "temp"
-	url := googleOauthConfig.AuthCodeURL(state)
-	// Add access_type=offline to get refresh token when user first tries to login
-	// refrence: https://stackoverflow.com/questions/10827920/not-receiving-google-oauth-refresh-token
-	return c.Redirect(http.StatusTemporaryRedirect, url+"&access_type=offline")
+// LinkOAuth starts an oauth flow that links the provider identity to the logged in user,
+// the authorization url is returned instead of redirected to since the request needs the jwt
+func (h *Handler) LinkOAuth(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	provider, err := h.providers.Get(c.Param("provider"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	flow, err := h.service.NewOAuthState(ctx, provider.Name, c.QueryParam("redirect_url"), claims.UserID)
+	if err != nil {
+		if errors.Is(err, authservice.ErrRedirectNotAllowed) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	// the browser has to keep the binding cookie of this response, the callback is refused without it
+	c.SetCookie(h.newOAuthBindingCookie(c, flow.Binding, authservice.OAuthStateTTL))
+
+	return c.JSON(http.StatusOK, echo.Map{"url": provider.AuthCodeURL(flow.State, oauth2.S256ChallengeOption(flow.Verifier))})
 }
 
-func (h *Handler) Callback(c echo.Context) error {
+// CallbackOAuth finishes the oauth login, creates the user when needed and issues our own jwt.
+// When the flow was started by LinkOAuth the identity is linked to the user instead
+func (h *Handler) CallbackOAuth(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	state := c.FormValue("state")
-	// TODO: get the generate state
-	if state != "temp" {
-		return echo.ErrBadGateway
+	provider, err := h.providers.Get(c.Param("provider"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	var binding string
+	if cookie, err := c.Cookie(oauthBindingCookie); err == nil {
+		binding = cookie.Value
 	}
+	c.SetCookie(h.newOAuthBindingCookie(c, "", -1))
 
-	code := c.FormValue("code")
-	tkn, err := googleOauthConfig.Exchange(ctx, code)
+	oauthState, err := h.service.ConsumeOAuthState(ctx, provider.Name, c.QueryParam("state"), binding)
 	if err != nil {
-		h.slog.Error("failed to exchange google oauth token", slog.String("error", err.Error()))
-		return echo.ErrBadGateway
+		if errors.Is(err, authservice.ErrInvalidOAuthState) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.ErrInternalServerError
 	}
 
-	client := googleOauthConfig.Client(ctx, tkn)
-	response, err := client.Get(os.Getenv("GOOGLE_OAUTH_API_URL"))
+	tkn, err := provider.Exchange(ctx, c.QueryParam("code"), oauth2.VerifierOption(oauthState.Verifier))
 	if err != nil {
-		h.slog.Error("failed to get user data from google oauth api", slog.String("error", err.Error()))
+		h.slog.Error("failed to exchange token", slog.String("provider", provider.Name), slog.String("error", err.Error()))
 		return echo.ErrBadGateway
 	}
-	defer response.Body.Close()
 
-	var userInfo map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&userInfo)
+	profile, err := provider.VerifyIDToken(ctx, tkn)
 	if err != nil {
-		h.slog.Error("failed to decode response body", slog.String("error", err.Error()))
-		return echo.ErrInternalServerError
+		h.slog.Error("failed to verify token", slog.String("provider", provider.Name), slog.String("error", err.Error()))
+		return echo.ErrBadGateway
 	}
 
-	user := &model.User{
-		Email:        userInfo["email"].(string),
-		FirstName:    userInfo["given_name"].(string),
-		LastName:     userInfo["name"].(string),
-		PictureUrl:   userInfo["picture"].(string),
+	// the email is still used to link legacy accounts, so an unverified email could be used to take one over
+	if profile.Subject == "" || profile.Email == "" || !profile.EmailVerified {
+		return echo.NewHTTPError(http.StatusForbidden, "account must have a verified email")
+	}
+
+	identity := model.UserIdentity{
+		Provider: provider.Name,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+
+	if oauthState.LinkUserID != 0 {
+		linked, err := h.service.LinkOAuthIdentity(ctx, oauthState.LinkUserID, identity)
+		if err != nil {
+			if errors.Is(err, authservice.ErrIdentityLinked) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			return echo.ErrInternalServerError
+		}
+
+		if oauthState.RedirectURL != "" {
+			return c.Redirect(http.StatusFound, oauthState.RedirectURL)
+		}
+		return c.JSON(http.StatusCreated, linked)
+	}
+
+	user := model.User{
+		Email:        profile.Email,
+		FirstName:    profile.FirstName(),
+		LastName:     profile.LastName(),
+		PictureUrl:   profile.Picture,
 		RefreshToken: tkn.RefreshToken,
+		Origin:       providerOrigin(provider.Name),
+	}
+
+	newUser, err := h.service.LoginOAuthUser(ctx, identity, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrAccountExists):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.As(err, new(*authservice.SuspendedError)):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.ErrUnauthorized
+		default:
+			return echo.ErrInternalServerError
+		}
 	}
 
-	newUser, err := h.service.CreateOrCheckGoogleUser(ctx, *user)
+	refreshToken, err := h.service.NewSession(ctx, newUser.ID, c.Request().UserAgent(), c.RealIP())
 	if err != nil {
 		return echo.ErrInternalServerError
 	}
 
-	jwtToken, err := token.NewJWT(newUser.ID, model.Roles(newUser.Role))
+	jwtToken, err := h.keys.NewJWT(newUser.ID, model.Roles(newUser.Role), refreshToken.Family)
 	if err != nil {
 		h.slog.Error("failed to create token", slog.String("error", err.Error()))
 		return echo.ErrInternalServerError
 	}
 
-	// TODO: should be redirected to somewhere else
-	return c.JSON(http.StatusCreated, echo.Map{"user": user, "token": jwtToken})
+	if oauthState.RedirectURL != "" {
+		if h.cookies != nil {
+			h.withTokens(c, echo.Map{}, jwtToken, refreshToken)
+			return c.Redirect(http.StatusFound, oauthState.RedirectURL)
+		}
+		return redirectWithTokens(c, oauthState.RedirectURL, jwtToken, refreshToken.PlainText)
+	}
+
+	return c.JSON(http.StatusOK, h.withTokens(c, echo.Map{"user": newUser}, jwtToken, refreshToken))
 }
 
+// providerOrigin keeps the dedicated origin of the providers we supported before the registry
+func providerOrigin(provider string) model.Origins {
+	switch provider {
+	case string(model.GoogleOrigin):
+		return model.GoogleOrigin
+	case string(model.Auth0Origin):
+		return model.Auth0Origin
+	default:
+		return model.OIDCOrigin
+	}
+}
+
+// redirectWithTokens sends the user back to the post login redirect url,
+// the tokens are put in the url fragment so they are never sent to a server
+func redirectWithTokens(c echo.Context, redirectURL, jwtToken, refreshToken string) error {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	u.Fragment = url.Values{"token": {jwtToken}, "refresh_token": {refreshToken}}.Encode()
+	return c.Redirect(http.StatusFound, u.String())
+}
+
+// RefreshToken rotates the refresh token and issues a new access token,
+// the refresh token that was sent can't be used again
 func (h *Handler) RefreshToken(c echo.Context) error {
-	// TODO: get refresh token from database
-	refreshToken := c.FormValue("refresh")
+	ctx := c.Request().Context()
 
-	// TODO: add check for different origin
-	// native origin
+	var request RefreshTokenReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if request.RefreshToken == "" {
+		request.RefreshToken = h.refreshTokenFromCookie(c)
+	}
 
-	// google origin
-	newtoken := googleOauthConfig.TokenSource(context.Background(), &oauth2.Token{RefreshToken: refreshToken})
-	token, err := newtoken.Token()
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, refreshToken, err := h.service.RotateRefreshToken(ctx, request.RefreshToken, c.Request().UserAgent(), c.RealIP())
 	if err != nil {
-		h.slog.Error("failed to refresh token", slog.String("error", err.Error()))
-		return echo.ErrBadGateway
+		switch {
+		case errors.Is(err, authservice.ErrInvalidRefreshToken):
+			return echo.ErrUnauthorized
+		case errors.As(err, new(*authservice.SuspendedError)):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
 	}
 
-	// TODO: generate new jwt
-	return c.JSON(http.StatusOK, token)
-}
+	jwtToken, err := h.keys.NewJWT(user.ID, model.Roles(user.Role), refreshToken.Family)
+	if err != nil {
+		h.slog.Error("failed to create token", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
 
-func (h *Handler) LoginAuth0(c echo.Context) error {
-	// TODO: generate state
-	return c.Redirect(http.StatusTemporaryRedirect, h.auht0.AuthCodeURL("temp"))
+	return c.JSON(http.StatusOK, h.withTokens(c, echo.Map{}, jwtToken, refreshToken))
 }
 
-func (h *Handler) CallbackAuth0(c echo.Context) error {
-	// TODO: get the generated state
-	if c.QueryParam("state") != "temp" {
-		return echo.ErrBadGateway
+func (h *Handler) GetIdentities(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
 	}
 
-	token, err := h.auht0.Exchange(context.Background(), c.QueryParam("code"))
+	identities, err := h.service.GetUserIdentities(ctx, claims.UserID)
 	if err != nil {
-		h.slog.Error("failed to exchange token", slog.String("error", err.Error()))
-		return echo.ErrBadGateway
+		return echo.ErrInternalServerError
 	}
 
-	idToken, err := h.auht0.VerifyIDToken(context.Background(), token)
+	return c.JSON(http.StatusOK, identities)
+}
+
+func (h *Handler) UnlinkIdentity(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
 	if err != nil {
-		h.slog.Error("failed to verify token", slog.String("error", err.Error()))
-		return echo.ErrBadGateway
+		return err
 	}
 
-	var profile map[string]interface{}
-	if err := idToken.Claims(&profile); err != nil {
-		h.slog.Error("failed to unmarshal claims into profile", slog.String("error", err.Error()))
-		return echo.ErrBadGateway
+	var request UnlinkIdentityReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.UnlinkOAuthIdentity(ctx, claims.UserID, request.Provider); err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrIdentityNotFound):
+			return echo.ErrNotFound
+		case errors.Is(err, authservice.ErrLastLoginMethod):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
 	}
 
-	// TODO: save the user from profile to database
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Logout revokes the access token used to call it, and the refresh token when it's sent
+func (h *Handler) Logout(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
 
-	// TODO: generate jwt token from user data
+	var request LogoutReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := h.service.Logout(ctx, claims, request.RefreshToken); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	h.clearTokenCookies(c)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeUserSessions logs the user out of every device, it takes effect immediately
+func (h *Handler) RevokeUserSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request RevokeUserSessionsReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.RevokeUserSessions(ctx, int64(request.ID)); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnlockLogin lifts the lockout caused by too many failed logins
+func (h *Handler) UnlockLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request UnlockLoginReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.UnlockLogin(ctx, int64(request.ID), request.IP); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
 
-	// TODO: redirect to somewhere else
-	return c.JSON(http.StatusOK, echo.Map{"token": token, "profile": profile})
+// JWKS exposes the public signing keys so other services can verify our tokens
+func (h *Handler) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.keys.JWKS())
 }
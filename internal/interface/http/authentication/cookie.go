@@ -0,0 +1,126 @@
+package authentication
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/labstack/echo/v4"
+)
+
+// refreshCookiePath keeps the browser from sending the refresh token anywhere else than /refresh,
+// logout doesn't need it since it revokes the session of the access token
+const refreshCookiePath = "/api/v1/refresh"
+
+// oauthBindingCookie ties an oauth flow to the browser that started it, it's only sent to the oauth routes
+const (
+	oauthBindingCookie     = "oauth_binding"
+	oauthBindingCookiePath = "/api/v1/oauth"
+)
+
+// CookieConfig turns on the cookie session mode, the tokens are set as HttpOnly cookies instead of returned
+type CookieConfig struct {
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+type HandlerConfig func(h *Handler)
+
+func WithCookies(cfg CookieConfig) HandlerConfig {
+	return func(h *Handler) {
+		h.cookies = &cfg
+	}
+}
+
+// withTokens adds the tokens to the response body, or sets them as cookies in the cookie session mode
+func (h *Handler) withTokens(c echo.Context, body echo.Map, jwtToken string, refreshToken *token.Token) echo.Map {
+	if h.cookies == nil {
+		body["token"] = jwtToken
+		body["refresh_token"] = refreshToken.PlainText
+		return body
+	}
+
+	c.SetCookie(h.newCookie(middleware.AccessTokenCookie, jwtToken, "/", token.AccessTokenTTL))
+	c.SetCookie(h.newCookie(middleware.RefreshTokenCookie, refreshToken.PlainText, refreshCookiePath, refreshToken.Expiry))
+	return body
+}
+
+// refreshTokenFromCookie returns the refresh token cookie in the cookie session mode
+func (h *Handler) refreshTokenFromCookie(c echo.Context) string {
+	if h.cookies == nil {
+		return ""
+	}
+
+	cookie, err := c.Cookie(middleware.RefreshTokenCookie)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func (h *Handler) clearTokenCookies(c echo.Context) {
+	if h.cookies == nil {
+		return
+	}
+
+	c.SetCookie(h.newCookie(middleware.AccessTokenCookie, "", "/", -1))
+	c.SetCookie(h.newCookie(middleware.RefreshTokenCookie, "", refreshCookiePath, -1))
+}
+
+// newCookie makes an HttpOnly cookie, a negative ttl deletes it
+func (h *Handler) newCookie(name, value, path string, ttl time.Duration) *http.Cookie {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   h.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   h.cookies.Secure,
+		HttpOnly: true,
+		SameSite: h.cookies.SameSite,
+	}
+}
+
+// newOAuthBindingCookie is set whether the cookie session mode is on or not, it's Lax
+// so the browser sends it on the redirect back from the provider
+func (h *Handler) newOAuthBindingCookie(c echo.Context, binding string, ttl time.Duration) *http.Cookie {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	cookie := &http.Cookie{
+		Name:     oauthBindingCookie,
+		Value:    binding,
+		Path:     oauthBindingCookiePath,
+		MaxAge:   maxAge,
+		Secure:   c.Scheme() == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if h.cookies != nil {
+		cookie.Domain = h.cookies.Domain
+		cookie.Secure = h.cookies.Secure
+	}
+
+	return cookie
+}
+
+// CSRFToken returns the csrf token browser clients must echo on unsafe requests in the cookie session mode,
+// it's the value of the _csrf cookie too
+func (h *Handler) CSRFToken(c echo.Context) error {
+	csrfToken, ok := c.Get(middleware.CSRFContextKey).(string)
+	if !ok {
+		return echo.ErrNotFound
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"csrf_token": csrfToken})
+}
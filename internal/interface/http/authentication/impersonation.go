@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Impersonate issues a short lived token to act as the user in the path, the token carries the admin
+// in its act claim and every request made with it is written to the audit log
+func (h *Handler) Impersonate(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request ImpersonateReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.StartImpersonation(ctx, claims.UserID, int64(request.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.ErrNotFound
+		case errors.Is(err, authservice.ErrImpersonateSelf):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, authservice.ErrImpersonationNotAllowed):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	jwtToken, tokenID, err := h.keys.NewImpersonationJWT(user.ID, model.Roles(user.Role), claims.UserID)
+	if err != nil {
+		h.slog.Error("failed to create token", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
+
+	audit := model.ImpersonationAudit{
+		ActorID: claims.UserID,
+		UserID:  user.ID,
+		TokenID: tokenID,
+		Method:  c.Request().Method,
+		Path:    c.Request().URL.Path,
+		IP:      c.RealIP(),
+	}
+	if err := h.service.RecordImpersonation(ctx, audit); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"user":       user,
+		"token":      jwtToken,
+		"expires_in": int(token.ImpersonationTokenTTL.Seconds()),
+	})
+}
@@ -2,6 +2,7 @@ package authentication
 
 import (
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
@@ -19,3 +20,18 @@ var loginDuration, _ = meter.Float64Histogram(
 	metric.WithDescription("the duration of the login handler"),
 	metric.WithUnit("s"),
 )
+
+var loginFailureCounter, _ = meter.Int64Counter(
+	"login.failure.counter",
+	metric.WithDescription("number of failed logins, by reason"),
+	metric.WithUnit("{calls}"),
+)
+
+// login failure reasons, used as the reason attribute of loginFailureCounter
+var (
+	loginFailureNotFound         = metric.WithAttributes(attribute.String("reason", "not_found"))
+	loginFailureInvalidPassword  = metric.WithAttributes(attribute.String("reason", "invalid_password"))
+	loginFailureLocked           = metric.WithAttributes(attribute.String("reason", "locked"))
+	loginFailureEmailNotVerified = metric.WithAttributes(attribute.String("reason", "email_not_verified"))
+	loginFailureSuspended        = metric.WithAttributes(attribute.String("reason", "suspended"))
+)
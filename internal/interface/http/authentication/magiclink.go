@@ -0,0 +1,99 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/izzanzahrial/skeleton/internal/model"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/labstack/echo/v4"
+)
+
+// SendMagicLink emails a login link, it always answers the same way whether the email has an account or not
+func (h *Handler) SendMagicLink(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request MagicLinkReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.SendMagicLink(ctx, request.Email); err != nil {
+		var tooManyErr *authservice.TooManyEmailsError
+		if errors.As(err, &tooManyErr) {
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(tooManyErr.RetryAfter.Seconds()))))
+			return echo.NewHTTPError(http.StatusTooManyRequests, tooManyErr.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "if the email has an account, a login link has been sent to it"})
+}
+
+// LoginMagicLink exchanges the token of the emailed link for the same tokens as Login,
+// the link replaces the password so users with mfa still get an mfa challenge
+func (h *Handler) LoginMagicLink(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request LoginMagicLinkReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.LoginMagicLink(ctx, request.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrInvalidMagicLink):
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		case errors.As(err, new(*authservice.SuspendedError)):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	mfaEnabled, err := h.service.IsMFAEnabled(ctx, user.ID)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+	if mfaEnabled {
+		challenge, err := h.service.NewMFAChallenge(ctx, user.ID)
+		if err != nil {
+			return echo.ErrInternalServerError
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"mfa_required": true,
+			"mfa_token":    challenge.PlainText,
+			"expires_in":   int(challenge.Expiry.Seconds()),
+		})
+	}
+
+	refreshToken, err := h.service.NewSession(ctx, user.ID, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	jwtToken, err := h.keys.NewJWT(user.ID, model.Roles(user.Role), refreshToken.Family)
+	if err != nil {
+		h.slog.Error("failed to create token", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, h.withTokens(c, echo.Map{"user": user}, jwtToken, refreshToken))
+}
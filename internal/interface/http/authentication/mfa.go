@@ -0,0 +1,163 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/labstack/echo/v4"
+)
+
+// LoginMFA exchanges the challenge given by Login and a totp or recovery code for the jwt
+func (h *Handler) LoginMFA(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request LoginMFAReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.VerifyMFAChallenge(ctx, request.MFAToken, request.Code)
+	if err != nil {
+		var lockedErr *authservice.LoginLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			return tooManyAttempts(c, lockedErr)
+		case errors.Is(err, authservice.ErrInvalidMFAChallenge), errors.Is(err, authservice.ErrInvalidMFACode):
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		case errors.As(err, new(*authservice.SuspendedError)):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	refreshToken, err := h.service.NewSession(ctx, user.ID, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	jwtToken, err := h.keys.NewJWT(user.ID, model.Roles(user.Role), refreshToken.Family)
+	if err != nil {
+		h.slog.Error("failed to create token", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, h.withTokens(c, echo.Map{"user": user}, jwtToken, refreshToken))
+}
+
+// EnrollTOTP starts the totp enrollment, the secret is only active after VerifyTOTP
+func (h *Handler) EnrollTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.service.EnrollTOTP(ctx, claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrMFANativeOnly):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case errors.Is(err, authservice.ErrMFAAlreadyEnabled):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// VerifyTOTP enables totp and returns the recovery codes, they can't be seen again
+func (h *Handler) VerifyTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request TOTPCodeReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	recoveryCodes, err := h.service.ConfirmTOTP(ctx, claims.UserID, request.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrMFANotEnrolled):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case errors.Is(err, authservice.ErrMFAAlreadyEnabled):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, authservice.ErrInvalidMFACode):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"recovery_codes": recoveryCodes})
+}
+
+// DisableTOTP turns off two-factor authentication, a totp or recovery code is needed
+// so a stolen access token alone can't remove it
+func (h *Handler) DisableTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request TOTPCodeReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.DisableTOTP(ctx, claims.UserID, request.Code); err != nil {
+		var lockedErr *authservice.LoginLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			return tooManyAttempts(c, lockedErr)
+		case errors.Is(err, authservice.ErrMFANotEnrolled):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case errors.Is(err, authservice.ErrInvalidMFACode):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// tooManyAttempts tells the client when the next attempt is allowed
+func tooManyAttempts(c echo.Context, lockedErr *authservice.LoginLockedError) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, lockedErr.Error())
+}
@@ -0,0 +1,171 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// BeginPasskeyRegistration returns the options the frontend gives to navigator.credentials.create
+func (h *Handler) BeginPasskeyRegistration(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	options, err := h.service.BeginPasskeyRegistration(ctx, claims.UserID)
+	if err != nil {
+		return passkeyError(err)
+	}
+
+	return c.JSON(http.StatusOK, options)
+}
+
+// FinishPasskeyRegistration stores the passkey returned by navigator.credentials.create
+func (h *Handler) FinishPasskeyRegistration(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request FinishPasskeyRegistrationReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	passkey, err := h.service.FinishPasskeyRegistration(ctx, claims.UserID, request.Name, request.Credential)
+	if err != nil {
+		return passkeyError(err)
+	}
+
+	return c.JSON(http.StatusCreated, passkey)
+}
+
+func (h *Handler) GetPasskeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	passkeys, err := h.service.GetPasskeys(ctx, claims.UserID)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, passkeys)
+}
+
+func (h *Handler) DeletePasskey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request DeletePasskeyReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.DeletePasskey(ctx, claims.UserID, request.ID); err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrPasskeyNotFound):
+			return echo.ErrNotFound
+		case errors.Is(err, authservice.ErrLastLoginMethod):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BeginPasskeyLogin returns the options the frontend gives to navigator.credentials.get
+func (h *Handler) BeginPasskeyLogin(c echo.Context) error {
+	options, err := h.service.BeginPasskeyLogin(c.Request().Context())
+	if err != nil {
+		return passkeyError(err)
+	}
+
+	return c.JSON(http.StatusOK, options)
+}
+
+// LoginPasskey exchanges the assertion returned by navigator.credentials.get for the same tokens as Login
+func (h *Handler) LoginPasskey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request LoginPasskeyReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.LoginPasskey(ctx, request.Credential)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.NewHTTPError(http.StatusUnauthorized, authservice.ErrInvalidPasskey.Error())
+		case errors.Is(err, authservice.ErrEmailNotVerified), errors.As(err, new(*authservice.SuspendedError)):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return passkeyError(err)
+		}
+	}
+
+	refreshToken, err := h.service.NewSession(ctx, user.ID, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	jwtToken, err := h.keys.NewJWT(user.ID, model.Roles(user.Role), refreshToken.Family)
+	if err != nil {
+		h.slog.Error("failed to create token", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, h.withTokens(c, echo.Map{"user": user}, jwtToken, refreshToken))
+}
+
+func passkeyError(err error) error {
+	switch {
+	case errors.Is(err, authservice.ErrPasskeysDisabled):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, authservice.ErrInvalidPasskey):
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	case errors.Is(err, authservice.ErrPasskeyExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.ErrInternalServerError
+	}
+}
@@ -0,0 +1,69 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/izzanzahrial/skeleton/pkg/password"
+	"github.com/labstack/echo/v4"
+)
+
+// ForgotPassword sends a password reset link, it always answers the same way whether the email has an account or not
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request ForgotPasswordReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.ForgotPassword(ctx, request.Email); err != nil {
+		var tooManyErr *authservice.TooManyEmailsError
+		if errors.As(err, &tooManyErr) {
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(tooManyErr.RetryAfter.Seconds()))))
+			return echo.NewHTTPError(http.StatusTooManyRequests, tooManyErr.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "if the email has an account, a password reset link has been sent to it"})
+}
+
+// ResetPassword sets a new password using the token from the reset link, the user is logged out everywhere
+func (h *Handler) ResetPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request ResetPasswordReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.ResetPassword(ctx, request.Token, request.Password); err != nil {
+		if errors.Is(err, authservice.ErrInvalidResetToken) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		var violation *password.PolicyViolation
+		if errors.As(err, &violation) {
+			return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"Password": violation.Message})
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -1,7 +1,116 @@
 package authentication
 
+import (
+	"time"
+
+	"github.com/izzanzahrial/skeleton/pkg/webauthn"
+)
+
 type LoginReq struct {
 	Email    string `form:"email" validate:"required_without=Username"`
 	Username string `form:"username" validate:"required_without=Email"`
-	Password string `form:"password" validate:"required"`
+	Password string `form:"password" validate:"required,max=128"`
+}
+
+type RefreshTokenReq struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token" validate:"required"`
+}
+
+type LogoutReq struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+}
+
+type RevokeUserSessionsReq struct {
+	ID int `param:"id" json:"-" validate:"required,gte=1"`
+}
+
+type UnlinkIdentityReq struct {
+	Provider string `param:"provider" json:"-" validate:"required"`
+}
+
+type UnlockLoginReq struct {
+	ID int    `param:"id" json:"-" validate:"required,gte=1"`
+	IP string `json:"ip" validate:"omitempty,ip"`
+}
+
+type SuspendUserReq struct {
+	ID     int    `param:"id" json:"-" validate:"required,gte=1"`
+	Reason string `json:"reason" validate:"required,max=500"`
+	// Until is when the suspension ends, the user is banned when it's left out
+	Until time.Time `json:"until"`
+}
+
+type UnsuspendUserReq struct {
+	ID int `param:"id" json:"-" validate:"required,gte=1"`
+}
+
+type LoginMFAReq struct {
+	MFAToken string `form:"mfa_token" json:"mfa_token" validate:"required"`
+	// Code is either the totp code or a recovery code
+	Code string `form:"code" json:"code" validate:"required"`
+}
+
+type TOTPCodeReq struct {
+	Code string `form:"code" json:"code" validate:"required"`
+}
+
+type ForgotPasswordReq struct {
+	Email string `form:"email" json:"email" validate:"required,email"`
+}
+
+type ResetPasswordReq struct {
+	Token    string `form:"token" json:"token" validate:"required"`
+	Password string `form:"password" json:"password" validate:"required,max=128"`
+}
+
+type VerifyEmailReq struct {
+	Token string `query:"token" form:"token" json:"token" validate:"required"`
+}
+
+type ResendVerificationEmailReq struct {
+	Email string `form:"email" json:"email" validate:"required,email"`
+}
+
+func (r ResetPasswordReq) NewPassword() (string, string, []string) {
+	return "Password", r.Password, nil
+}
+
+type CreateAPIKeyReq struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// ExpiresInDays defaults to 90 days when it's not set
+	ExpiresInDays int `json:"expires_in_days" validate:"omitempty,gte=1,lte=365"`
+}
+
+type RevokeAPIKeyReq struct {
+	ID int `param:"id" json:"-" validate:"required,gte=1"`
+}
+
+type RevokeSessionReq struct {
+	ID string `param:"id" json:"-" validate:"required"`
+}
+
+type ImpersonateReq struct {
+	ID int `param:"id" json:"-" validate:"required,gte=1"`
+}
+
+type MagicLinkReq struct {
+	Email string `form:"email" json:"email" validate:"required,email"`
+}
+
+type LoginMagicLinkReq struct {
+	Token string `form:"token" json:"token" validate:"required"`
+}
+
+type FinishPasskeyRegistrationReq struct {
+	Name       string                        `json:"name" validate:"required,max=100"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+type DeletePasskeyReq struct {
+	ID string `param:"id" json:"-" validate:"required"`
+}
+
+type LoginPasskeyReq struct {
+	Credential webauthn.AssertionResponse `json:"credential"`
 }
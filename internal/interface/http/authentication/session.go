@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/labstack/echo/v4"
+)
+
+// GetSessions lists the devices the user is logged in on, the session of the calling token is marked as current
+func (h *Handler) GetSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.service.GetSessions(ctx, claims.UserID, claims.SessionID)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession logs out one of the user's sessions
+func (h *Handler) RevokeSession(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request RevokeSessionReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.RevokeSession(ctx, claims.UserID, request.ID); err != nil {
+		if errors.Is(err, authservice.ErrSessionNotFound) {
+			return echo.ErrNotFound
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeOtherSessions logs out everywhere else, the session of the calling token stays logged in
+func (h *Handler) RevokeOtherSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.RevokeOtherSessions(ctx, claims.UserID, claims.SessionID); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
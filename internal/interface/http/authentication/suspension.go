@@ -0,0 +1,81 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// SuspendUser stops the user from logging in and from using their tokens until the suspension ends,
+// leaving out until bans the user
+func (h *Handler) SuspendUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request SuspendUserReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.SuspendUser(ctx, claims.UserID, int64(request.ID), request.Until, request.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.ErrNotFound
+		case errors.Is(err, authservice.ErrSuspendSelf), errors.Is(err, authservice.ErrSuspensionEnded):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, authservice.ErrSuspensionNotAllowed):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// UnsuspendUser lifts the suspension or the ban of the user before it ends
+func (h *Handler) UnsuspendUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request UnsuspendUserReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.UnsuspendUser(ctx, claims.UserID, int64(request.ID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.ErrNotFound
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
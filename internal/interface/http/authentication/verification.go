@@ -0,0 +1,64 @@
+package authentication
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	authservice "github.com/izzanzahrial/skeleton/internal/service/authentication"
+	"github.com/labstack/echo/v4"
+)
+
+// VerifyEmail marks the email as verified using the token from the link sent on signup
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request VerifyEmailReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.VerifyEmail(ctx, request.Token); err != nil {
+		if errors.Is(err, authservice.ErrInvalidVerificationToken) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResendVerificationEmail sends a new verification link, it always answers the same way whether the email has an account or not
+func (h *Handler) ResendVerificationEmail(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request ResendVerificationEmailReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.ResendVerificationEmail(ctx, request.Email); err != nil {
+		var tooManyErr *authservice.TooManyEmailsError
+		if errors.As(err, &tooManyErr) {
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(tooManyErr.RetryAfter.Seconds()))))
+			return echo.NewHTTPError(http.StatusTooManyRequests, tooManyErr.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "if the email has an unverified account, a verification link has been sent to it"})
+}
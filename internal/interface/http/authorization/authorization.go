@@ -0,0 +1,339 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	authorizationservice "github.com/izzanzahrial/skeleton/internal/service/authorization"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type authorizationService interface {
+	Authorize(ctx context.Context, userID int64, req authorizationservice.AuthorizationRequest) (authorizationservice.AuthorizationPrompt, error)
+	Approve(ctx context.Context, userID int64, req authorizationservice.AuthorizationRequest, approved bool) (string, error)
+	ExchangeAuthorizationCode(ctx context.Context, clientID, secret, code, redirectURI, verifier string) (authorizationservice.Grant, error)
+	ClientCredentialsToken(ctx context.Context, clientID, secret, scope string) (authorizationservice.Grant, error)
+	UserInfo(ctx context.Context, userID int64, scopes []string) (model.UserInfo, error)
+	CreateClient(ctx context.Context, name string, redirectURIs, grantTypes, scopes []string, firstParty, public bool) (model.OAuthClient, string, error)
+	GetClients(ctx context.Context) ([]model.OAuthClient, error)
+	RevokeClient(ctx context.Context, id string) error
+	GetConsents(ctx context.Context, userID int64) ([]model.OAuthConsent, error)
+	RevokeConsent(ctx context.Context, userID int64, clientID string) error
+	AuthenticateClient(ctx context.Context, clientID, secret, scope string) (model.OAuthClient, error)
+	Introspect(ctx context.Context, tkn string) (authorizationservice.Introspection, error)
+	Revoke(ctx context.Context, tkn string) error
+}
+
+type Handler struct {
+	service authorizationService
+	keys    *token.KeyManager
+	// issuer is the public base url of the api, authorizationURL the frontend consent page
+	issuer           string
+	authorizationURL string
+	slog             *slog.Logger
+}
+
+func NewHandler(service authorizationService, keys *token.KeyManager, issuer, authorizationURL string, slog *slog.Logger) *Handler {
+	return &Handler{service: service, keys: keys, issuer: issuer, authorizationURL: authorizationURL, slog: slog}
+}
+
+// Discovery serves the openid connect discovery document, so clients can configure themselves from the issuer
+func (h *Handler) Discovery(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{
+		"issuer":                                        h.issuer,
+		"authorization_endpoint":                        h.authorizationURL,
+		"token_endpoint":                                h.issuer + "/oauth/token",
+		"userinfo_endpoint":                             h.issuer + "/oauth/userinfo",
+		"jwks_uri":                                      h.issuer + "/.well-known/jwks.json",
+		"scopes_supported":                              model.OAuthScopes,
+		"response_types_supported":                      []string{"code"},
+		"grant_types_supported":                         model.OAuthGrantTypes,
+		"code_challenge_methods_supported":              []string{"S256"},
+		"token_endpoint_auth_methods_supported":         []string{"client_secret_basic", "client_secret_post", "none"},
+		"introspection_endpoint":                        h.issuer + "/oauth/introspect",
+		"revocation_endpoint":                           h.issuer + "/oauth/revoke",
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"revocation_endpoint_auth_methods_supported":    []string{"client_secret_basic", "client_secret_post"},
+		"subject_types_supported":                       []string{"public"},
+		"id_token_signing_alg_values_supported":         []string{h.keys.Algorithm()},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "nonce", "email", "email_verified",
+			"name", "given_name", "family_name", "preferred_username", "picture",
+		},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// Authorize is called by the consent page with the authorization request of the client,
+// it returns the client and the scopes to show the logged in user
+func (h *Handler) Authorize(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request AuthorizeReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	prompt, err := h.service.Authorize(ctx, claims.UserID, request.AuthorizationRequest())
+	if err != nil {
+		return h.oauthError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, prompt)
+}
+
+// Approve records the decision of the user, the consent page sends the user to redirect_to
+// which brings the authorization code, or the error, back to the client
+func (h *Handler) Approve(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request ApproveReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	redirectTo, err := h.service.Approve(ctx, claims.UserID, request.AuthorizationRequest(), request.Approved)
+	if err != nil {
+		return h.oauthError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"redirect_to": redirectTo})
+}
+
+// Token exchanges an authorization code, or the client credentials, for an access token.
+// An id token is added when the user granted the openid scope
+func (h *Handler) Token(c echo.Context) error {
+	ctx := c.Request().Context()
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	var request TokenReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest})
+	}
+
+	if err := c.Validate(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest, Description: "grant_type is required"})
+	}
+
+	clientID, secret, err := clientCredentials(c, request.ClientID, request.ClientSecret)
+	if err != nil {
+		return h.oauthError(c, err)
+	}
+
+	var grant authorizationservice.Grant
+	switch request.GrantType {
+	case model.GrantTypeAuthorizationCode:
+		grant, err = h.service.ExchangeAuthorizationCode(ctx, clientID, secret, request.Code, request.RedirectURI, request.CodeVerifier)
+	case model.GrantTypeClientCredentials:
+		grant, err = h.service.ClientCredentialsToken(ctx, clientID, secret, request.Scope)
+	default:
+		err = &authorizationservice.Error{Code: authorizationservice.ErrorUnsupportedGrantType}
+	}
+	if err != nil {
+		return h.oauthError(c, err)
+	}
+
+	// the client acting on its own behalf is the subject of the token
+	subject, userID, role := grant.ClientID, int64(0), model.Roles("")
+	if grant.User != nil {
+		subject, userID, role = strconv.FormatInt(grant.User.ID, 10), grant.User.ID, grant.User.Role
+	}
+
+	accessToken, _, err := h.keys.NewOAuthJWT(h.issuer, subject, userID, role, grant.ClientID, grant.Scopes)
+	if err != nil {
+		h.slog.Error("failed to create token", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
+
+	response := echo.Map{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(token.OAuthTokenTTL.Seconds()),
+		"scope":        strings.Join(grant.Scopes, " "),
+	}
+
+	if grant.User != nil && slices.Contains(grant.Scopes, model.ScopeOpenID) {
+		idToken, err := h.keys.NewIDToken(h.issuer, grant.ClientID, grant.User.ID, grant.Nonce, model.NewUserClaims(*grant.User, grant.Scopes))
+		if err != nil {
+			h.slog.Error("failed to create id token", slog.String("error", err.Error()))
+			return echo.ErrInternalServerError
+		}
+		response["id_token"] = idToken
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// UserInfo returns the claims of the user the access token gives access to,
+// the tokens issued by the login routes see every claim
+func (h *Handler) UserInfo(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	scopes := model.OAuthScopes
+	if claims.ClientID != "" {
+		scopes = strings.Fields(claims.Scope)
+	}
+
+	info, err := h.service.UserInfo(ctx, claims.UserID, scopes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.ErrUnauthorized
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// Introspect tells a downstream service whether a token is active and who it belongs to (RFC 7662),
+// the service authenticates with the credentials of a client registered with the tokens:introspect scope
+func (h *Handler) Introspect(c echo.Context) error {
+	ctx := c.Request().Context()
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	var request IntrospectReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest})
+	}
+
+	if err := h.authenticateCaller(c, request.ClientID, request.ClientSecret, model.ScopeTokensIntrospect); err != nil {
+		return h.oauthError(c, err)
+	}
+
+	if err := c.Validate(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest, Description: "token is required"})
+	}
+
+	introspection, err := h.service.Introspect(ctx, request.Token)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke revokes a token (RFC 7009), the caller authenticates like for the introspection with the tokens:revoke scope.
+// The response is the same whether the token was known or not
+func (h *Handler) Revoke(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request RevokeTokenReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest})
+	}
+
+	if err := h.authenticateCaller(c, request.ClientID, request.ClientSecret, model.ScopeTokensRevoke); err != nil {
+		return h.oauthError(c, err)
+	}
+
+	if err := c.Validate(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest, Description: "token is required"})
+	}
+
+	if err := h.service.Revoke(ctx, request.Token); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// authenticateCaller accepts the credentials of a confidential client registered with the scope,
+// the api keys of users are refused since the endpoints tell who a token belongs to
+func (h *Handler) authenticateCaller(c echo.Context, formClientID, formSecret, scope string) error {
+	ctx := c.Request().Context()
+
+	clientID, secret, err := clientCredentials(c, formClientID, formSecret)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.service.AuthenticateClient(ctx, clientID, secret, scope); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// clientCredentials reads the client id and secret from basic auth, or from the form,
+// basic auth values are form encoded first (RFC 6749 section 2.3.1)
+func clientCredentials(c echo.Context, formClientID, formSecret string) (string, string, error) {
+	id, secret, ok := c.Request().BasicAuth()
+	if !ok {
+		return formClientID, formSecret, nil
+	}
+
+	if formSecret != "" {
+		return "", "", &authorizationservice.Error{Code: authorizationservice.ErrorInvalidRequest, Description: "only one client authentication method can be used"}
+	}
+
+	id, err := url.QueryUnescape(id)
+	if err != nil {
+		return "", "", &authorizationservice.Error{Code: authorizationservice.ErrorInvalidClient}
+	}
+
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", &authorizationservice.Error{Code: authorizationservice.ErrorInvalidClient}
+	}
+
+	return id, secret, nil
+}
+
+// oauthError sends the oauth error as is, a client that failed to authenticate gets a 401
+func (h *Handler) oauthError(c echo.Context, err error) error {
+	var oauthErr *authorizationservice.Error
+	if !errors.As(err, &oauthErr) {
+		return echo.ErrInternalServerError
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == authorizationservice.ErrorInvalidClient {
+		status = http.StatusUnauthorized
+		if _, _, ok := c.Request().BasicAuth(); ok {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		}
+	}
+
+	return c.JSON(status, oauthErr)
+}
@@ -0,0 +1,129 @@
+package authorization
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	authorizationservice "github.com/izzanzahrial/skeleton/internal/service/authorization"
+	"github.com/labstack/echo/v4"
+)
+
+// CreateClient registers an oauth client, the secret of a confidential client is only shown in this response
+func (h *Handler) CreateClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request CreateClientReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	client, secret, err := h.service.CreateClient(ctx, request.Name, request.RedirectURIs, request.GrantTypes, request.Scopes, request.FirstParty, request.Public)
+	if err != nil {
+		switch {
+		case errors.Is(err, authorizationservice.ErrUnknownGrantType),
+			errors.Is(err, authorizationservice.ErrUnknownScope),
+			errors.Is(err, authorizationservice.ErrInvalidRedirectURI),
+			errors.Is(err, authorizationservice.ErrRedirectURIMissing),
+			errors.Is(err, authorizationservice.ErrPublicClientGrant):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.ErrInternalServerError
+		}
+	}
+
+	response := echo.Map{"client": client}
+	if secret != "" {
+		response["client_secret"] = secret
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *Handler) GetClients(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	clients, err := h.service.GetClients(ctx)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, clients)
+}
+
+func (h *Handler) RevokeClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request RevokeClientReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.RevokeClient(ctx, request.ID); err != nil {
+		if errors.Is(err, authorizationservice.ErrClientNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetConsents lists the clients the logged in user allowed to act on their behalf
+func (h *Handler) GetConsents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	consents, err := h.service.GetConsents(ctx, claims.UserID)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, consents)
+}
+
+func (h *Handler) RevokeConsent(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
+	var request RevokeConsentReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.RevokeConsent(ctx, claims.UserID, request.ClientID); err != nil {
+		if errors.Is(err, authorizationservice.ErrConsentNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package authorization
+
+import authorizationservice "github.com/izzanzahrial/skeleton/internal/service/authorization"
+
+// AuthorizeReq is the authorization request the client sent the user to the consent page with
+type AuthorizeReq struct {
+	ResponseType        string `query:"response_type" json:"response_type" validate:"required"`
+	ClientID            string `query:"client_id" json:"client_id" validate:"required"`
+	RedirectURI         string `query:"redirect_uri" json:"redirect_uri" validate:"required"`
+	Scope               string `query:"scope" json:"scope"`
+	State               string `query:"state" json:"state"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `query:"nonce" json:"nonce"`
+}
+
+func (r AuthorizeReq) AuthorizationRequest() authorizationservice.AuthorizationRequest {
+	return authorizationservice.AuthorizationRequest{
+		ResponseType:        r.ResponseType,
+		ClientID:            r.ClientID,
+		RedirectURI:         r.RedirectURI,
+		Scope:               r.Scope,
+		State:               r.State,
+		CodeChallenge:       r.CodeChallenge,
+		CodeChallengeMethod: r.CodeChallengeMethod,
+		Nonce:               r.Nonce,
+	}
+}
+
+type ApproveReq struct {
+	AuthorizeReq
+	Approved bool `json:"approved"`
+}
+
+// TokenReq is the form sent to the token endpoint, the client credentials can also be sent with basic auth
+type TokenReq struct {
+	GrantType    string `form:"grant_type" validate:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// IntrospectReq is the form sent to the introspection endpoint, token_type_hint is accepted but not needed
+type IntrospectReq struct {
+	Token         string `form:"token" validate:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type RevokeTokenReq struct {
+	Token         string `form:"token" validate:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type CreateClientReq struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,required,url"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1,dive,required"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// FirstParty clients are trusted, the users aren't asked to approve them
+	FirstParty bool `json:"first_party"`
+	// Public clients (e.g. single page or mobile apps) can't keep a secret, they only get tokens with pkce
+	Public bool `json:"public"`
+}
+
+type RevokeClientReq struct {
+	ID string `param:"id" json:"-" validate:"required"`
+}
+
+type RevokeConsentReq struct {
+	ClientID string `param:"client_id" json:"-" validate:"required"`
+}
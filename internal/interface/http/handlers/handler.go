@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"github.com/izzanzahrial/skeleton/internal/interface/http/authentication"
+	"github.com/izzanzahrial/skeleton/internal/interface/http/authorization"
 	"github.com/izzanzahrial/skeleton/internal/interface/http/post"
+	"github.com/izzanzahrial/skeleton/internal/interface/http/role"
 	"github.com/izzanzahrial/skeleton/internal/interface/http/user"
 )
 
@@ -10,6 +12,9 @@ type Handlers struct {
 	Auth *authentication.Handler
 	User *user.Handler
 	Post *post.Handler
+	Role *role.Handler
+	// Authorization is nil when the oauth server is disabled
+	Authorization *authorization.Handler
 }
 
 // type HandlersConfiguration func(h *Handlers) error
@@ -33,10 +38,12 @@ type Handlers struct {
 // 	}
 // }
 
-func NewHandlers(ah *authentication.Handler, uh *user.Handler, ph *post.Handler) *Handlers {
+func NewHandlers(ah *authentication.Handler, uh *user.Handler, ph *post.Handler, rh *role.Handler, azh *authorization.Handler) *Handlers {
 	return &Handlers{
-		Auth: ah,
-		User: uh,
-		Post: ph,
+		Auth:          ah,
+		User:          uh,
+		Post:          ph,
+		Role:          rh,
+		Authorization: azh,
 	}
 }
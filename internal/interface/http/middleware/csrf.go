@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+// CSRFContextKey holds the csrf token of the request, so it can be handed to the client
+const CSRFContextKey = "csrf"
+
+// CSRF is a double submit csrf protection for the cookie session mode, unsafe requests must echo the value
+// of the _csrf cookie where tokenLookup points to (e.g. header:X-CSRF-Token), a cross site page can't read it.
+// Requests with an Authorization header are skipped since the browser never adds that header on its own,
+// so are the skipPaths that never read the session cookies, e.g. the oauth token endpoint called by other apps
+func CSRF(tokenLookup, cookieDomain string, cookieSecure bool, cookieSameSite http.SameSite, skipPaths ...string) echo.MiddlewareFunc {
+	return echomiddleware.CSRFWithConfig(echomiddleware.CSRFConfig{
+		Skipper: func(c echo.Context) bool {
+			return c.Request().Header.Get(echo.HeaderAuthorization) != "" || slices.Contains(skipPaths, c.Path())
+		},
+		TokenLookup:    tokenLookup,
+		ContextKey:     CSRFContextKey,
+		CookieName:     "_csrf",
+		CookieDomain:   cookieDomain,
+		CookiePath:     "/",
+		CookieSecure:   cookieSecure,
+		CookieSameSite: cookieSameSite,
+	})
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/labstack/echo/v4"
+)
+
+// impersonationAuditor writes the audit log of the requests made with an impersonation token
+type impersonationAuditor interface {
+	RecordImpersonation(ctx context.Context, audit model.ImpersonationAudit) error
+}
+
+// DenyImpersonation rejects impersonation tokens on sensitive routes, like changing the password
+// or managing the credentials of the user, it must run after IsAuthenticated
+func DenyImpersonation(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := Claims(c)
+		if err != nil {
+			return err
+		}
+
+		if claims.Actor != nil {
+			return echo.NewHTTPError(http.StatusForbidden, "not allowed while impersonating a user")
+		}
+
+		return next(c)
+	}
+}
+
+// auditImpersonation records the requests made with an impersonation token before they run,
+// a request that can't be audited is rejected
+func (m *Middleware) auditImpersonation(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := Claims(c)
+		if err != nil || claims.Actor == nil {
+			return next(c)
+		}
+
+		audit := model.ImpersonationAudit{
+			ActorID: claims.Actor.UserID,
+			UserID:  claims.UserID,
+			TokenID: claims.ID,
+			Method:  c.Request().Method,
+			Path:    c.Request().URL.Path,
+			IP:      c.RealIP(),
+		}
+		if err := m.auditor.RecordImpersonation(c.Request().Context(), audit); err != nil {
+			return echo.ErrInternalServerError
+		}
+
+		return next(c)
+	}
+}
@@ -1,73 +1,348 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
 
 	"github.com/izzanzahrial/skeleton/internal/model"
 	"github.com/izzanzahrial/skeleton/pkg/token"
 )
 
-// using authorization headers but from echo
-func IsAuthorize(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		tkn := c.Get("user").(*jwt.Token)
-		claims, ok := tkn.Claims.(*token.JwtCustomClaims)
-		if !ok && !tkn.Valid {
-			return echo.ErrUnauthorized
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// cookies holding the tokens in the cookie session mode
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+)
+
+type revocationCache interface {
+	IsAccessTokenDenied(ctx context.Context, jti string) (bool, error)
+	TokensRevokedBefore(ctx context.Context, userID int64) (time.Time, error)
+	TouchSession(ctx context.Context, id, ip string, at time.Time) (bool, error)
+	UserSuspension(ctx context.Context, userID int64) (model.Suspension, bool, error)
+}
+
+// apiKeyAuthenticator checks the api keys sent instead of a jwt
+type apiKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, clientIP string) (model.APIKey, model.User, error)
+}
+
+// clientTokenChecker tells whether the client a token was issued to, and the consent of its user, are still valid
+type clientTokenChecker interface {
+	IsClientTokenActive(ctx context.Context, clientID string, userID int64) (bool, error)
+}
+
+// permissionChecker looks up the permissions of the role the user currently has
+type permissionChecker interface {
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+}
+
+// Middleware holds the dependencies needed by the middlewares that verify a token
+type Middleware struct {
+	keys        *token.KeyManager
+	cache       revocationCache
+	apiKeys     apiKeyAuthenticator
+	permissions permissionChecker
+	auditor     impersonationAuditor
+	slog        *slog.Logger
+	// cookieTokens also reads the access token from AccessTokenCookie
+	cookieTokens bool
+	// clients checks the tokens issued to oauth clients, they are rejected when it's not set
+	clients clientTokenChecker
+}
+
+type Config func(m *Middleware)
+
+// WithCookieTokens accepts the access token cookie of the cookie session mode, next to the Authorization header
+func WithCookieTokens() Config {
+	return func(m *Middleware) {
+		m.cookieTokens = true
+	}
+}
+
+// WithOAuthClients accepts the tokens issued to oauth clients as long as the client and the consent aren't revoked
+func WithOAuthClients(clients clientTokenChecker) Config {
+	return func(m *Middleware) {
+		m.clients = clients
+	}
+}
+
+func New(keys *token.KeyManager, cache revocationCache, apiKeys apiKeyAuthenticator, permissions permissionChecker, auditor impersonationAuditor, slog *slog.Logger, cfgs ...Config) *Middleware {
+	m := &Middleware{keys: keys, cache: cache, apiKeys: apiKeys, permissions: permissions, auditor: auditor, slog: slog}
+	for _, cfg := range cfgs {
+		cfg(m)
+	}
+
+	return m
+}
+
+// Claims returns the claims of the token verified by IsAuthenticated
+func Claims(c echo.Context) (*token.JwtCustomClaims, error) {
+	tkn, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	claims, ok := tkn.Claims.(*token.JwtCustomClaims)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	return claims, nil
+}
+
+// RequirePermission only lets through users whose role has the permission, it must run after IsAuthenticated
+func (m *Middleware) RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := Claims(c)
+			if err != nil {
+				return err
+			}
+
+			ok, err := m.permissions.HasPermission(c.Request().Context(), claims.UserID, permission)
+			if err != nil {
+				return echo.ErrInternalServerError
+			}
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, "missing permission "+permission)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+const apiKeyContextKey = "api_key"
+
+// APIKey returns the api key the request was authenticated with, it's false when a jwt was used
+func APIKey(c echo.Context) (model.APIKey, bool) {
+	apiKey, ok := c.Get(apiKeyContextKey).(model.APIKey)
+	return apiKey, ok
+}
+
+// IsAuthenticated accepts a jwt, or an api key (Authorization: Bearer sk_...) holding one of the given scopes.
+// Api keys and the tokens issued to oauth clients are rejected on routes that don't list any scope,
+// so they can't manage the account they belong to. Suspended users are rejected until their suspension ends
+func (m *Middleware) IsAuthenticated(scopes ...string) echo.MiddlewareFunc {
+	config := echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(token.JwtCustomClaims)
+		},
+		ParseTokenFunc: m.parseToken,
+	}
+	if m.cookieTokens {
+		config.TokenLookupFuncs = []echomiddleware.ValuesExtractor{extractHeaderOrCookie}
+	}
+	jwtMiddleware := echojwt.WithConfig(config)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		jwtNext := jwtMiddleware(restrictClientTokens(scopes, m.rejectSuspended(m.auditImpersonation(next))))
+
+		return func(c echo.Context) error {
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			if key, ok := strings.CutPrefix(auth, "Bearer "); ok && strings.HasPrefix(key, token.APIKeyPrefix) {
+				if err := m.authenticateAPIKey(c, key, scopes); err != nil {
+					return err
+				}
+				return m.rejectSuspended(next)(c)
+			}
+
+			return jwtNext(c)
 		}
+	}
+}
 
-		if claims.Role != model.RolesAdmin {
-			return echo.ErrUnauthorized
+// extractHeaderOrCookie only falls back to the access token cookie when there's no Authorization header,
+// since the csrf middleware skips the requests having that header
+func extractHeaderOrCookie(c echo.Context) ([]string, error) {
+	if auth := c.Request().Header.Get(echo.HeaderAuthorization); auth != "" {
+		tkn, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok {
+			return nil, errors.New("invalid authorization header")
 		}
+		return []string{tkn}, nil
+	}
 
-		return next(c)
+	cookie, err := c.Cookie(AccessTokenCookie)
+	if err != nil {
+		return nil, errors.New("missing access token")
 	}
+
+	return []string{cookie.Value}, nil
 }
 
-// using authorization header
-func IsAuthorizeHeader(next echo.HandlerFunc) echo.HandlerFunc {
+// restrictClientTokens only lets through the tokens of oauth clients holding one of the scopes, like api keys,
+// a client acting on its own behalf has no user so its tokens are meant for other services
+func restrictClientTokens(scopes []string, next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		authtoken := c.Request().Header.Get("Authorization")
-		if authtoken == "" {
-			return echo.ErrUnauthorized
+		claims, err := Claims(c)
+		if err != nil {
+			return err
 		}
 
-		tokenString := strings.Split(authtoken, " ")[1]
-		tkn, err := jwt.ParseWithClaims(tokenString, &token.JwtCustomClaims{}, func(t *jwt.Token) (interface{}, error) {
-			// TODO: move secret key somewhere else
-			return []byte("secret"), nil
-		})
-		if err != nil {
-			log.Fatalf("failed to parse token: %v", err)
-			return echo.ErrUnauthorized
+		if claims.ClientID == "" {
+			return next(c)
 		}
 
-		claims, ok := tkn.Claims.(*token.JwtCustomClaims)
-		if !ok && !tkn.Valid {
-			return echo.ErrUnauthorized
+		if len(scopes) == 0 || claims.UserID == 0 {
+			return echo.NewHTTPError(http.StatusForbidden, "oauth client tokens can't be used on this route")
 		}
 
-		if claims.Role != model.RolesAdmin {
-			return echo.ErrUnauthorized
+		granted := strings.Fields(claims.Scope)
+		if !slices.ContainsFunc(scopes, func(scope string) bool { return slices.Contains(granted, scope) }) {
+			return echo.NewHTTPError(http.StatusForbidden, "token is missing the scope "+strings.Join(scopes, " or "))
 		}
 
 		return next(c)
 	}
 }
 
-func IsAuthenticated() echo.MiddlewareFunc {
-	config := echojwt.Config{
-		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(token.JwtCustomClaims)
-		},
-		// TODO: move secret key somewhere else
-		SigningKey: []byte("secret"),
+// rejectSuspended stops the user of the token while they are suspended, oauth clients acting on their own behalf have no user
+func (m *Middleware) rejectSuspended(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := Claims(c)
+		if err != nil {
+			return err
+		}
+
+		if claims.UserID != 0 {
+			if err := m.checkSuspended(c.Request().Context(), claims.UserID); err != nil {
+				return err
+			}
+		}
+
+		return next(c)
+	}
+}
+
+// checkSuspended returns a forbidden error telling when the suspension of the user ends
+func (m *Middleware) checkSuspended(ctx context.Context, userID int64) error {
+	suspension, suspended, err := m.cache.UserSuspension(ctx, userID)
+	if err != nil {
+		m.slog.Error("failed to check user suspension", slog.String("error", err.Error()))
+		return echo.ErrInternalServerError
+	}
+	if suspended && suspension.Active(time.Now()) {
+		return echo.NewHTTPError(http.StatusForbidden, suspension.Message())
+	}
+
+	return nil
+}
+
+// authenticateAPIKey stores the api key owner as claims, so handlers read it the same way as a jwt
+func (m *Middleware) authenticateAPIKey(c echo.Context, key string, scopes []string) error {
+	if len(scopes) == 0 {
+		return echo.NewHTTPError(http.StatusForbidden, "api keys can't be used on this route")
+	}
+
+	apiKey, user, err := m.apiKeys.AuthenticateAPIKey(c.Request().Context(), key, c.RealIP())
+	if err != nil {
+		m.slog.Debug("failed to authenticate api key", slog.String("error", err.Error()))
+		return echo.ErrUnauthorized
+	}
+
+	if !slices.ContainsFunc(scopes, func(scope string) bool { return slices.Contains(apiKey.Scopes, scope) }) {
+		return echo.NewHTTPError(http.StatusForbidden, "api key is missing the scope "+strings.Join(scopes, " or "))
+	}
+
+	// api keys aren't revoked on suspension, so the suspension is read from the user loaded with the key
+	if user.Suspension != nil && user.Suspension.Active(time.Now()) {
+		return echo.NewHTTPError(http.StatusForbidden, user.Suspension.Message())
+	}
+
+	claims := &token.JwtCustomClaims{UserID: user.ID, Role: user.Role}
+	c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+	c.Set(apiKeyContextKey, apiKey)
+
+	return nil
+}
+
+// parseToken verifies the token signature and rejects id tokens and tokens that were revoked before they expired
+func (m *Middleware) parseToken(c echo.Context, auth string) (interface{}, error) {
+	tkn, err := jwt.ParseWithClaims(auth, new(token.JwtCustomClaims), m.keys.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := tkn.Claims.(*token.JwtCustomClaims)
+	if !ok || !tkn.Valid || !claims.IsAccessToken() {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := m.checkRevoked(c.Request().Context(), claims, c.RealIP()); err != nil {
+		return nil, err
+	}
+
+	if claims.ClientID != "" {
+		if err := m.checkClient(c.Request().Context(), claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return tkn, nil
+}
+
+// checkClient rejects the tokens of a revoked client, and of a user who revoked the consent given to the client
+func (m *Middleware) checkClient(ctx context.Context, claims *token.JwtCustomClaims) error {
+	if m.clients == nil {
+		return ErrTokenRevoked
+	}
+
+	active, err := m.clients.IsClientTokenActive(ctx, claims.ClientID, claims.UserID)
+	if err != nil {
+		m.slog.Error("failed to check oauth client", slog.String("error", err.Error()))
+		return err
+	}
+	if !active {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// checkRevoked rejects denied tokens and tokens of a session that was logged out,
+// tokens issued before sessions were recorded don't carry a session id
+func (m *Middleware) checkRevoked(ctx context.Context, claims *token.JwtCustomClaims, clientIP string) error {
+	denied, err := m.cache.IsAccessTokenDenied(ctx, claims.ID)
+	if err != nil {
+		m.slog.Error("failed to check denied token", slog.String("error", err.Error()))
+		return err
+	}
+	if denied {
+		return ErrTokenRevoked
+	}
+
+	revokedBefore, err := m.cache.TokensRevokedBefore(ctx, claims.UserID)
+	if err != nil {
+		m.slog.Error("failed to check revoked tokens", slog.String("error", err.Error()))
+		return err
+	}
+	if !revokedBefore.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.After(revokedBefore)) {
+		return ErrTokenRevoked
+	}
+
+	if claims.SessionID != "" {
+		active, err := m.cache.TouchSession(ctx, claims.SessionID, clientIP, time.Now())
+		if err != nil {
+			m.slog.Error("failed to check session", slog.String("error", err.Error()))
+			return err
+		}
+		if !active {
+			return ErrTokenRevoked
+		}
 	}
 
-	return echojwt.WithConfig(config)
+	return nil
 }
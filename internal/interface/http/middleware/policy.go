@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// OwnerFunc returns the id of the user that owns the resource the request acts on
+type OwnerFunc func(c echo.Context) (int64, error)
+
+// OwnerFromParam reads the owner from a path param, for routes where the resource is the user itself
+func OwnerFromParam(name string) OwnerFunc {
+	return func(c echo.Context) (int64, error) {
+		id, err := strconv.ParseInt(c.Param(name), 10, 64)
+		if err != nil || id < 1 {
+			return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid "+name)
+		}
+
+		return id, nil
+	}
+}
+
+// RequireOwnerOrPermission lets through the owner of the resource, or a user whose role has the permission,
+// it must run after IsAuthenticated
+func (m *Middleware) RequireOwnerOrPermission(owner OwnerFunc, permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := Claims(c)
+			if err != nil {
+				return err
+			}
+
+			ownerID, err := owner(c)
+			if err != nil {
+				return err
+			}
+
+			if ownerID == claims.UserID {
+				return next(c)
+			}
+
+			ok, err := m.permissions.HasPermission(c.Request().Context(), claims.UserID, permission)
+			if err != nil {
+				return echo.ErrInternalServerError
+			}
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, "only the owner or a user with the permission "+permission+" can do this")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/izzanzahrial/skeleton/config"
+	"golang.org/x/oauth2"
+)
+
+var ErrProviderNotFound = errors.New("oauth provider not found")
+
+// Provider is a single OIDC discoverable identity provider, e.g. google, auth0, keycloak, gitlab or dex
+type Provider struct {
+	Name string
+	*oidc.Provider
+	oauth2.Config
+	verifier   *oidc.IDTokenVerifier
+	authParams []oauth2.AuthCodeOption
+	claims     map[string]string
+}
+
+// Registry holds every configured provider by name
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry discovers every configured provider, providers that fail discovery are left out
+// and their errors are returned together with the registry of the remaining providers
+func NewRegistry(ctx context.Context, cfgs []config.OIDCProvider) (*Registry, error) {
+	r := &Registry{providers: make(map[string]*Provider, len(cfgs))}
+
+	var errs []error
+	for _, cfg := range cfgs {
+		provider, err := newProvider(ctx, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create provider %s: %w", cfg.Name, err))
+			continue
+		}
+		r.providers[cfg.Name] = provider
+	}
+
+	return r, errors.Join(errs...)
+}
+
+func (r *Registry) Get(name string) (*Provider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	return provider, nil
+}
+
+func newProvider(ctx context.Context, cfg config.OIDCProvider) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var authParams []oauth2.AuthCodeOption
+	for key, value := range cfg.AuthParams {
+		authParams = append(authParams, oauth2.SetAuthURLParam(key, value))
+	}
+
+	return &Provider{
+		Name:     cfg.Name,
+		Provider: provider,
+		Config: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier:   provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		authParams: authParams,
+		claims:     cfg.Claims,
+	}, nil
+}
+
+// AuthCodeURL adds the provider specific parameters on top of the given options
+func (p *Provider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.Config.AuthCodeURL(state, append(opts, p.authParams...)...)
+}
+
+// Profile holds the claims of the id token that are mapped to a user
+type Profile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Picture       string
+}
+
+// FirstName falls back to the first word of the full name, since some providers don't send given_name
+func (p Profile) FirstName() string {
+	if p.GivenName != "" {
+		return p.GivenName
+	}
+
+	first, _, _ := strings.Cut(p.Name, " ")
+	return first
+}
+
+func (p Profile) LastName() string {
+	if p.FamilyName != "" || p.GivenName != "" {
+		return p.FamilyName
+	}
+
+	_, last, _ := strings.Cut(p.Name, " ")
+	return last
+}
+
+// VerifyIDToken verifies the id token returned together with the oauth2 token,
+// and maps its claims to a profile using the provider claim mapping
+func (p *Provider) VerifyIDToken(ctx context.Context, token *oauth2.Token) (Profile, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Profile{}, errors.New("no id_token field in oauth2 token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Profile{}, fmt.Errorf("failed to unmarshal claims: %w", err)
+	}
+
+	return Profile{
+		Subject:       p.stringClaim(claims, "subject", "sub"),
+		Email:         p.stringClaim(claims, "email", "email"),
+		EmailVerified: p.boolClaim(claims, "email_verified", "email_verified"),
+		Name:          p.stringClaim(claims, "name", "name"),
+		GivenName:     p.stringClaim(claims, "given_name", "given_name"),
+		FamilyName:    p.stringClaim(claims, "family_name", "family_name"),
+		Picture:       p.stringClaim(claims, "picture", "picture"),
+	}, nil
+}
+
+func (p *Provider) claimName(field, standard string) string {
+	if claim, ok := p.claims[field]; ok {
+		return claim
+	}
+
+	return standard
+}
+
+func (p *Provider) stringClaim(claims map[string]interface{}, field, standard string) string {
+	value, _ := claims[p.claimName(field, standard)].(string)
+	return value
+}
+
+// boolClaim also accepts "true", since some providers send email_verified as a string
+func (p *Provider) boolClaim(claims map[string]interface{}, field, standard string) bool {
+	switch value := claims[p.claimName(field, standard)].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,279 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/izzanzahrial/skeleton/config"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientID = "client-id"
+	testKeyID    = "issuer-key"
+)
+
+// mockIssuer is an OIDC provider serving its discovery document and its keys
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]any{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Error(err)
+	}
+}
+
+// idToken signs the claims on top of valid standard claims, a nil value removes the claim
+func (m *mockIssuer) idToken(t *testing.T, key *rsa.PrivateKey, claims map[string]any) *oauth2.Token {
+	t.Helper()
+
+	all := jwt.MapClaims{
+		"iss": m.URL,
+		"aud": testClientID,
+		"sub": "subject-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(all, name)
+			continue
+		}
+		all[name] = value
+	}
+
+	tkn := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	tkn.Header["kid"] = testKeyID
+	signed, err := tkn.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]any{"id_token": signed})
+}
+
+func newTestProvider(t *testing.T, issuer *mockIssuer, cfg config.OIDCProvider) *Provider {
+	t.Helper()
+
+	cfg.Name = "mock"
+	cfg.Issuer = issuer.URL
+	cfg.ClientID = testClientID
+
+	registry, err := NewRegistry(context.Background(), []config.OIDCProvider{cfg})
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+
+	provider, err := registry.Get("mock")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	return provider
+}
+
+func TestNewRegistry(t *testing.T) {
+	issuer := newMockIssuer(t)
+	broken := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(broken.Close)
+
+	registry, err := NewRegistry(context.Background(), []config.OIDCProvider{
+		{Name: "mock", Issuer: issuer.URL, ClientID: testClientID},
+		{Name: "broken", Issuer: broken.URL, ClientID: testClientID},
+	})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("NewRegistry() error = %v, want the error of the broken provider", err)
+	}
+
+	provider, err := registry.Get("mock")
+	if err != nil {
+		t.Fatalf("Get() error = %v, the working provider must be kept", err)
+	}
+	if provider.Config.Endpoint.AuthURL != issuer.URL+"/authorize" || provider.Config.Endpoint.TokenURL != issuer.URL+"/token" {
+		t.Errorf("Endpoint = %+v, want the discovered endpoints", provider.Config.Endpoint)
+	}
+
+	for _, name := range []string{"broken", "unknown"} {
+		if _, err := registry.Get(name); !errors.Is(err, ErrProviderNotFound) {
+			t.Errorf("Get(%s) error = %v, want %v", name, err, ErrProviderNotFound)
+		}
+	}
+}
+
+func TestNewRegistryIssuerMismatch(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	// the discovery document must be served by the issuer it names
+	_, err := NewRegistry(context.Background(), []config.OIDCProvider{{Name: "mock", Issuer: issuer.URL + "/other", ClientID: testClientID}})
+	if err == nil {
+		t.Error("NewRegistry() error = nil for an issuer that doesn't match its discovery document")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer, config.OIDCProvider{
+		RedirectURL: "https://app.example.com/callback",
+		Scopes:      []string{"openid", "email"},
+		AuthParams:  map[string]string{"access_type": "offline"},
+	})
+
+	u, err := url.Parse(provider.AuthCodeURL("state-1", oauth2.S256ChallengeOption("verifier")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	want := map[string]string{
+		"state":                 "state-1",
+		"client_id":             testClientID,
+		"redirect_uri":          "https://app.example.com/callback",
+		"scope":                 "openid email",
+		"access_type":           "offline",
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	standard := newTestProvider(t, issuer, config.OIDCProvider{})
+	mapped := newTestProvider(t, issuer, config.OIDCProvider{Claims: map[string]string{"subject": "oid", "email": "upn", "email_verified": "verified"}})
+
+	tests := []struct {
+		name     string
+		provider *Provider
+		token    *oauth2.Token
+		want     Profile
+		wantErr  bool
+	}{
+		{
+			name:     "standard claims",
+			provider: standard,
+			token: issuer.idToken(t, issuer.key, map[string]any{
+				"email": "jane@example.com", "email_verified": true, "name": "Jane Doe",
+				"given_name": "Jane", "family_name": "Doe", "picture": "https://example.com/jane.png",
+			}),
+			want: Profile{
+				Subject: "subject-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe",
+				GivenName: "Jane", FamilyName: "Doe", Picture: "https://example.com/jane.png",
+			},
+		},
+		{
+			name:     "email_verified as a string",
+			provider: standard,
+			token:    issuer.idToken(t, issuer.key, map[string]any{"email": "jane@example.com", "email_verified": "true"}),
+			want:     Profile{Subject: "subject-1", Email: "jane@example.com", EmailVerified: true},
+		},
+		{
+			name:     "unverified email",
+			provider: standard,
+			token:    issuer.idToken(t, issuer.key, map[string]any{"email": "jane@example.com", "email_verified": "false"}),
+			want:     Profile{Subject: "subject-1", Email: "jane@example.com"},
+		},
+		{
+			name:     "mapped claims",
+			provider: mapped,
+			token:    issuer.idToken(t, issuer.key, map[string]any{"oid": "object-1", "upn": "jane@corp.example.com", "verified": true, "email": "ignored@example.com"}),
+			want:     Profile{Subject: "object-1", Email: "jane@corp.example.com", EmailVerified: true},
+		},
+		{name: "wrong audience", provider: standard, token: issuer.idToken(t, issuer.key, map[string]any{"aud": "other-client"}), wantErr: true},
+		{name: "wrong issuer", provider: standard, token: issuer.idToken(t, issuer.key, map[string]any{"iss": "https://evil.example.com"}), wantErr: true},
+		{name: "expired", provider: standard, token: issuer.idToken(t, issuer.key, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}), wantErr: true},
+		{name: "signed by another key", provider: standard, token: issuer.idToken(t, otherKey, nil), wantErr: true},
+		{name: "missing id token", provider: standard, token: &oauth2.Token{AccessToken: "access-token"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.VerifyIDToken(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyIDToken() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyIDToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   Profile
+		wantFirst string
+		wantLast  string
+	}{
+		{name: "given and family name", profile: Profile{Name: "Jane Q Doe", GivenName: "Jane", FamilyName: "Doe"}, wantFirst: "Jane", wantLast: "Doe"},
+		{name: "only full name", profile: Profile{Name: "Jane Q Doe"}, wantFirst: "Jane", wantLast: "Q Doe"},
+		{name: "only given name", profile: Profile{Name: "Jane Doe", GivenName: "Jane"}, wantFirst: "Jane", wantLast: ""},
+		{name: "single word name", profile: Profile{Name: "Jane"}, wantFirst: "Jane", wantLast: ""},
+		{name: "no name", profile: Profile{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if first, last := tt.profile.FirstName(), tt.profile.LastName(); first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("FirstName(), LastName() = %q, %q, want %q, %q", first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
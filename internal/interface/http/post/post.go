@@ -5,7 +5,9 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 
+	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
 	"github.com/izzanzahrial/skeleton/internal/model"
 	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
@@ -16,6 +18,9 @@ var tracer = otel.Tracer("github.com/izzanzahrial/skeleton/internal/interface/ht
 
 type postService interface {
 	CreatePost(ctx context.Context, userID int64, title, content string) (model.Post, error)
+	GetPost(ctx context.Context, id int64) (model.Post, error)
+	UpdatePost(ctx context.Context, id int64, title, content *string) (model.Post, error)
+	DeletePost(ctx context.Context, id int64) error
 	GetPostByUserID(ctx context.Context, userID int64) ([]model.Post, error)
 	GetPostsFullText(ctx context.Context, limit, offset int, keyword string) ([]model.Post, error)
 }
@@ -34,6 +39,11 @@ func (h *Handler) CreatePost(c echo.Context) error {
 	ctx, span := tracer.Start(ctx, "post.CreatePost")
 	defer span.End()
 
+	claims, err := middleware.Claims(c)
+	if err != nil {
+		return err
+	}
+
 	var request CreatPostReq
 	if err := c.Bind(&request); err != nil {
 		h.slog.Error("failed to bind request", slog.String("error", err.Error()))
@@ -45,7 +55,7 @@ func (h *Handler) CreatePost(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	post, err := h.service.CreatePost(ctx, request.UserID, request.Title, request.Content)
+	post, err := h.service.CreatePost(ctx, claims.UserID, request.Title, request.Content)
 	if err != nil {
 		return echo.ErrInternalServerError
 	}
@@ -106,3 +116,71 @@ func (h *Handler) GetPostsFullText(c echo.Context) error {
 
 	return c.JSON(http.StatusFound, posts)
 }
+
+func (h *Handler) UpdatePost(c echo.Context) error {
+	ctx := c.Request().Context()
+	ctx, span := tracer.Start(ctx, "post.UpdatePost")
+	defer span.End()
+
+	var request UpdatePostReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("failed to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("failed to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	post, err := h.service.UpdatePost(ctx, request.ID, request.Title, request.Content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.ErrNotFound
+		}
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, post)
+}
+
+func (h *Handler) DeletePost(c echo.Context) error {
+	ctx := c.Request().Context()
+	ctx, span := tracer.Start(ctx, "post.DeletePost")
+	defer span.End()
+
+	var request DeletePostReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("failed to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("failed to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.service.DeletePost(ctx, request.ID); err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Owner returns the author of the post in the path, it's used by the owner or moderator policy
+func (h *Handler) Owner(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+
+	post, err := h.service.GetPost(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, echo.ErrNotFound
+		}
+		return 0, echo.ErrInternalServerError
+	}
+
+	return post.UserID, nil
+}
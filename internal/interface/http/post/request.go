@@ -1,7 +1,7 @@
 package post
 
+// CreatPostReq doesn't take the author, posts are created as the authenticated user
 type CreatPostReq struct {
-	UserID  int64  `form:"id" json:"user_id" validate:"required"`
 	Title   string `form:"title" json:"title" validate:"required"`
 	Content string `form:"content" json:"content" validate:"required"`
 }
@@ -15,3 +15,14 @@ type GetPostsFullTextReq struct {
 	Limit   int    `query:"limit" json:"limit" validate:"omitempty,gte=10"`
 	Offset  int    `query:"offset" json:"offset" validate:"omitempty,gte=1"`
 }
+
+// the id of UpdatePostReq and DeletePostReq is only bound from the path, it's the one RequireOwnerOrPermission checked
+type UpdatePostReq struct {
+	ID      int64   `param:"id" json:"-" validate:"required,gte=1"`
+	Title   *string `json:"title" validate:"omitempty,min=1"`
+	Content *string `json:"content" validate:"omitempty,min=1"`
+}
+
+type DeletePostReq struct {
+	ID int64 `param:"id" json:"-" validate:"required,gte=1"`
+}
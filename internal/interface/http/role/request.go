@@ -0,0 +1,21 @@
+package role
+
+type CreateRoleReq struct {
+	Name        string   `json:"name" validate:"required,max=64,excludesall= /"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type SetRolePermissionsReq struct {
+	Name        string   `param:"name" json:"-" validate:"required"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type DeleteRoleReq struct {
+	Name string `param:"name" json:"-" validate:"required"`
+}
+
+type AssignRoleReq struct {
+	ID   int    `param:"id" json:"-" validate:"required,gte=1"`
+	Role string `json:"role" validate:"required"`
+}
@@ -0,0 +1,160 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/izzanzahrial/skeleton/internal/model"
+	roleservice "github.com/izzanzahrial/skeleton/internal/service/role"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type roleService interface {
+	GetPermissions(ctx context.Context) ([]model.Permission, error)
+	GetRoles(ctx context.Context) ([]model.Role, error)
+	CreateRole(ctx context.Context, name, description string, permissions []string) (model.Role, error)
+	SetRolePermissions(ctx context.Context, name string, permissions []string) (model.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	AssignRole(ctx context.Context, userID int64, name string) (model.User, error)
+}
+
+type Handler struct {
+	service roleService
+	slog    *slog.Logger
+}
+
+func NewHandler(service roleService, slog *slog.Logger) *Handler {
+	return &Handler{service: service, slog: slog}
+}
+
+func (h *Handler) GetPermissions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	permissions, err := h.service.GetPermissions(ctx)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, permissions)
+}
+
+func (h *Handler) GetRoles(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	roles, err := h.service.GetRoles(ctx)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+func (h *Handler) CreateRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request CreateRoleReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	role, err := h.service.CreateRole(ctx, request.Name, request.Description, request.Permissions)
+	if err != nil {
+		return roleError(err)
+	}
+
+	return c.JSON(http.StatusCreated, role)
+}
+
+// SetRolePermissions replaces the permissions of a role
+func (h *Handler) SetRolePermissions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request SetRolePermissionsReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	role, err := h.service.SetRolePermissions(ctx, request.Name, request.Permissions)
+	if err != nil {
+		return roleError(err)
+	}
+
+	return c.JSON(http.StatusOK, role)
+}
+
+func (h *Handler) DeleteRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request DeleteRoleReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	if err := h.service.DeleteRole(ctx, request.Name); err != nil {
+		return roleError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AssignRole replaces the role of a user, it applies to the tokens the user already has
+func (h *Handler) AssignRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var request AssignRoleReq
+	if err := c.Bind(&request); err != nil {
+		h.slog.Error("fail to bind request", slog.String("error", err.Error()))
+		return echo.ErrBadRequest
+	}
+
+	if err := c.Validate(&request); err != nil {
+		h.slog.Error("fail to validate request", slog.String("error", err.Error()))
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	user, err := h.service.AssignRole(ctx, int64(request.ID), request.Role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.ErrNotFound
+		}
+		return roleError(err)
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, roleservice.ErrRoleNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, roleservice.ErrRoleExists), errors.Is(err, roleservice.ErrRoleInUse):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, roleservice.ErrProtectedRole):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, roleservice.ErrUnknownPermission):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.ErrInternalServerError
+	}
+}
@@ -3,44 +3,118 @@ package router
 import (
 	"github.com/izzanzahrial/skeleton/internal/interface/http/handlers"
 	"github.com/izzanzahrial/skeleton/internal/interface/http/middleware"
+	"github.com/izzanzahrial/skeleton/internal/model"
 	"github.com/labstack/echo/v4"
 )
 
-func MapRoutes(e *echo.Echo, h *handlers.Handlers) {
+func MapRoutes(e *echo.Echo, h *handlers.Handlers, m *middleware.Middleware) {
+	// public keys used to verify the issued jwt, so other services don't need a shared secret
+	e.GET("/.well-known/jwks.json", h.Auth.JWKS)
+
 	api := e.Group("/api")
 	v1 := api.Group("/v1")
 
-	mapAuthenticationRoutes(v1, h)
-	mapUserRoutes(v1, h)
-	mapPostRoute(v1, h)
+	mapAuthenticationRoutes(v1, h, m)
+	mapUserRoutes(v1, h, m)
+	mapRoleRoutes(v1, h, m)
+	mapPostRoute(v1, h, m)
+	if h.Authorization != nil {
+		mapAuthorizationRoutes(e, v1, h, m)
+	}
 }
 
-func mapAuthenticationRoutes(e *echo.Group, h *handlers.Handlers) {
+// impersonation tokens are denied on the routes that change credentials, sessions or permissions
+func mapAuthenticationRoutes(e *echo.Group, h *handlers.Handlers, m *middleware.Middleware) {
 	// using native authentication
 	e.POST("/login", h.Auth.Login)
+	e.POST("/login/mfa", h.Auth.LoginMFA)
+	e.POST("/login/magic-link", h.Auth.SendMagicLink)
+	e.POST("/login/magic-link/consume", h.Auth.LoginMagicLink)
+	e.POST("/login/passkey/options", h.Auth.BeginPasskeyLogin)
+	e.POST("/login/passkey", h.Auth.LoginPasskey)
+	e.POST("/refresh", h.Auth.RefreshToken)
+	e.POST("/logout", h.Auth.Logout, m.IsAuthenticated())
+	e.GET("/csrf", h.Auth.CSRFToken)
+	e.POST("/password/forgot", h.Auth.ForgotPassword)
+	e.POST("/password/reset", h.Auth.ResetPassword)
+	e.POST("/verify-email", h.Auth.VerifyEmail)
+	e.POST("/verify-email/resend", h.Auth.ResendVerificationEmail)
+	e.POST("/users/:id/revoke-sessions", h.Auth.RevokeUserSessions, m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionUsersWrite))
+	e.POST("/users/:id/unlock", h.Auth.UnlockLogin, m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionUsersWrite))
+	e.POST("/users/:id/suspend", h.Auth.SuspendUser, m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionUsersSuspend))
+	e.POST("/users/:id/unsuspend", h.Auth.UnsuspendUser, m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionUsersSuspend))
+	e.POST("/mfa/totp/enroll", h.Auth.EnrollTOTP, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.POST("/mfa/totp/verify", h.Auth.VerifyTOTP, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.DELETE("/mfa/totp", h.Auth.DisableTOTP, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.POST("/passkeys/options", h.Auth.BeginPasskeyRegistration, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.POST("/passkeys", h.Auth.FinishPasskeyRegistration, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.GET("/passkeys", h.Auth.GetPasskeys, m.IsAuthenticated())
+	e.DELETE("/passkeys/:id", h.Auth.DeletePasskey, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.POST("/api-keys", h.Auth.CreateAPIKey, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.GET("/api-keys", h.Auth.GetAPIKeys, m.IsAuthenticated())
+	e.DELETE("/api-keys/:id", h.Auth.RevokeAPIKey, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.GET("/sessions", h.Auth.GetSessions, m.IsAuthenticated())
+	e.DELETE("/sessions", h.Auth.RevokeOtherSessions, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.DELETE("/sessions/:id", h.Auth.RevokeSession, m.IsAuthenticated(), middleware.DenyImpersonation)
+	// the issued token acts as the user, every request made with it is audited
+	e.POST("/users/:id/impersonate", h.Auth.Impersonate, m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionUsersImpersonate))
 
-	// using google oauth2 authentication
-	e.GET("/google", h.Auth.LoginGoogleOAuth)
-	e.GET("/callback", h.Auth.Callback)
-	e.GET("/refresh", h.Auth.RefreshToken)
-
-	// using auth0 authentication
-	e.GET("/auth0", h.Auth.LoginAuth0)
-	e.GET("/callback/auth0", h.Auth.CallbackAuth0)
-
+	// using any oidc provider configured in OIDC_PROVIDERS, e.g. google or auth0
+	e.GET("/oauth/:provider", h.Auth.LoginOAuth)
+	e.GET("/oauth/:provider/callback", h.Auth.CallbackOAuth)
+	e.POST("/oauth/:provider/link", h.Auth.LinkOAuth, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.GET("/identities", h.Auth.GetIdentities, m.IsAuthenticated())
+	e.DELETE("/identities/:provider", h.Auth.UnlinkIdentity, m.IsAuthenticated(), middleware.DenyImpersonation)
 }
 
-func mapUserRoutes(e *echo.Group, h *handlers.Handlers) {
+func mapUserRoutes(e *echo.Group, h *handlers.Handlers, m *middleware.Middleware) {
 	e.POST("/signup", h.User.Signup)
-	e.POST("/signup-admin", h.User.SignUpAdmin, middleware.IsAuthenticated(), middleware.IsAuthorize)
-	e.GET("/users/:role", h.User.GetUsersByRole, middleware.IsAuthenticated(), middleware.IsAuthorize)
-	e.GET("/users", h.User.GetUsersLikeUsername, middleware.IsAuthenticated(), middleware.IsAuthorize)
-	e.PATCH("/users/:id", h.User.UpdateUser)
-	e.DELETE("/users", h.User.DeleteUser, middleware.IsAuthenticated(), middleware.IsAuthorize)
+	// creating an admin hands out the admin role
+	e.POST("/signup-admin", h.User.SignUpAdmin, m.IsAuthenticated(model.ScopeUsersWrite), middleware.DenyImpersonation, m.RequirePermission(model.PermissionRolesManage))
+	e.GET("/users/:role", h.User.GetUsersByRole, m.IsAuthenticated(model.ScopeUsersRead), m.RequirePermission(model.PermissionUsersRead))
+	e.GET("/users", h.User.GetUsersLikeUsername, m.IsAuthenticated(model.ScopeUsersRead), m.RequirePermission(model.PermissionUsersRead))
+	// users can update and delete their own account, other accounts need the permission
+	e.PATCH("/users/:id", h.User.UpdateUser, m.IsAuthenticated(model.ScopeUsersWrite), middleware.DenyImpersonation, m.RequireOwnerOrPermission(middleware.OwnerFromParam("id"), model.PermissionUsersWrite))
+	e.DELETE("/users/:id", h.User.DeleteUser, m.IsAuthenticated(model.ScopeUsersWrite), middleware.DenyImpersonation, m.RequireOwnerOrPermission(middleware.OwnerFromParam("id"), model.PermissionUsersDelete))
+}
+
+func mapRoleRoutes(e *echo.Group, h *handlers.Handlers, m *middleware.Middleware) {
+	manage := []echo.MiddlewareFunc{m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionRolesManage)}
+
+	e.GET("/permissions", h.Role.GetPermissions, manage...)
+	e.GET("/roles", h.Role.GetRoles, manage...)
+	e.POST("/roles", h.Role.CreateRole, manage...)
+	e.PUT("/roles/:name/permissions", h.Role.SetRolePermissions, manage...)
+	e.DELETE("/roles/:name", h.Role.DeleteRole, manage...)
+	e.PUT("/users/:id/role", h.Role.AssignRole, manage...)
 }
 
-func mapPostRoute(e *echo.Group, h *handlers.Handlers) {
-	e.POST("/posts", h.Post.CreatePost)
+func mapPostRoute(e *echo.Group, h *handlers.Handlers, m *middleware.Middleware) {
+	e.POST("/posts", h.Post.CreatePost, m.IsAuthenticated(model.ScopePostsWrite))
+	e.PATCH("/posts/:id", h.Post.UpdatePost, m.IsAuthenticated(model.ScopePostsWrite), m.RequireOwnerOrPermission(h.Post.Owner, model.PermissionPostsModerate))
+	e.DELETE("/posts/:id", h.Post.DeletePost, m.IsAuthenticated(model.ScopePostsWrite), m.RequireOwnerOrPermission(h.Post.Owner, model.PermissionPostsModerate))
 	e.GET("/posts/:id", h.Post.GetPostByUserID)
 	e.GET("/posts", h.Post.GetPostsFullText)
 }
+
+// the oauth endpoints are served at the root of the issuer, the client and consent management under the api
+func mapAuthorizationRoutes(e *echo.Echo, v1 *echo.Group, h *handlers.Handlers, m *middleware.Middleware) {
+	e.GET("/.well-known/openid-configuration", h.Authorization.Discovery)
+	// called by the consent page, an impersonating admin can't give away access to the user account
+	e.GET("/oauth/authorize", h.Authorization.Authorize, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.POST("/oauth/authorize", h.Authorization.Approve, m.IsAuthenticated(), middleware.DenyImpersonation)
+	e.POST("/oauth/token", h.Authorization.Token)
+	// called by downstream services, they authenticate with client credentials or an api key
+	e.POST("/oauth/introspect", h.Authorization.Introspect)
+	e.POST("/oauth/revoke", h.Authorization.Revoke)
+	e.GET("/oauth/userinfo", h.Authorization.UserInfo, m.IsAuthenticated(model.ScopeOpenID))
+	e.POST("/oauth/userinfo", h.Authorization.UserInfo, m.IsAuthenticated(model.ScopeOpenID))
+
+	manage := []echo.MiddlewareFunc{m.IsAuthenticated(), middleware.DenyImpersonation, m.RequirePermission(model.PermissionClientsManage)}
+	v1.GET("/oauth-clients", h.Authorization.GetClients, manage...)
+	v1.POST("/oauth-clients", h.Authorization.CreateClient, manage...)
+	v1.DELETE("/oauth-clients/:id", h.Authorization.RevokeClient, manage...)
+
+	v1.GET("/oauth-consents", h.Authorization.GetConsents, m.IsAuthenticated())
+	v1.DELETE("/oauth-consents/:client_id", h.Authorization.RevokeConsent, m.IsAuthenticated(), middleware.DenyImpersonation)
+}
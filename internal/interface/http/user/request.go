@@ -16,25 +16,49 @@ type GetUsersLikeUsernameReq struct {
 	Offset   int    `query:"offset" validate:"omitempty,gte=1"`
 }
 
+// the id of DeleteUserReq and UpdateUserReq is only bound from the path, it's the one RequireOwnerOrPermission checked
 type DeleteUserReq struct {
-	ID int `json:"id" validate:"required,gte=1"`
+	ID int `param:"id" json:"-" validate:"required,gte=1"`
 }
 
 type SignUpUserReq struct {
 	Email    string `form:"email" validate:"required,email"`
 	Username string `form:"username" validate:"required"`
-	Password string `form:"password" validate:"required"`
+	Password string `form:"password" validate:"required,max=128"`
 }
 
 type SignUpAdminReq struct {
 	Email    string `form:"email" validate:"required,email"`
 	Username string `form:"username" validate:"required"`
-	Password string `form:"password" validate:"required"`
+	Password string `form:"password" validate:"required,max=128"`
 }
 
 type UpdateUserReq struct {
-	ID       int     `param:"id" json:"id" validate:"required,gte=1"`
+	ID       int     `param:"id" json:"-" validate:"required,gte=1"`
 	Email    *string `json:"email" validate:"omitempty,email"`
-	Username *string `json:"username" validate:"omitempty,alpha`
-	Password *string `json:"password" validate:"omitempty"`
+	Username *string `json:"username" validate:"omitempty,alpha"`
+	Password *string `json:"password" validate:"omitempty,max=128"`
+}
+
+func (r SignUpUserReq) NewPassword() (string, string, []string) {
+	return "Password", r.Password, []string{r.Username, r.Email}
+}
+
+func (r SignUpAdminReq) NewPassword() (string, string, []string) {
+	return "Password", r.Password, []string{r.Username, r.Email}
+}
+
+func (r UpdateUserReq) NewPassword() (string, string, []string) {
+	var userInputs []string
+	if r.Username != nil {
+		userInputs = append(userInputs, *r.Username)
+	}
+	if r.Email != nil {
+		userInputs = append(userInputs, *r.Email)
+	}
+
+	if r.Password == nil {
+		return "Password", "", userInputs
+	}
+	return "Password", *r.Password, userInputs
 }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/password"
 	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
@@ -22,13 +23,19 @@ type userService interface {
 	DeleteUser(ctx context.Context, id int64) error
 }
 
+// emailVerifier sends the verification link to a new user
+type emailVerifier interface {
+	SendVerificationEmail(ctx context.Context, user model.User) error
+}
+
 type Handler struct {
-	service userService
-	slog    *slog.Logger
+	service  userService
+	verifier emailVerifier
+	slog     *slog.Logger
 }
 
-func NewHandler(service userService, slog *slog.Logger) *Handler {
-	return &Handler{service: service, slog: slog}
+func NewHandler(service userService, verifier emailVerifier, slog *slog.Logger) *Handler {
+	return &Handler{service: service, verifier: verifier, slog: slog}
 }
 
 func (h *Handler) Signup(c echo.Context) error {
@@ -54,6 +61,11 @@ func (h *Handler) Signup(c echo.Context) error {
 		return echo.ErrInternalServerError
 	}
 
+	// the user is already created, the link can be sent again using /verify-email/resend
+	if err := h.verifier.SendVerificationEmail(ctx, user); err != nil {
+		h.slog.Error("failed to send verification email", slog.String("error", err.Error()))
+	}
+
 	duration := time.Since(start)
 	signUpDuration.Record(ctx, duration.Seconds())
 	return c.JSON(http.StatusCreated, user)
@@ -227,6 +239,13 @@ func (h *Handler) UpdateUser(c echo.Context) error {
 
 	user, err := h.service.UpdateUser(ctx, int64(request.ID), request.Email, request.Username, request.Password)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.ErrNotFound
+		}
+		var violation *password.PolicyViolation
+		if errors.As(err, &violation) {
+			return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"Password": violation.Message})
+		}
 		return echo.ErrInternalServerError
 	}
 
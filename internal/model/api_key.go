@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+)
+
+// api key scopes, a route that accepts api keys requires one of them
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+)
+
+var APIKeyScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopePostsRead, ScopePostsWrite}
+
+type APIKey struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	// Prefix is the start of the key, so the user can tell the keys apart
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	LastUsedIP string    `json:"last_used_ip"`
+}
+
+// DBAPIKeyToModelAPIKey converts a DB api key to a model api key
+func DBAPIKeyToModelAPIKey(keys ...db.ApiKey) []APIKey {
+	var modelKeys []APIKey
+
+	for _, k := range keys {
+		modelKeys = append(modelKeys, APIKey{
+			ID:         k.ID,
+			UserID:     k.UserID,
+			CreatedAt:  k.CreatedAt.Time,
+			Name:       k.Name,
+			Prefix:     k.Prefix,
+			Scopes:     k.Scopes,
+			ExpiresAt:  k.ExpiresAt.Time,
+			LastUsedAt: k.LastUsedAt.Time,
+			LastUsedIP: k.LastUsedIp.String,
+		})
+	}
+
+	return modelKeys
+}
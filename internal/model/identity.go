@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+)
+
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+// DBUserIdentityToModelUserIdentity converts a DB user identity to a model user identity
+func DBUserIdentityToModelUserIdentity(identities ...db.UserIdentity) []UserIdentity {
+	var modelIdentities []UserIdentity
+
+	for _, i := range identities {
+		modelIdentities = append(modelIdentities, UserIdentity{
+			ID:        i.ID,
+			UserID:    i.UserID,
+			CreatedAt: i.CreatedAt.Time,
+			Provider:  i.Provider,
+			Subject:   i.Subject,
+			Email:     i.Email,
+		})
+	}
+
+	return modelIdentities
+}
@@ -0,0 +1,12 @@
+package model
+
+// ImpersonationAudit is the audit log entry of a request made by an admin acting as another user
+type ImpersonationAudit struct {
+	ActorID int64
+	UserID  int64
+	// TokenID is the jti of the impersonation token
+	TokenID string
+	Method  string
+	Path    string
+	IP      string
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+)
+
+// openid connect scopes, they give the client the identity of the user instead of access to the api
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// token scopes let a downstream service call the introspection and revocation endpoints,
+// only the oauth clients registered by an admin can hold them, never the api keys of a user
+const (
+	ScopeTokensIntrospect = "tokens:introspect"
+	ScopeTokensRevoke     = "tokens:revoke"
+)
+
+// OAuthScopes are the scopes an oauth client can be registered with, the api scopes work like the api key ones
+var OAuthScopes = append([]string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeTokensIntrospect, ScopeTokensRevoke}, APIKeyScopes...)
+
+// grant types supported by the authorization server
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+var OAuthGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials}
+
+// OAuthClient is an app allowed to get tokens from the authorization server, public clients don't have a secret
+type OAuthClient struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	FirstParty   bool      `json:"first_party"`
+	Public       bool      `json:"public"`
+}
+
+// OAuthConsent holds the scopes the user allowed the client to use
+type OAuthConsent struct {
+	ClientID  string    `json:"client_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Scopes    []string  `json:"scopes"`
+}
+
+// UserClaims are the openid connect claims about the user, only the ones the granted scopes allow are set
+type UserClaims struct {
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+}
+
+// UserInfo is the response of the userinfo endpoint
+type UserInfo struct {
+	Subject string `json:"sub"`
+	UserClaims
+}
+
+// NewUserClaims returns the claims of the user the scopes give access to
+func NewUserClaims(user User, scopes []string) UserClaims {
+	var claims UserClaims
+
+	if slices.Contains(scopes, ScopeEmail) {
+		verified := !user.EmailVerifiedAt.IsZero()
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+
+	if slices.Contains(scopes, ScopeProfile) {
+		claims.GivenName = user.FirstName
+		claims.FamilyName = user.LastName
+		claims.PreferredUsername = user.Username
+		claims.Picture = user.PictureUrl
+		claims.Name = user.FirstName
+		if user.LastName != "" {
+			claims.Name += " " + user.LastName
+		}
+	}
+
+	return claims
+}
+
+// DBOauthClientToModelOAuthClient converts a DB oauth client to a model oauth client
+func DBOauthClientToModelOAuthClient(clients ...db.OauthClient) []OAuthClient {
+	var modelClients []OAuthClient
+
+	for _, c := range clients {
+		modelClients = append(modelClients, OAuthClient{
+			ID:           c.ID,
+			CreatedAt:    c.CreatedAt.Time,
+			Name:         c.Name,
+			RedirectURIs: c.RedirectUris,
+			GrantTypes:   c.GrantTypes,
+			Scopes:       c.Scopes,
+			FirstParty:   c.FirstParty,
+			Public:       len(c.SecretHash) == 0,
+		})
+	}
+
+	return modelClients
+}
+
+// DBOauthConsentToModelOAuthConsent converts a DB oauth consent to a model oauth consent
+func DBOauthConsentToModelOAuthConsent(consents ...db.OauthConsent) []OAuthConsent {
+	var modelConsents []OAuthConsent
+
+	for _, c := range consents {
+		modelConsents = append(modelConsents, OAuthConsent{
+			ClientID:  c.ClientID,
+			CreatedAt: c.CreatedAt.Time,
+			UpdatedAt: c.UpdatedAt.Time,
+			Scopes:    c.Scopes,
+		})
+	}
+
+	return modelConsents
+}
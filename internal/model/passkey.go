@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/base64"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+)
+
+// Passkey is a webauthn credential of the user, ID is the base64url credential id the browser uses
+type Passkey struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// DBWebauthnCredentialToModelPasskey converts a DB webauthn credential to a model passkey
+func DBWebauthnCredentialToModelPasskey(credentials ...db.WebauthnCredential) []Passkey {
+	var passkeys []Passkey
+
+	for _, c := range credentials {
+		passkeys = append(passkeys, Passkey{
+			ID:         base64.RawURLEncoding.EncodeToString(c.ID),
+			UserID:     c.UserID,
+			Name:       c.Name,
+			CreatedAt:  c.CreatedAt.Time,
+			LastUsedAt: c.LastUsedAt.Time,
+		})
+	}
+
+	return passkeys
+}
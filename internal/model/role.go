@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+)
+
+// permissions checked by the router, they are stored in the permissions table by the rbac migration
+const (
+	PermissionUsersRead     = "users:read"
+	PermissionUsersWrite    = "users:write"
+	PermissionUsersDelete   = "users:delete"
+	PermissionPostsModerate = "posts:moderate"
+	PermissionRolesManage   = "roles:manage"
+	// PermissionUsersImpersonate is added by the impersonation migration
+	PermissionUsersImpersonate = "users:impersonate"
+	// PermissionClientsManage is added by the oauth server migration
+	PermissionClientsManage = "clients:manage"
+	// PermissionUsersSuspend is added by the user suspension migration
+	PermissionUsersSuspend = "users:suspend"
+)
+
+type Role struct {
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	Description string    `json:"description"`
+	// Builtin roles are given by the signup flows and can't be deleted
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// DBRoleToModelRole converts a DB role and its permissions to a model role
+func DBRoleToModelRole(role db.Role, permissions []string) Role {
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return Role{
+		Name:        role.Name,
+		CreatedAt:   role.CreatedAt.Time,
+		Description: role.Description,
+		Builtin:     role.Builtin,
+		Permissions: permissions,
+	}
+}
+
+// DBPermissionToModelPermission converts a DB permission to a model permission
+func DBPermissionToModelPermission(permissions ...db.Permission) []Permission {
+	var modelPermissions []Permission
+
+	for _, p := range permissions {
+		modelPermissions = append(modelPermissions, Permission{
+			Name:        p.Name,
+			Description: p.Description,
+		})
+	}
+
+	return modelPermissions
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Session is a login on a device, it's the family of refresh tokens rotated from that login
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// Current is set for the session of the token used to list the sessions
+	Current bool `json:"current"`
+}
@@ -18,8 +18,17 @@ type Origins string
 const (
 	NativeOrigin Origins = "native"
 	GoogleOrigin Origins = "google"
+	Auth0Origin  Origins = "auth0"
+	OIDCOrigin   Origins = "oidc"
 )
 
+// RefreshTokenField is the associated data of the encrypted users.refresh_token,
+// so the value can't be moved into another encrypted field
+const RefreshTokenField = "users.refresh_token"
+
+// TOTPSecretField is the associated data of the encrypted user_totp.secret
+const TOTPSecretField = "user_totp.secret"
+
 type User struct {
 	ID           int64     `json:"id"`
 	CreatedAt    time.Time `json:"created_at"`
@@ -31,31 +40,78 @@ type User struct {
 	FirstName    string    `json:"first_name"`
 	LastName     string    `json:"last_name"`
 	PictureUrl   string    `json:"picture_url"`
-	RefreshToken string    `json:"refresh_token"`
-	Role         Roles     `json:"role"`
-	Origin       Origins   `json:"origin"`
+	// RefreshToken is the oauth provider refresh token given at signup, it's stored encrypted
+	// and never read back nor serialized
+	RefreshToken string  `json:"-"`
+	Role         Roles   `json:"role"`
+	Origin       Origins `json:"origin"`
+	// EmailVerifiedAt is zero until the user opened the verification link
+	EmailVerifiedAt time.Time `json:"email_verified_at"`
+	// Suspension is only set while the user is suspended or banned
+	Suspension *Suspension `json:"suspension,omitempty"`
+}
+
+// Suspension is why and until when a moderator stopped the user from using their account, a zero Until is a ban
+type Suspension struct {
+	SuspendedAt time.Time `json:"suspended_at"`
+	Until       time.Time `json:"until"`
+	Reason      string    `json:"reason"`
+}
+
+// Active is false once the suspension ended, a ban never ends
+func (s Suspension) Active(now time.Time) bool {
+	return s.Until.IsZero() || now.Before(s.Until)
+}
+
+// Message tells the suspended user when they can use their account again
+func (s Suspension) Message() string {
+	if s.Until.IsZero() {
+		return "account is banned: " + s.Reason
+	}
+	return "account is suspended until " + s.Until.UTC().Format(time.RFC3339) + ": " + s.Reason
+}
+
+// DBUserSuspension returns the suspension of the user, it's false when the user isn't suspended anymore
+func DBUserSuspension(u db.User, now time.Time) (Suspension, bool) {
+	if !u.SuspendedAt.Valid {
+		return Suspension{}, false
+	}
+
+	suspension := Suspension{
+		SuspendedAt: u.SuspendedAt.Time,
+		Until:       u.SuspendedUntil.Time,
+		Reason:      u.SuspensionReason.String,
+	}
+
+	return suspension, suspension.Active(now)
 }
 
 // DBUserToModelUser converts a DB user to a model user
 func DBUserToModelUser(users ...db.User) []User {
 	var modelUsers []User
 
+	now := time.Now()
 	for _, u := range users {
-		modelUsers = append(modelUsers, User{
-			ID:           u.ID,
-			CreatedAt:    u.CreatedAt.Time,
-			UpdatedAt:    u.UpdatedAt.Time,
-			DeletedAt:    u.DeletedAt.Time,
-			Email:        u.Email,
-			Username:     u.Username.String,
-			PasswordHash: u.PasswordHash,
-			FirstName:    u.FirstName.String,
-			LastName:     u.LastName.String,
-			PictureUrl:   u.PictureUrl.String,
-			RefreshToken: u.RefreshToken.String,
-			Role:         Roles(u.Role),
-			Origin:       Origins(u.Origin),
-		})
+		user := User{
+			ID:              u.ID,
+			CreatedAt:       u.CreatedAt.Time,
+			UpdatedAt:       u.UpdatedAt.Time,
+			DeletedAt:       u.DeletedAt.Time,
+			Email:           u.Email,
+			Username:        u.Username.String,
+			PasswordHash:    u.PasswordHash,
+			FirstName:       u.FirstName.String,
+			LastName:        u.LastName.String,
+			PictureUrl:      u.PictureUrl.String,
+			Role:            Roles(u.Role),
+			Origin:          Origins(u.Origin),
+			EmailVerifiedAt: u.EmailVerifiedAt.Time,
+		}
+		if suspension, ok := DBUserSuspension(u, now); ok {
+			user.Suspension = &suspension
+		}
+
+		modelUsers = append(modelUsers, user)
 	}
 
 	return modelUsers
@@ -0,0 +1,126 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// DefaultAPIKeyTTL is used when the user doesn't choose when the key expires
+	DefaultAPIKeyTTL = 90 * 24 * time.Hour
+	MaxAPIKeyTTL     = 365 * 24 * time.Hour
+	// apiKeyPrefixLength is how much of the key is stored in plain text to recognize it, the rest stays secret
+	apiKeyPrefixLength = len(token.APIKeyPrefix) + 6
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid or expired api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidScope   = errors.New("invalid api key scope")
+)
+
+// CreateAPIKey creates a scoped api key for the user, the plain text key is only returned this once
+func (s *Service) CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, ttl time.Duration) (model.APIKey, string, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(model.APIKeyScopes, scope) {
+			return model.APIKey{}, "", fmt.Errorf("%w %q", ErrInvalidScope, scope)
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultAPIKeyTTL
+	}
+	ttl = min(ttl, MaxAPIKeyTTL)
+
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	tkn, err := token.NewAPIKey(userID, ttl)
+	if err != nil {
+		s.slog.Error("error creating api key", slog.String("error", err.Error()))
+		return model.APIKey{}, "", err
+	}
+
+	param := db.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    tkn.PlainText[:apiKeyPrefixLength],
+		KeyHash:   tkn.Hash,
+		Scopes:    scopes,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(ttl), Valid: true},
+	}
+
+	apiKey, err := s.repo.CreateAPIKey(ctx, param)
+	if err != nil {
+		s.slog.Error("error storing api key", slog.String("error", err.Error()))
+		return model.APIKey{}, "", err
+	}
+
+	return model.DBAPIKeyToModelAPIKey(apiKey)[0], tkn.PlainText, nil
+}
+
+func (s *Service) GetAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error) {
+	apiKeys, err := s.repo.GetAPIKeysByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting api keys", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return model.DBAPIKeyToModelAPIKey(apiKeys...), nil
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	rows, err := s.repo.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		s.slog.Error("error revoking api key", slog.String("error", err.Error()))
+		return err
+	}
+
+	if rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the api key and its owner, and records when and from where the key was used
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key, clientIP string) (model.APIKey, model.User, error) {
+	apiKey, err := s.repo.GetAPIKeyByHash(ctx, token.Hash(key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+		}
+		s.slog.Error("error getting api key", slog.String("error", err.Error()))
+		return model.APIKey{}, model.User{}, err
+	}
+
+	if !apiKey.ExpiresAt.Time.After(time.Now()) {
+		return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+	}
+
+	user, err := s.getActiveUser(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+		}
+		return model.APIKey{}, model.User{}, err
+	}
+
+	param := db.TouchAPIKeyParams{ID: apiKey.ID, LastUsedIp: pgtype.Text{String: clientIP, Valid: clientIP != ""}}
+	if err := s.repo.TouchAPIKey(ctx, param); err != nil {
+		s.slog.Error("error recording api key usage", slog.String("error", err.Error()))
+	}
+
+	return model.DBAPIKeyToModelAPIKey(apiKey)[0], user, nil
+}
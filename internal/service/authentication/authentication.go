@@ -2,40 +2,230 @@ package authentication
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
+	"time"
 
 	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
 	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/envelope"
+	"github.com/izzanzahrial/skeleton/pkg/mailer"
 	pass "github.com/izzanzahrial/skeleton/pkg/password"
 	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/izzanzahrial/skeleton/pkg/webauthn"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
-	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
 )
 
 type authRepo interface {
 	GetuserByEmailOrUsername(ctx context.Context, param db.GetuserByEmailOrUsernameParams) (db.User, error)
 	CreateUserGoogle(ctx context.Context, param db.CreateUserGoogleParams) (db.User, error)
 	GetuserByEmail(ctx context.Context, email string) (db.User, error)
+	GetUser(ctx context.Context, id int64) (db.User, error)
+	CreateUserIdentity(ctx context.Context, arg db.CreateUserIdentityParams) (db.UserIdentity, error)
+	GetUserIdentity(ctx context.Context, arg db.GetUserIdentityParams) (db.UserIdentity, error)
+	GetUserIdentitiesByUserID(ctx context.Context, userID int64) ([]db.UserIdentity, error)
+	DeleteUserIdentity(ctx context.Context, arg db.DeleteUserIdentityParams) (int64, error)
+	UpsertUserTOTP(ctx context.Context, arg db.UpsertUserTOTPParams) (db.UserTotp, error)
+	GetUserTOTP(ctx context.Context, userID int64) (db.UserTotp, error)
+	ConfirmUserTOTP(ctx context.Context, userID int64) error
+	DeleteUserTOTP(ctx context.Context, userID int64) error
+	CreateRecoveryCode(ctx context.Context, arg db.CreateRecoveryCodeParams) error
+	DeleteRecoveryCodes(ctx context.Context, userID int64) error
+	UseRecoveryCode(ctx context.Context, arg db.UseRecoveryCodeParams) (int64, error)
+	UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error)
+	VerifyUserEmail(ctx context.Context, arg db.VerifyUserEmailParams) (int64, error)
+	CreateAPIKey(ctx context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash []byte) (db.ApiKey, error)
+	GetAPIKeysByUserID(ctx context.Context, userID int64) ([]db.ApiKey, error)
+	RevokeAPIKey(ctx context.Context, arg db.RevokeAPIKeyParams) (int64, error)
+	TouchAPIKey(ctx context.Context, arg db.TouchAPIKeyParams) error
+	UserHasPermission(ctx context.Context, arg db.UserHasPermissionParams) (bool, error)
+	GetRolePermissions(ctx context.Context, role string) ([]string, error)
+	SuspendUser(ctx context.Context, arg db.SuspendUserParams) (db.User, error)
+	UnsuspendUser(ctx context.Context, id int64) (db.User, error)
+	CreateImpersonationAuditLog(ctx context.Context, arg db.CreateImpersonationAuditLogParams) error
+	CreateWebAuthnCredential(ctx context.Context, arg db.CreateWebAuthnCredentialParams) (db.WebauthnCredential, error)
+	GetWebAuthnCredential(ctx context.Context, id []byte) (db.WebauthnCredential, error)
+	GetWebAuthnCredentialsByUserID(ctx context.Context, userID int64) ([]db.WebauthnCredential, error)
+	UpdateWebAuthnCredentialSignCount(ctx context.Context, arg db.UpdateWebAuthnCredentialSignCountParams) error
+	DeleteWebAuthnCredential(ctx context.Context, arg db.DeleteWebAuthnCredentialParams) (int64, error)
 }
 
 type authCache interface {
 	SetAuthToken(ctx context.Context, token token.Token) error
+	SetRefreshToken(ctx context.Context, token token.Token) error
+	UseRefreshToken(ctx context.Context, hash []byte) (token.Token, error)
+	RevokeTokenFamily(ctx context.Context, family string, ttl time.Duration) error
+	IsTokenFamilyRevoked(ctx context.Context, family string) (bool, error)
+	GetRefreshToken(ctx context.Context, hash []byte) (token.Token, error)
+	DenyAccessToken(ctx context.Context, jti string, ttl time.Duration) error
+	RevokeUserTokens(ctx context.Context, userID int64, at time.Time, ttl time.Duration) error
+	CreateSession(ctx context.Context, session model.Session, ttl time.Duration) error
+	GetSession(ctx context.Context, id string) (model.Session, error)
+	GetUserSessions(ctx context.Context, userID int64) ([]model.Session, error)
+	TouchSession(ctx context.Context, id, ip string, at time.Time) (bool, error)
+	SetOAuthState(ctx context.Context, provider, state string, value cache.OAuthState, ttl time.Duration) error
+	ConsumeOAuthState(ctx context.Context, provider, state string) (cache.OAuthState, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	BlockLogin(ctx context.Context, key string, ttl time.Duration) error
+	LoginBlockedFor(ctx context.Context, key string) (time.Duration, error)
+	ResetLoginFailures(ctx context.Context, keys ...string) error
+	SetMFAChallenge(ctx context.Context, token token.Token) error
+	GetMFAChallenge(ctx context.Context, hash []byte) (int64, error)
+	RecordMFAChallengeFailure(ctx context.Context, hash []byte) (int64, error)
+	DeleteMFAChallenge(ctx context.Context, hash []byte) (bool, error)
+	UseTOTPStep(ctx context.Context, userID int64, step int64, ttl time.Duration) (bool, error)
+	SetPasswordResetToken(ctx context.Context, token token.Token) error
+	GetPasswordResetToken(ctx context.Context, hash []byte) (int64, error)
+	ConsumePasswordResetToken(ctx context.Context, hash []byte) (int64, error)
+	RecordPasswordResetEmail(ctx context.Context, email string, window time.Duration) (int64, time.Duration, error)
+	SetEmailVerificationToken(ctx context.Context, token token.Token, email string) error
+	ConsumeEmailVerificationToken(ctx context.Context, hash []byte) (cache.EmailVerification, error)
+	RecordVerificationEmail(ctx context.Context, email string, window time.Duration) (int64, time.Duration, error)
+	SetMagicLinkToken(ctx context.Context, token token.Token, email string) error
+	ConsumeMagicLinkToken(ctx context.Context, hash []byte) (cache.MagicLink, error)
+	RecordMagicLinkEmail(ctx context.Context, email string, window time.Duration) (int64, time.Duration, error)
+	SetWebAuthnChallenge(ctx context.Context, ceremony string, challenge []byte, userID int64, ttl time.Duration) error
+	ConsumeWebAuthnChallenge(ctx context.Context, ceremony string, challenge []byte) (int64, error)
+	SetUserSuspension(ctx context.Context, userID int64, suspension model.Suspension) error
+	DeleteUserSuspension(ctx context.Context, userID int64) error
 }
+
+const (
+	refreshTokenTTL = token.RefreshTokenTTL
+	// OAuthStateTTL is how long the user has to finish logging in at the oauth provider
+	OAuthStateTTL = 10 * time.Minute
+	// uniqueViolation is the postgres error code of a unique constraint violation
+	uniqueViolation = "23505"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidOAuthState   = errors.New("invalid oauth state")
+	ErrRedirectNotAllowed  = errors.New("redirect url is not allowed")
+	// ErrAccountExists is returned when an unlinked identity has the email of an existing account,
+	// the user has to log in to that account first and link the identity
+	ErrAccountExists    = errors.New("an account with this email already exists, log in and link this provider to it")
+	ErrIdentityLinked   = errors.New("identity is already linked to another account")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrLastLoginMethod  = errors.New("can't unlink the last login method of the account")
+)
+
 type Service struct {
 	repo  authRepo
 	cache authCache
 	slog  *slog.Logger
+	// redirectAllowlist holds the origins the user can be sent back to after an oauth login
+	redirectAllowlist []string
+	mailer            mailer.Mailer
+	mailFrom          string
+	// linkBaseURL is the frontend url the links sent by email point to
+	linkBaseURL string
+	hasher      *pass.Hasher
+	// requireVerifiedEmail rejects the login of native users that didn't verify their email
+	requireVerifiedEmail bool
+	// fieldKeys encrypts the oauth refresh tokens and the totp secrets, the refresh tokens aren't stored
+	// and the totp secrets are stored in plaintext when it's not set
+	fieldKeys *envelope.Keyring
+	// passkeys is the webauthn relying party, passkeys are disabled when it's not set
+	passkeys *webauthn.RelyingParty
 }
 
 type ServiceConfig func(s *Service) error
 
-func NewService(repo authRepo, cache authCache, slog *slog.Logger) *Service {
-	return &Service{
+func NewService(repo authRepo, cache authCache, slog *slog.Logger, cfgs ...ServiceConfig) (*Service, error) {
+	s := &Service{
 		repo:  repo,
 		cache: cache,
 		slog:  slog,
+		// emails are only logged until a mailer is configured
+		mailer: mailer.NewLog(slog),
+	}
+
+	hasher, err := pass.New(pass.DefaultParams)
+	if err != nil {
+		return nil, err
+	}
+	s.hasher = hasher
+
+	for _, cfg := range cfgs {
+		if err := cfg(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// WithRedirectAllowlist sets the origins, in the form of scheme://host[:port], that are allowed as post login redirect
+func WithRedirectAllowlist(origins []string) ServiceConfig {
+	return func(s *Service) error {
+		for _, origin := range origins {
+			u, err := url.Parse(origin)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid redirect origin %q", origin)
+			}
+			s.redirectAllowlist = append(s.redirectAllowlist, u.Scheme+"://"+u.Host)
+		}
+		return nil
+	}
+}
+
+// WithMailer sets the mailer used for the emails sent to users, links inside them point to linkBaseURL
+func WithMailer(m mailer.Mailer, from, linkBaseURL string) ServiceConfig {
+	return func(s *Service) error {
+		u, err := url.Parse(linkBaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid link base url %q", linkBaseURL)
+		}
+
+		s.mailer = m
+		s.mailFrom = from
+		s.linkBaseURL = strings.TrimSuffix(linkBaseURL, "/")
+		return nil
+	}
+}
+
+// WithPasswordHasher sets the hasher used for new passwords, a password hashed any other way is rehashed on login
+func WithPasswordHasher(hasher *pass.Hasher) ServiceConfig {
+	return func(s *Service) error {
+		s.hasher = hasher
+		return nil
+	}
+}
+
+// WithFieldEncryption sets the keyring encrypting the oauth refresh tokens before they are stored
+func WithFieldEncryption(keys *envelope.Keyring) ServiceConfig {
+	return func(s *Service) error {
+		s.fieldKeys = keys
+		return nil
+	}
+}
+
+// WithPasskeys enables the passkey registration and login for the relying party
+func WithPasskeys(rp *webauthn.RelyingParty) ServiceConfig {
+	return func(s *Service) error {
+		s.passkeys = rp
+		return nil
+	}
+}
+
+// WithRequireVerifiedEmail makes native users verify their email before they can log in
+func WithRequireVerifiedEmail(required bool) ServiceConfig {
+	return func(s *Service) error {
+		s.requireVerifiedEmail = required
+		return nil
 	}
 }
 
@@ -63,7 +253,13 @@ func NewService(repo authRepo, cache authCache, slog *slog.Logger) *Service {
 // 	}
 // }
 
-func (s *Service) GetuserByEmailOrUsername(ctx context.Context, email, username, password string) (model.User, error) {
+// GetuserByEmailOrUsername checks the password of the user, failed attempts are throttled per account and per client ip
+func (s *Service) GetuserByEmailOrUsername(ctx context.Context, email, username, password, clientIP string) (model.User, error) {
+	ipKey := ipThrottleKey(clientIP)
+	if err := s.checkLoginBlocked(ctx, ipKey); err != nil {
+		return model.User{}, err
+	}
+
 	param := db.GetuserByEmailOrUsernameParams{
 		Email:    email,
 		Username: pgtype.Text{String: username, Valid: true},
@@ -73,29 +269,116 @@ func (s *Service) GetuserByEmailOrUsername(ctx context.Context, email, username,
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
 			s.slog.Error("error getting user", slog.String("error", err.Error()))
+			return model.User{}, err
+		}
+
+		accountKey := accountThrottleKey(email, username)
+		if err := s.checkLoginBlocked(ctx, accountKey); err != nil {
+			return model.User{}, err
+		}
+		if err := s.recordLoginFailure(ctx, accountKey, ipKey); err != nil {
+			return model.User{}, err
 		}
+		return model.User{}, pgx.ErrNoRows
+	}
+
+	accountKey := userThrottleKey(user.ID)
+	if err := s.checkLoginBlocked(ctx, accountKey); err != nil {
 		return model.User{}, err
 	}
 
 	ok, err := pass.Check(password, user.PasswordHash)
 	if !ok || err != nil {
-		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if !errors.Is(err, pass.ErrMismatchedHashAndPassword) {
 			s.slog.Error("error checking password", slog.String("error", err.Error()))
 		}
+		if err := s.recordLoginFailure(ctx, accountKey, ipKey); err != nil {
+			return model.User{}, err
+		}
+		return model.User{}, ErrInvalidCredentials
+	}
+
+	if err := s.cache.ResetLoginFailures(ctx, accountKey); err != nil {
+		s.slog.Error("error resetting login failures", slog.String("error", err.Error()))
+	}
+
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+
+	// checked after the password, so it doesn't tell whether an email has an account
+	if s.requireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+		return model.User{}, ErrEmailNotVerified
+	}
+
+	modelUser := model.DBUserToModelUser(user)[0]
+	if err := checkSuspended(modelUser); err != nil {
 		return model.User{}, err
 	}
 
-	return model.DBUserToModelUser(user)[0], nil
+	return modelUser, nil
 }
 
-func (s *Service) CreateOrCheckGoogleUser(ctx context.Context, user model.User) (model.User, error) {
+// rehashPassword upgrades the stored hash to the configured algorithm and cost, the login still succeeds when it fails
+func (s *Service) rehashPassword(ctx context.Context, userID int64, password string) {
+	passwordHash, err := s.hasher.Generate(password)
+	if err != nil {
+		s.slog.Error("error generating password hash", slog.String("error", err.Error()))
+		return
+	}
+
+	if _, err := s.repo.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{PasswordHash: passwordHash, ID: userID}); err != nil {
+		s.slog.Error("error updating password hash", slog.String("error", err.Error()))
+	}
+}
+
+// UnlockLogin lifts the password and mfa lockouts of the user account, and the lockout of the client ip when it's given
+func (s *Service) UnlockLogin(ctx context.Context, userID int64, clientIP string) error {
+	keys := []string{userThrottleKey(userID), mfaThrottleKey(userID)}
+	if clientIP != "" {
+		keys = append(keys, ipThrottleKey(clientIP))
+	}
+
+	if err := s.cache.ResetLoginFailures(ctx, keys...); err != nil {
+		s.slog.Error("error resetting login failures", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// LoginOAuthUser returns the user the identity is linked to, or creates a new user with the identity.
+// An identity is never linked to an existing account using only the email, see LinkOAuthIdentity
+func (s *Service) LoginOAuthUser(ctx context.Context, identity model.UserIdentity, user model.User) (model.User, error) {
+	dbIdentity, err := s.repo.GetUserIdentity(ctx, db.GetUserIdentityParams{Provider: identity.Provider, Subject: identity.Subject})
+	if err == nil {
+		user, err := s.getActiveUser(ctx, dbIdentity.UserID)
+		if err != nil {
+			return model.User{}, err
+		}
+		if err := checkSuspended(user); err != nil {
+			return model.User{}, err
+		}
+		return user, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		s.slog.Error("error getting user identity", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
 	dbUser, err := s.repo.GetuserByEmail(ctx, user.Email)
 	if err == nil {
-		return model.DBUserToModelUser(dbUser)[0], nil
+		return s.linkLegacyUser(ctx, dbUser, identity)
 	}
 
 	if !errors.Is(err, pgx.ErrNoRows) {
-		s.slog.Error("error creating user", slog.String("error", err.Error()))
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	refreshToken, err := s.encryptRefreshToken(user.RefreshToken)
+	if err != nil {
 		return model.User{}, err
 	}
 
@@ -104,15 +387,402 @@ func (s *Service) CreateOrCheckGoogleUser(ctx context.Context, user model.User)
 		FirstName:    pgtype.Text{String: user.FirstName, Valid: true},
 		LastName:     pgtype.Text{String: user.LastName, Valid: true},
 		PictureUrl:   pgtype.Text{String: user.PictureUrl, Valid: true},
-		RefreshToken: pgtype.Text{String: user.RefreshToken, Valid: true},
-		Role:         db.RolesUser,
-		Origin:       db.OriginsGoogle,
+		RefreshToken: refreshToken,
+		Role:         string(model.RolesUser),
+		Origin:       db.Origins(user.Origin),
 	}
 
 	dbUser, err = s.repo.CreateUserGoogle(ctx, param)
 	if err != nil {
+		s.slog.Error("error creating user", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	if _, err := s.createIdentity(ctx, dbUser.ID, identity); err != nil {
 		return model.User{}, err
 	}
 
 	return model.DBUserToModelUser(dbUser)[0], nil
 }
+
+// encryptRefreshToken encrypts the oauth refresh token for the users table
+func (s *Service) encryptRefreshToken(refreshToken string) (pgtype.Text, error) {
+	if refreshToken == "" || s.fieldKeys == nil {
+		return pgtype.Text{}, nil
+	}
+
+	encrypted, err := s.fieldKeys.Encrypt([]byte(refreshToken), []byte(model.RefreshTokenField))
+	if err != nil {
+		s.slog.Error("error encrypting refresh token", slog.String("error", err.Error()))
+		return pgtype.Text{}, err
+	}
+
+	return pgtype.Text{String: encrypted, Valid: true}, nil
+}
+
+// linkLegacyUser links the identity to a user that was created by the same provider before identities existed,
+// any other user with the same email has to link the identity explicitly. Only google and auth0 users predate
+// identities, the oidc origin is shared by every configured provider so it can't tell which one created the user
+func (s *Service) linkLegacyUser(ctx context.Context, dbUser db.User, identity model.UserIdentity) (model.User, error) {
+	origin := model.Origins(dbUser.Origin)
+	if (origin != model.GoogleOrigin && origin != model.Auth0Origin) || string(origin) != identity.Provider {
+		return model.User{}, ErrAccountExists
+	}
+
+	identities, err := s.repo.GetUserIdentitiesByUserID(ctx, dbUser.ID)
+	if err != nil {
+		s.slog.Error("error getting user identities", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+	if len(identities) > 0 {
+		return model.User{}, ErrAccountExists
+	}
+
+	user := model.DBUserToModelUser(dbUser)[0]
+	if err := checkSuspended(user); err != nil {
+		return model.User{}, err
+	}
+
+	if _, err := s.createIdentity(ctx, dbUser.ID, identity); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// LinkOAuthIdentity links the identity to the user, the user proves the ownership of the account
+// by being logged in when starting the oauth flow
+func (s *Service) LinkOAuthIdentity(ctx context.Context, userID int64, identity model.UserIdentity) (model.UserIdentity, error) {
+	dbIdentity, err := s.repo.GetUserIdentity(ctx, db.GetUserIdentityParams{Provider: identity.Provider, Subject: identity.Subject})
+	if err == nil {
+		if dbIdentity.UserID != userID {
+			return model.UserIdentity{}, ErrIdentityLinked
+		}
+		return model.DBUserIdentityToModelUserIdentity(dbIdentity)[0], nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		s.slog.Error("error getting user identity", slog.String("error", err.Error()))
+		return model.UserIdentity{}, err
+	}
+
+	return s.createIdentity(ctx, userID, identity)
+}
+
+func (s *Service) GetUserIdentities(ctx context.Context, userID int64) ([]model.UserIdentity, error) {
+	identities, err := s.repo.GetUserIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting user identities", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return model.DBUserIdentityToModelUserIdentity(identities...), nil
+}
+
+// UnlinkOAuthIdentity removes the identity of the provider from the user,
+// as long as the user is still able to log in using a password, a passkey or another identity
+func (s *Service) UnlinkOAuthIdentity(ctx context.Context, userID int64, provider string) error {
+	loginMethods, err := s.countLoginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if loginMethods <= 1 {
+		return ErrLastLoginMethod
+	}
+
+	rows, err := s.repo.DeleteUserIdentity(ctx, db.DeleteUserIdentityParams{UserID: userID, Provider: provider})
+	if err != nil {
+		s.slog.Error("error deleting user identity", slog.String("error", err.Error()))
+		return err
+	}
+	if rows == 0 {
+		return ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func (s *Service) createIdentity(ctx context.Context, userID int64, identity model.UserIdentity) (model.UserIdentity, error) {
+	param := db.CreateUserIdentityParams{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	dbIdentity, err := s.repo.CreateUserIdentity(ctx, param)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return model.UserIdentity{}, ErrIdentityLinked
+		}
+		s.slog.Error("error creating user identity", slog.String("error", err.Error()))
+		return model.UserIdentity{}, err
+	}
+
+	return model.DBUserIdentityToModelUserIdentity(dbIdentity)[0], nil
+}
+
+func (s *Service) getActiveUser(ctx context.Context, userID int64) (model.User, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("error getting user", slog.String("error", err.Error()))
+		}
+		return model.User{}, err
+	}
+
+	if user.DeletedAt.Valid {
+		return model.User{}, pgx.ErrNoRows
+	}
+
+	return model.DBUserToModelUser(user)[0], nil
+}
+
+// NewSession starts a new refresh token family for the user and records the device it was issued to,
+// the family of the returned token is the session id
+func (s *Service) NewSession(ctx context.Context, userID int64, userAgent, clientIP string) (*token.Token, error) {
+	tkn, err := s.issueRefreshToken(ctx, userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.createSession(ctx, userID, tkn.Family, userAgent, clientIP); err != nil {
+		return nil, err
+	}
+
+	return tkn, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family.
+// Presenting a refresh token that was already used revokes the whole family,
+// since either the user or an attacker is holding a stolen token
+func (s *Service) RotateRefreshToken(ctx context.Context, refreshToken, userAgent, clientIP string) (model.User, *token.Token, error) {
+	old, err := s.cache.UseRefreshToken(ctx, token.Hash(refreshToken))
+	if err != nil {
+		switch {
+		case errors.Is(err, cache.ErrTokenNotFound):
+			return model.User{}, nil, ErrInvalidRefreshToken
+		case errors.Is(err, cache.ErrTokenReused):
+			s.slog.Warn("refresh token reuse detected, revoking token family", slog.Int64("user_id", old.User.ID))
+			if err := s.cache.RevokeTokenFamily(ctx, old.Family, refreshTokenTTL); err != nil {
+				s.slog.Error("error revoking token family", slog.String("error", err.Error()))
+				return model.User{}, nil, err
+			}
+			return model.User{}, nil, ErrInvalidRefreshToken
+		default:
+			s.slog.Error("error using refresh token", slog.String("error", err.Error()))
+			return model.User{}, nil, err
+		}
+	}
+
+	revoked, err := s.cache.IsTokenFamilyRevoked(ctx, old.Family)
+	if err != nil {
+		s.slog.Error("error checking token family", slog.String("error", err.Error()))
+		return model.User{}, nil, err
+	}
+	if revoked {
+		return model.User{}, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetUser(ctx, old.User.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.User{}, nil, ErrInvalidRefreshToken
+		}
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return model.User{}, nil, err
+	}
+
+	if user.DeletedAt.Valid {
+		return model.User{}, nil, ErrInvalidRefreshToken
+	}
+
+	if suspension, ok := model.DBUserSuspension(user, time.Now()); ok {
+		return model.User{}, nil, &SuspendedError{Suspension: suspension}
+	}
+
+	active, err := s.cache.TouchSession(ctx, old.Family, clientIP, time.Now())
+	if err != nil {
+		s.slog.Error("error touching session", slog.String("error", err.Error()))
+		return model.User{}, nil, err
+	}
+	if !active {
+		// the session of the family was ended, only families started before sessions were recorded get one
+		if old.HasSession {
+			return model.User{}, nil, ErrInvalidRefreshToken
+		}
+		if err := s.createSession(ctx, user.ID, old.Family, userAgent, clientIP); err != nil {
+			if errors.Is(err, cache.ErrSessionRevoked) {
+				return model.User{}, nil, ErrInvalidRefreshToken
+			}
+			return model.User{}, nil, err
+		}
+	}
+
+	tkn, err := s.issueRefreshToken(ctx, user.ID, old.Family)
+	if err != nil {
+		return model.User{}, nil, err
+	}
+
+	return model.DBUserToModelUser(user)[0], tkn, nil
+}
+
+func (s *Service) issueRefreshToken(ctx context.Context, userID int64, family string) (*token.Token, error) {
+	tkn, err := token.NewRefresh(userID, family, refreshTokenTTL)
+	if err != nil {
+		s.slog.Error("error creating refresh token", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if err := s.cache.SetRefreshToken(ctx, *tkn); err != nil {
+		s.slog.Error("error storing refresh token", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return tkn, nil
+}
+
+// Logout denies the access token until it expires, and ends its session and the refresh token family when it's given
+func (s *Service) Logout(ctx context.Context, claims *token.JwtCustomClaims, refreshToken string) error {
+	var ttl time.Duration
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+
+	if err := s.cache.DenyAccessToken(ctx, claims.ID, ttl); err != nil {
+		s.slog.Error("error denying access token", slog.String("error", err.Error()))
+		return err
+	}
+
+	if claims.SessionID != "" {
+		if err := s.cache.RevokeTokenFamily(ctx, claims.SessionID, refreshTokenTTL); err != nil {
+			s.slog.Error("error revoking session", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	// a rotated token still ends its session
+	tkn, err := s.cache.GetRefreshToken(ctx, token.Hash(refreshToken))
+	if err != nil && !errors.Is(err, cache.ErrTokenReused) {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return nil
+		}
+		s.slog.Error("error getting refresh token", slog.String("error", err.Error()))
+		return err
+	}
+
+	// a user can only log out their own session
+	if tkn.User.ID != claims.UserID {
+		return nil
+	}
+
+	if err := s.cache.RevokeTokenFamily(ctx, tkn.Family, refreshTokenTTL); err != nil {
+		s.slog.Error("error revoking token family", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes every access and refresh token that was issued to the user so far
+func (s *Service) RevokeUserSessions(ctx context.Context, userID int64) error {
+	if err := s.cache.RevokeUserTokens(ctx, userID, time.Now(), refreshTokenTTL); err != nil {
+		s.slog.Error("error revoking user tokens", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// OAuthFlow is an oauth flow that was just started, the browser that started it keeps the Binding
+// and must send it back with the State, so the callback url can't be finished in another browser
+type OAuthFlow struct {
+	State    string
+	Verifier string
+	Binding  string
+}
+
+// NewOAuthState starts an oauth flow for the provider and returns the state and PKCE verifier for it,
+// the redirect url is optional and must be on the allowlist. The identity is linked to linkUserID when it's set
+func (s *Service) NewOAuthState(ctx context.Context, provider, redirectURL string, linkUserID int64) (OAuthFlow, error) {
+	if redirectURL != "" && !s.isRedirectAllowed(redirectURL) {
+		return OAuthFlow{}, ErrRedirectNotAllowed
+	}
+
+	state, err := randomString()
+	if err != nil {
+		s.slog.Error("error generating oauth state", slog.String("error", err.Error()))
+		return OAuthFlow{}, err
+	}
+
+	binding, err := randomString()
+	if err != nil {
+		s.slog.Error("error generating oauth state binding", slog.String("error", err.Error()))
+		return OAuthFlow{}, err
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	value := cache.OAuthState{Verifier: verifier, RedirectURL: redirectURL, LinkUserID: linkUserID, BindingHash: token.Hash(binding)}
+	if err := s.cache.SetOAuthState(ctx, provider, state, value, OAuthStateTTL); err != nil {
+		s.slog.Error("error storing oauth state", slog.String("error", err.Error()))
+		return OAuthFlow{}, err
+	}
+
+	return OAuthFlow{State: state, Verifier: verifier, Binding: binding}, nil
+}
+
+// ConsumeOAuthState checks the state returned by the provider and the binding of the browser that started
+// the flow, a state can only be used once
+func (s *Service) ConsumeOAuthState(ctx context.Context, provider, state, binding string) (cache.OAuthState, error) {
+	if state == "" {
+		return cache.OAuthState{}, ErrInvalidOAuthState
+	}
+
+	value, err := s.cache.ConsumeOAuthState(ctx, provider, state)
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return cache.OAuthState{}, ErrInvalidOAuthState
+		}
+		s.slog.Error("error consuming oauth state", slog.String("error", err.Error()))
+		return cache.OAuthState{}, err
+	}
+
+	// otherwise anyone could get a victim logged in to the attacker account with the callback url of their own login,
+	// or get the provider identity of the victim linked to the attacker account
+	if subtle.ConstantTimeCompare(token.Hash(binding), value.BindingHash) != 1 {
+		return cache.OAuthState{}, ErrInvalidOAuthState
+	}
+
+	return value, nil
+}
+
+func randomString() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+func (s *Service) isRedirectAllowed(redirectURL string) bool {
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil {
+		return false
+	}
+
+	origin := u.Scheme + "://" + u.Host
+	for _, allowed := range s.redirectAllowlist {
+		if origin == allowed {
+			return true
+		}
+	}
+
+	return false
+}
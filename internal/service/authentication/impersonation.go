@@ -0,0 +1,84 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/model"
+)
+
+var (
+	ErrImpersonateSelf         = errors.New("can't impersonate yourself")
+	ErrImpersonationNotAllowed = errors.New("user can't be impersonated")
+)
+
+// StartImpersonation returns the user the actor is about to impersonate, users who can impersonate
+// can't be impersonated themselves, and the actor must hold every permission of the user,
+// so impersonating never gives the actor a permission it didn't have
+func (s *Service) StartImpersonation(ctx context.Context, actorID, userID int64) (model.User, error) {
+	if actorID == userID {
+		return model.User{}, ErrImpersonateSelf
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	canImpersonate, err := s.repo.UserHasPermission(ctx, db.UserHasPermissionParams{ID: userID, Permission: model.PermissionUsersImpersonate})
+	if err != nil {
+		s.slog.Error("error checking user permission", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+	if canImpersonate {
+		return model.User{}, ErrImpersonationNotAllowed
+	}
+
+	// the role of the actor is read again, the one in its token may be outdated
+	actor, err := s.getActiveUser(ctx, actorID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	actorPermissions, err := s.repo.GetRolePermissions(ctx, string(actor.Role))
+	if err != nil {
+		s.slog.Error("error getting role permissions", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	userPermissions, err := s.repo.GetRolePermissions(ctx, string(user.Role))
+	if err != nil {
+		s.slog.Error("error getting role permissions", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	for _, permission := range userPermissions {
+		if !slices.Contains(actorPermissions, permission) {
+			return model.User{}, ErrImpersonationNotAllowed
+		}
+	}
+
+	return user, nil
+}
+
+// RecordImpersonation writes the audit log of a request made with an impersonation token
+func (s *Service) RecordImpersonation(ctx context.Context, audit model.ImpersonationAudit) error {
+	param := db.CreateImpersonationAuditLogParams{
+		ActorID: audit.ActorID,
+		UserID:  audit.UserID,
+		TokenID: audit.TokenID,
+		Method:  audit.Method,
+		Path:    audit.Path,
+		Ip:      audit.IP,
+	}
+
+	if err := s.repo.CreateImpersonationAuditLog(ctx, param); err != nil {
+		s.slog.Error("error creating impersonation audit log", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/mailer"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// magicLinkTTL is how long the link in the magic link email can be used
+	magicLinkTTL = 15 * time.Minute
+	// maxMagicLinkEmails is how many magic links an address can receive inside magicLinkEmailWindow
+	maxMagicLinkEmails   = 5
+	magicLinkEmailWindow = time.Hour
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired magic link")
+
+// SendMagicLink emails a single use login link. Nothing is returned when there is no user with the email,
+// so the endpoint can't be used to find out whether an email has an account
+func (s *Service) SendMagicLink(ctx context.Context, email string) error {
+	// an empty email matches any user in GetuserByEmail
+	if email == "" {
+		return nil
+	}
+
+	// the limit is checked before the lookup, so it applies the same way to unknown emails
+	count, retryAfter, err := s.cache.RecordMagicLinkEmail(ctx, strings.ToLower(email), magicLinkEmailWindow)
+	if err != nil {
+		s.slog.Error("error recording magic link email", slog.String("error", err.Error()))
+		return err
+	}
+	if count > maxMagicLinkEmails {
+		return &TooManyEmailsError{RetryAfter: retryAfter}
+	}
+
+	user, err := s.repo.GetuserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return err
+	}
+
+	if user.DeletedAt.Valid {
+		return nil
+	}
+
+	tkn, err := token.New(user.ID, magicLinkTTL)
+	if err != nil {
+		s.slog.Error("error creating magic link token", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.cache.SetMagicLinkToken(ctx, *tkn, user.Email); err != nil {
+		s.slog.Error("error storing magic link token", slog.String("error", err.Error()))
+		return err
+	}
+
+	link := s.linkBaseURL + "/login/magic-link?token=" + url.QueryEscape(tkn.PlainText)
+	s.sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Someone asked to log in to your account without a password.\n\n"+
+			"Open the link below to log in, it can be used once and expires in %d minutes:\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", int(magicLinkTTL.Minutes()), link),
+	})
+
+	return nil
+}
+
+// LoginMagicLink exchanges the token of a magic link for the user it was sent to.
+// Opening the link proves the user owns the email, so the email is marked as verified
+func (s *Service) LoginMagicLink(ctx context.Context, magicLinkToken string) (model.User, error) {
+	magicLink, err := s.cache.ConsumeMagicLinkToken(ctx, token.Hash(magicLinkToken))
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return model.User{}, ErrInvalidMagicLink
+		}
+		s.slog.Error("error getting magic link token", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	user, err := s.getActiveUser(ctx, magicLink.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.User{}, ErrInvalidMagicLink
+		}
+		return model.User{}, err
+	}
+
+	// the user changed the email after the link was sent
+	if user.Email != magicLink.Email {
+		return model.User{}, ErrInvalidMagicLink
+	}
+
+	if err := checkSuspended(user); err != nil {
+		return model.User{}, err
+	}
+
+	if user.EmailVerifiedAt.IsZero() {
+		if _, err := s.repo.VerifyUserEmail(ctx, db.VerifyUserEmailParams{ID: user.ID, Email: user.Email}); err != nil {
+			s.slog.Error("error verifying email", slog.String("error", err.Error()))
+			return model.User{}, err
+		}
+		user.EmailVerifiedAt = time.Now()
+	}
+
+	return user, nil
+}
@@ -0,0 +1,367 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/envelope"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/izzanzahrial/skeleton/pkg/totp"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// mfaChallengeTTL is how long the user has to send the totp code after the password was checked
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengeMaxFailures is the number of wrong codes after which the challenge is deleted
+	mfaChallengeMaxFailures = 5
+	recoveryCodeCount       = 10
+	totpIssuer              = "skeleton"
+)
+
+var (
+	ErrMFANativeOnly       = errors.New("two-factor authentication is only available for accounts with a password")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+)
+
+// TOTPEnrollment is shown to the user once, the uri is rendered as a QR code for authenticator apps
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnrollTOTP generates a new totp secret for the user, it's only used for login after ConfirmTOTP
+func (s *Service) EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollment, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return TOTPEnrollment{}, err
+	}
+
+	if len(user.PasswordHash) == 0 {
+		return TOTPEnrollment{}, ErrMFANativeOnly
+	}
+
+	enabled, err := s.IsMFAEnabled(ctx, userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if enabled {
+		return TOTPEnrollment{}, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.slog.Error("error generating totp secret", slog.String("error", err.Error()))
+		return TOTPEnrollment{}, err
+	}
+
+	storedSecret, err := s.encryptTOTPSecret(secret)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	if _, err := s.repo.UpsertUserTOTP(ctx, db.UpsertUserTOTPParams{UserID: userID, Secret: storedSecret}); err != nil {
+		s.slog.Error("error storing totp secret", slog.String("error", err.Error()))
+		return TOTPEnrollment{}, err
+	}
+
+	return TOTPEnrollment{Secret: secret, URI: totp.ProvisioningURI(totpIssuer, user.Email, secret)}, nil
+}
+
+// ConfirmTOTP enables the enrolled totp once the user proves the authenticator app works,
+// and returns the recovery codes, they are only shown this once
+func (s *Service) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	userTOTP, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMFANotEnrolled
+		}
+		s.slog.Error("error getting totp", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if userTOTP.ConfirmedAt.Valid {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.decryptTOTPSecret(userTOTP.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTOTP(ctx, userID, secret, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ConfirmUserTOTP(ctx, userID); err != nil {
+		s.slog.Error("error confirming totp", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return s.regenerateRecoveryCodes(ctx, userID)
+}
+
+// DisableTOTP turns off two-factor authentication, it needs a valid code or recovery code
+func (s *Service) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	if err := s.verifySecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteUserTOTP(ctx, userID); err != nil {
+		s.slog.Error("error deleting totp", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.repo.DeleteRecoveryCodes(ctx, userID); err != nil {
+		s.slog.Error("error deleting recovery codes", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) IsMFAEnabled(ctx context.Context, userID int64) (bool, error) {
+	userTOTP, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		s.slog.Error("error getting totp", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return userTOTP.ConfirmedAt.Valid, nil
+}
+
+// NewMFAChallenge is given instead of a jwt when the password of a user with mfa was checked
+func (s *Service) NewMFAChallenge(ctx context.Context, userID int64) (*token.Token, error) {
+	tkn, err := token.New(userID, mfaChallengeTTL)
+	if err != nil {
+		s.slog.Error("error creating mfa challenge", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if err := s.cache.SetMFAChallenge(ctx, *tkn); err != nil {
+		s.slog.Error("error storing mfa challenge", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return tkn, nil
+}
+
+// VerifyMFAChallenge exchanges the challenge and a totp or recovery code for the user,
+// the challenge is deleted after too many wrong codes so the password has to be checked again
+func (s *Service) VerifyMFAChallenge(ctx context.Context, challenge, code string) (model.User, error) {
+	hash := token.Hash(challenge)
+
+	userID, err := s.cache.GetMFAChallenge(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return model.User{}, ErrInvalidMFAChallenge
+		}
+		s.slog.Error("error getting mfa challenge", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	if err := s.verifySecondFactor(ctx, userID, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return model.User{}, err
+		}
+
+		failures, cacheErr := s.cache.RecordMFAChallengeFailure(ctx, hash)
+		if cacheErr != nil {
+			s.slog.Error("error recording mfa challenge failure", slog.String("error", cacheErr.Error()))
+			return model.User{}, cacheErr
+		}
+		if failures >= mfaChallengeMaxFailures {
+			if _, cacheErr := s.cache.DeleteMFAChallenge(ctx, hash); cacheErr != nil {
+				s.slog.Error("error deleting mfa challenge", slog.String("error", cacheErr.Error()))
+			}
+		}
+		return model.User{}, err
+	}
+
+	deleted, err := s.cache.DeleteMFAChallenge(ctx, hash)
+	if err != nil {
+		s.slog.Error("error deleting mfa challenge", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+	if !deleted {
+		return model.User{}, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if err := checkSuspended(user); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// verifySecondFactor accepts either a totp code or an unused recovery code, wrong codes are throttled per user
+// so a correct password can't be used to get unlimited guesses through new challenges
+func (s *Service) verifySecondFactor(ctx context.Context, userID int64, code string) error {
+	key := mfaThrottleKey(userID)
+	if err := s.checkLoginBlocked(ctx, key); err != nil {
+		return err
+	}
+
+	if err := s.checkSecondFactor(ctx, userID, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return err
+		}
+		if recordErr := s.recordFailure(ctx, key, mfaThrottle); recordErr != nil {
+			return recordErr
+		}
+		return err
+	}
+
+	if err := s.cache.ResetLoginFailures(ctx, key); err != nil {
+		s.slog.Error("error resetting mfa failures", slog.String("error", err.Error()))
+	}
+
+	return nil
+}
+
+func (s *Service) checkSecondFactor(ctx context.Context, userID int64, code string) error {
+	userTOTP, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMFANotEnrolled
+		}
+		s.slog.Error("error getting totp", slog.String("error", err.Error()))
+		return err
+	}
+
+	if !userTOTP.ConfirmedAt.Valid {
+		return ErrMFANotEnrolled
+	}
+
+	if len(code) == totp.Digits {
+		secret, err := s.decryptTOTPSecret(userTOTP.Secret)
+		if err != nil {
+			return err
+		}
+		return s.checkTOTP(ctx, userID, secret, code)
+	}
+
+	rows, err := s.repo.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{UserID: userID, CodeHash: token.Hash(normalizeRecoveryCode(code))})
+	if err != nil {
+		s.slog.Error("error using recovery code", slog.String("error", err.Error()))
+		return err
+	}
+	if rows == 0 {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// encryptTOTPSecret encrypts the secret for the user_totp table
+func (s *Service) encryptTOTPSecret(secret string) (string, error) {
+	if s.fieldKeys == nil {
+		return secret, nil
+	}
+
+	encrypted, err := s.fieldKeys.Encrypt([]byte(secret), []byte(model.TOTPSecretField))
+	if err != nil {
+		s.slog.Error("error encrypting totp secret", slog.String("error", err.Error()))
+		return "", err
+	}
+
+	return encrypted, nil
+}
+
+// decryptTOTPSecret accepts the plaintext secrets stored before the encryption was added,
+// cmd/reencrypt encrypts them
+func (s *Service) decryptTOTPSecret(value string) (string, error) {
+	if !envelope.IsEncrypted(value) {
+		return value, nil
+	}
+
+	if s.fieldKeys == nil {
+		err := errors.New("totp secret is encrypted but field encryption isn't configured")
+		s.slog.Error("error decrypting totp secret", slog.String("error", err.Error()))
+		return "", err
+	}
+
+	secret, err := s.fieldKeys.Decrypt(value, []byte(model.TOTPSecretField))
+	if err != nil {
+		s.slog.Error("error decrypting totp secret", slog.String("error", err.Error()))
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+// checkTOTP validates the code and rejects it when it was already used
+func (s *Service) checkTOTP(ctx context.Context, userID int64, secret, code string) error {
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// a step can't be accepted anymore once every step around it has passed
+	ttl := time.Duration(2*totp.Skew+1) * totp.Period
+	first, err := s.cache.UseTOTPStep(ctx, userID, step, ttl)
+	if err != nil {
+		s.slog.Error("error using totp step", slog.String("error", err.Error()))
+		return err
+	}
+	if !first {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *Service) regenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	if err := s.repo.DeleteRecoveryCodes(ctx, userID); err != nil {
+		s.slog.Error("error deleting recovery codes", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		randomBytes := make([]byte, 5)
+		if _, err := rand.Read(randomBytes); err != nil {
+			s.slog.Error("error generating recovery code", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("failed to generate random bytes: %v", err)
+		}
+
+		code := base32.StdEncoding.EncodeToString(randomBytes)
+		param := db.CreateRecoveryCodeParams{UserID: userID, CodeHash: token.Hash(code)}
+		if err := s.repo.CreateRecoveryCode(ctx, param); err != nil {
+			s.slog.Error("error storing recovery code", slog.String("error", err.Error()))
+			return nil, err
+		}
+
+		// the dash only makes the code easier to read, it's removed before hashing
+		codes = append(codes, code[:4]+"-"+code[4:])
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,286 @@
+package authentication
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/webauthn"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// passkeyChallengeTTL is a bit longer than the time the browser waits for the authenticator
+	passkeyChallengeTTL   = webauthn.DefaultTimeout + time.Minute
+	passkeyCeremonyCreate = "create"
+	passkeyCeremonyGet    = "get"
+)
+
+var (
+	ErrPasskeysDisabled = errors.New("passkeys are not enabled")
+	ErrInvalidPasskey   = errors.New("invalid or unknown passkey")
+	ErrPasskeyExists    = errors.New("passkey is already registered")
+	ErrPasskeyNotFound  = errors.New("passkey not found")
+)
+
+// BeginPasskeyRegistration returns the options given to navigator.credentials.create to add a passkey to the user
+func (s *Service) BeginPasskeyRegistration(ctx context.Context, userID int64) (webauthn.CreationOptions, error) {
+	if s.passkeys == nil {
+		return webauthn.CreationOptions{}, ErrPasskeysDisabled
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+
+	credentials, err := s.repo.GetWebAuthnCredentialsByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting webauthn credentials", slog.String("error", err.Error()))
+		return webauthn.CreationOptions{}, err
+	}
+
+	exclude := make([][]byte, 0, len(credentials))
+	for _, c := range credentials {
+		exclude = append(exclude, c.ID)
+	}
+
+	challenge, err := s.newPasskeyChallenge(ctx, passkeyCeremonyCreate, userID)
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+
+	name := user.Email
+	if user.Username != "" {
+		name = user.Username
+	}
+
+	entity := webauthn.UserEntity{ID: userHandle(userID), Name: name, DisplayName: name}
+	return s.passkeys.CreationOptions(challenge, entity, exclude), nil
+}
+
+// FinishPasskeyRegistration stores the passkey created with the options of BeginPasskeyRegistration
+func (s *Service) FinishPasskeyRegistration(ctx context.Context, userID int64, name string, resp webauthn.RegistrationResponse) (model.Passkey, error) {
+	if s.passkeys == nil {
+		return model.Passkey{}, ErrPasskeysDisabled
+	}
+
+	challenge, challengeUserID, err := s.consumePasskeyChallenge(ctx, passkeyCeremonyCreate, resp.Response.ClientDataJSON)
+	if err != nil {
+		return model.Passkey{}, err
+	}
+	if challengeUserID != userID {
+		return model.Passkey{}, ErrInvalidPasskey
+	}
+
+	credential, err := s.passkeys.VerifyRegistration(challenge, resp)
+	if err != nil {
+		s.slog.Warn("invalid passkey registration", slog.String("error", err.Error()))
+		return model.Passkey{}, ErrInvalidPasskey
+	}
+
+	param := db.CreateWebAuthnCredentialParams{
+		ID:        credential.ID,
+		UserID:    userID,
+		Name:      name,
+		PublicKey: credential.PublicKey,
+		SignCount: int64(credential.SignCount),
+	}
+
+	dbCredential, err := s.repo.CreateWebAuthnCredential(ctx, param)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return model.Passkey{}, ErrPasskeyExists
+		}
+		s.slog.Error("error creating webauthn credential", slog.String("error", err.Error()))
+		return model.Passkey{}, err
+	}
+
+	return model.DBWebauthnCredentialToModelPasskey(dbCredential)[0], nil
+}
+
+func (s *Service) GetPasskeys(ctx context.Context, userID int64) ([]model.Passkey, error) {
+	credentials, err := s.repo.GetWebAuthnCredentialsByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting webauthn credentials", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return model.DBWebauthnCredentialToModelPasskey(credentials...), nil
+}
+
+// DeletePasskey removes a passkey of the user, as long as the user is still able to log in another way
+func (s *Service) DeletePasskey(ctx context.Context, userID int64, id string) error {
+	credentialID, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return ErrPasskeyNotFound
+	}
+
+	loginMethods, err := s.countLoginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if loginMethods <= 1 {
+		return ErrLastLoginMethod
+	}
+
+	rows, err := s.repo.DeleteWebAuthnCredential(ctx, db.DeleteWebAuthnCredentialParams{ID: credentialID, UserID: userID})
+	if err != nil {
+		s.slog.Error("error deleting webauthn credential", slog.String("error", err.Error()))
+		return err
+	}
+	if rows == 0 {
+		return ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+// BeginPasskeyLogin returns the options given to navigator.credentials.get, any passkey of any user can answer them
+func (s *Service) BeginPasskeyLogin(ctx context.Context) (webauthn.RequestOptions, error) {
+	if s.passkeys == nil {
+		return webauthn.RequestOptions{}, ErrPasskeysDisabled
+	}
+
+	challenge, err := s.newPasskeyChallenge(ctx, passkeyCeremonyGet, 0)
+	if err != nil {
+		return webauthn.RequestOptions{}, err
+	}
+
+	return s.passkeys.RequestOptions(challenge, nil), nil
+}
+
+// LoginPasskey returns the owner of the passkey that signed the challenge of BeginPasskeyLogin.
+// The passkey is verified by the authenticator with a pin or biometrics, so it isn't followed by the totp
+func (s *Service) LoginPasskey(ctx context.Context, resp webauthn.AssertionResponse) (model.User, error) {
+	if s.passkeys == nil {
+		return model.User{}, ErrPasskeysDisabled
+	}
+
+	challenge, _, err := s.consumePasskeyChallenge(ctx, passkeyCeremonyGet, resp.Response.ClientDataJSON)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	dbCredential, err := s.repo.GetWebAuthnCredential(ctx, resp.RawID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.User{}, ErrInvalidPasskey
+		}
+		s.slog.Error("error getting webauthn credential", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	// the user handle is optional for a credential that was asked for, but must match when it's given
+	if len(resp.Response.UserHandle) != 0 && string(resp.Response.UserHandle) != string(userHandle(dbCredential.UserID)) {
+		return model.User{}, ErrInvalidPasskey
+	}
+
+	credential := webauthn.Credential{
+		ID:        dbCredential.ID,
+		PublicKey: dbCredential.PublicKey,
+		SignCount: uint32(dbCredential.SignCount),
+	}
+
+	signCount, err := s.passkeys.VerifyAssertion(challenge, resp, credential)
+	if err != nil {
+		s.slog.Warn("invalid passkey assertion", slog.Int64("user_id", dbCredential.UserID), slog.String("error", err.Error()))
+		return model.User{}, ErrInvalidPasskey
+	}
+
+	param := db.UpdateWebAuthnCredentialSignCountParams{ID: dbCredential.ID, SignCount: int64(signCount)}
+	if err := s.repo.UpdateWebAuthnCredentialSignCount(ctx, param); err != nil {
+		s.slog.Error("error updating webauthn credential", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	user, err := s.getActiveUser(ctx, dbCredential.UserID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if s.requireVerifiedEmail && user.EmailVerifiedAt.IsZero() {
+		return model.User{}, ErrEmailNotVerified
+	}
+
+	if err := checkSuspended(user); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *Service) newPasskeyChallenge(ctx context.Context, ceremony string, userID int64) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		s.slog.Error("error creating webauthn challenge", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if err := s.cache.SetWebAuthnChallenge(ctx, ceremony, challenge, userID, passkeyChallengeTTL); err != nil {
+		s.slog.Error("error storing webauthn challenge", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// consumePasskeyChallenge returns the challenge the client data was signed for when it was issued by this server
+func (s *Service) consumePasskeyChallenge(ctx context.Context, ceremony string, clientDataJSON []byte) ([]byte, int64, error) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, 0, ErrInvalidPasskey
+	}
+
+	userID, err := s.cache.ConsumeWebAuthnChallenge(ctx, ceremony, challenge)
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return nil, 0, ErrInvalidPasskey
+		}
+		s.slog.Error("error getting webauthn challenge", slog.String("error", err.Error()))
+		return nil, 0, err
+	}
+
+	return challenge, userID, nil
+}
+
+// countLoginMethods counts the password, the linked identities and the passkeys of the user
+func (s *Service) countLoginMethods(ctx context.Context, userID int64) (int, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	identities, err := s.repo.GetUserIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting user identities", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	credentials, err := s.repo.GetWebAuthnCredentialsByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting webauthn credentials", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	count := len(identities) + len(credentials)
+	if len(user.PasswordHash) != 0 {
+		count++
+	}
+
+	return count, nil
+}
+
+// userHandle is the webauthn user id of the user, it's returned by the authenticator on login
+func userHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
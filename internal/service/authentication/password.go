@@ -0,0 +1,159 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/pkg/mailer"
+	"github.com/izzanzahrial/skeleton/pkg/password"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// passwordResetTTL is how long the link in the password reset email can be used
+	passwordResetTTL = 30 * time.Minute
+	// maxPasswordResetEmails is how many password reset emails an address can receive inside passwordResetEmailWindow
+	maxPasswordResetEmails   = 3
+	passwordResetEmailWindow = time.Hour
+	// sendMailTimeout bounds the email delivery that runs after the request has returned
+	sendMailTimeout = 30 * time.Second
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// ForgotPassword emails a password reset link to a native user. Nothing is returned when there is no such user,
+// so the endpoint can't be used to find out whether an email has an account
+func (s *Service) ForgotPassword(ctx context.Context, email string) error {
+	// an empty email matches any user in GetuserByEmail
+	if email == "" {
+		return nil
+	}
+
+	// the limit is checked before the lookup, so it applies the same way to unknown emails
+	count, retryAfter, err := s.cache.RecordPasswordResetEmail(ctx, strings.ToLower(email), passwordResetEmailWindow)
+	if err != nil {
+		s.slog.Error("error recording password reset email", slog.String("error", err.Error()))
+		return err
+	}
+	if count > maxPasswordResetEmails {
+		return &TooManyEmailsError{RetryAfter: retryAfter}
+	}
+
+	user, err := s.repo.GetuserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return err
+	}
+
+	// oauth users don't have a password to reset
+	if len(user.PasswordHash) == 0 {
+		return nil
+	}
+
+	tkn, err := token.New(user.ID, passwordResetTTL)
+	if err != nil {
+		s.slog.Error("error creating password reset token", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.cache.SetPasswordResetToken(ctx, *tkn); err != nil {
+		s.slog.Error("error storing password reset token", slog.String("error", err.Error()))
+		return err
+	}
+
+	link := s.linkBaseURL + "/reset-password?token=" + url.QueryEscape(tkn.PlainText)
+	s.sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+			"Open the link below to choose a new password, it expires in %d minutes:\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", int(passwordResetTTL.Minutes()), link),
+	})
+
+	return nil
+}
+
+// ResetPassword sets the new password of the user the token was sent to,
+// every session of the user is revoked since the old password may have been compromised
+func (s *Service) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	// the token is only consumed once the new password is accepted, so a rejected password doesn't waste the link
+	userID, err := s.cache.GetPasswordResetToken(ctx, token.Hash(resetToken))
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return ErrInvalidResetToken
+		}
+		s.slog.Error("error getting password reset token", slog.String("error", err.Error()))
+		return err
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// the reset request has no username or email, the ones of the user the token belongs to are used
+	if err := password.CheckSimilar(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	if _, err := s.cache.ConsumePasswordResetToken(ctx, token.Hash(resetToken)); err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return ErrInvalidResetToken
+		}
+		s.slog.Error("error consuming password reset token", slog.String("error", err.Error()))
+		return err
+	}
+
+	passwordHash, err := s.hasher.Generate(newPassword)
+	if err != nil {
+		s.slog.Error("error generating password hash", slog.String("error", err.Error()))
+		return err
+	}
+
+	if _, err := s.repo.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{PasswordHash: passwordHash, ID: userID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		s.slog.Error("error updating password", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	// the owner proved access to the email, so a lockout caused by someone guessing the old password is lifted
+	if err := s.cache.ResetLoginFailures(ctx, userThrottleKey(userID)); err != nil {
+		s.slog.Error("error resetting login failures", slog.String("error", err.Error()))
+	}
+
+	return nil
+}
+
+// sendMail delivers the email in the background, so the response time doesn't tell whether an email was sent
+func (s *Service) sendMail(ctx context.Context, msg mailer.Message) {
+	msg.From = s.mailFrom
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendMailTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			s.slog.Error("error sending email", slog.String("subject", msg.Subject), slog.String("error", err.Error()))
+		}
+	}()
+}
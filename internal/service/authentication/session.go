@@ -0,0 +1,102 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// maxUserAgentLength keeps a client from storing an arbitrarily large user agent
+const maxUserAgentLength = 512
+
+func (s *Service) createSession(ctx context.Context, userID int64, id, userAgent, clientIP string) error {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	session := model.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         clientIP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	if err := s.cache.CreateSession(ctx, session, refreshTokenTTL); err != nil {
+		if !errors.Is(err, cache.ErrSessionRevoked) {
+			s.slog.Error("error creating session", slog.String("error", err.Error()))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetSessions returns the active sessions of the user, the one with currentSessionID is marked as current
+func (s *Service) GetSessions(ctx context.Context, userID int64, currentSessionID string) ([]model.Session, error) {
+	sessions, err := s.cache.GetUserSessions(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting user sessions", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession logs out one of the user's sessions, its access and refresh tokens stop working
+func (s *Service) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	session, err := s.cache.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return ErrSessionNotFound
+		}
+		s.slog.Error("error getting session", slog.String("error", err.Error()))
+		return err
+	}
+
+	// don't tell a user whether a session of someone else exists
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if err := s.cache.RevokeTokenFamily(ctx, session.ID, refreshTokenTTL); err != nil {
+		s.slog.Error("error revoking session", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions logs out every session of the user except the current one
+func (s *Service) RevokeOtherSessions(ctx context.Context, userID int64, currentSessionID string) error {
+	sessions, err := s.cache.GetUserSessions(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting user sessions", slog.String("error", err.Error()))
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+
+		if err := s.cache.RevokeTokenFamily(ctx, session.ID, refreshTokenTTL); err != nil {
+			s.slog.Error("error revoking session", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	return nil
+}
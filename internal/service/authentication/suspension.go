@@ -0,0 +1,109 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrSuspendSelf          = errors.New("can't suspend yourself")
+	ErrSuspensionNotAllowed = errors.New("user can't be suspended")
+	ErrSuspensionEnded      = errors.New("suspension must end in the future")
+)
+
+// SuspendedError is returned when a suspended user logs in, it tells when the suspension ends
+type SuspendedError struct {
+	Suspension model.Suspension
+}
+
+func (e *SuspendedError) Error() string {
+	return e.Suspension.Message()
+}
+
+// SuspendUser stops the user from logging in and revokes the tokens they already have, a zero until bans the user.
+// Users who can suspend can't be suspended, so a moderator never locks out another one
+func (s *Service) SuspendUser(ctx context.Context, actorID, userID int64, until time.Time, reason string) (model.User, error) {
+	if actorID == userID {
+		return model.User{}, ErrSuspendSelf
+	}
+
+	if !until.IsZero() && !until.After(time.Now()) {
+		return model.User{}, ErrSuspensionEnded
+	}
+
+	canSuspend, err := s.repo.UserHasPermission(ctx, db.UserHasPermissionParams{ID: userID, Permission: model.PermissionUsersSuspend})
+	if err != nil {
+		s.slog.Error("error checking user permission", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+	if canSuspend {
+		return model.User{}, ErrSuspensionNotAllowed
+	}
+
+	param := db.SuspendUserParams{
+		ID:               userID,
+		SuspendedUntil:   pgtype.Timestamptz{Time: until, Valid: !until.IsZero()},
+		SuspensionReason: pgtype.Text{String: reason, Valid: true},
+	}
+
+	dbUser, err := s.repo.SuspendUser(ctx, param)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("error suspending user", slog.String("error", err.Error()))
+		}
+		return model.User{}, err
+	}
+
+	// the tokens are revoked so the suspension doesn't only rely on its copy in the cache,
+	// new tokens can't be issued since logins and refreshes check the suspension stored in the database
+	if err := s.cache.RevokeUserTokens(ctx, userID, time.Now(), refreshTokenTTL); err != nil {
+		s.slog.Error("error revoking user tokens", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	suspension, _ := model.DBUserSuspension(dbUser, time.Now())
+	if err := s.cache.SetUserSuspension(ctx, userID, suspension); err != nil {
+		s.slog.Error("error storing user suspension", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	s.slog.Warn("user suspended", slog.Int64("actor_id", actorID), slog.Int64("user_id", userID), slog.Time("until", until))
+
+	return model.DBUserToModelUser(dbUser)[0], nil
+}
+
+// UnsuspendUser lifts the suspension or the ban of the user
+func (s *Service) UnsuspendUser(ctx context.Context, actorID, userID int64) (model.User, error) {
+	dbUser, err := s.repo.UnsuspendUser(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("error unsuspending user", slog.String("error", err.Error()))
+		}
+		return model.User{}, err
+	}
+
+	if err := s.cache.DeleteUserSuspension(ctx, userID); err != nil {
+		s.slog.Error("error deleting user suspension", slog.String("error", err.Error()))
+		return model.User{}, err
+	}
+
+	s.slog.Warn("user unsuspended", slog.Int64("actor_id", actorID), slog.Int64("user_id", userID))
+
+	return model.DBUserToModelUser(dbUser)[0], nil
+}
+
+// checkSuspended returns a SuspendedError while the user is suspended
+func checkSuspended(user model.User) error {
+	if user.Suspension != nil {
+		return &SuspendedError{Suspension: *user.Suspension}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loginThrottle slows down password guessing, every failure past the free attempts doubles the delay
+// before the next attempt is allowed, until the key is locked out
+type loginThrottle struct {
+	// freeAttempts is the number of failures allowed before any delay
+	freeAttempts int64
+	// lockoutAttempts is the number of failures after which the key is locked out
+	lockoutAttempts int64
+	baseDelay       time.Duration
+	lockout         time.Duration
+	// window is how long failures are remembered since the first failure
+	window time.Duration
+}
+
+var (
+	accountThrottle = loginThrottle{freeAttempts: 3, lockoutAttempts: 10, baseDelay: time.Second, lockout: 15 * time.Minute, window: time.Hour}
+	// a single ip can be shared by many users behind a NAT, so it gets more attempts
+	ipThrottle = loginThrottle{freeAttempts: 20, lockoutAttempts: 100, baseDelay: time.Second, lockout: 15 * time.Minute, window: time.Hour}
+	// a correct password mints a new mfa challenge, so wrong codes are counted per user across challenges
+	mfaThrottle = loginThrottle{freeAttempts: 3, lockoutAttempts: 10, baseDelay: time.Second, lockout: 15 * time.Minute, window: time.Hour}
+)
+
+func (t loginThrottle) delay(failures int64) time.Duration {
+	switch {
+	case failures >= t.lockoutAttempts:
+		return t.lockout
+	case failures > t.freeAttempts:
+		// the delay is capped before shifting, a large shift would overflow into a negative or zero delay
+		shift := failures - t.freeAttempts - 1
+		if shift >= 63 || t.baseDelay > t.lockout>>shift {
+			return t.lockout
+		}
+		return t.baseDelay << shift
+	default:
+		return 0
+	}
+}
+
+// LoginLockedError is returned when too many logins failed, RetryAfter is when the next attempt is allowed
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+func userThrottleKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// accountThrottleKey is used when the account doesn't exist, so guessing unknown accounts is throttled as well
+func accountThrottleKey(email, username string) string {
+	return "account:" + strings.ToLower(email) + ":" + strings.ToLower(username)
+}
+
+func ipThrottleKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// mfaThrottleKey is separate from userThrottleKey, a correct password resets that one but never this one
+func mfaThrottleKey(userID int64) string {
+	return "mfa:" + strconv.FormatInt(userID, 10)
+}
+
+// checkLoginBlocked returns a LoginLockedError when any of the keys is blocked
+func (s *Service) checkLoginBlocked(ctx context.Context, keys ...string) error {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		ttl, err := s.cache.LoginBlockedFor(ctx, key)
+		if err != nil {
+			s.slog.Error("error checking login block", slog.String("error", err.Error()))
+			return err
+		}
+		retryAfter = max(retryAfter, ttl)
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts the failure for the account and the ip, and blocks them when the throttle says so
+func (s *Service) recordLoginFailure(ctx context.Context, accountKey, ipKey string) error {
+	for key, throttle := range map[string]loginThrottle{accountKey: accountThrottle, ipKey: ipThrottle} {
+		if err := s.recordFailure(ctx, key, throttle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordFailure counts the failure for the key, and blocks it when the throttle says so
+func (s *Service) recordFailure(ctx context.Context, key string, throttle loginThrottle) error {
+	failures, err := s.cache.RecordLoginFailure(ctx, key, throttle.window)
+	if err != nil {
+		s.slog.Error("error recording login failure", slog.String("error", err.Error()))
+		return err
+	}
+
+	delay := throttle.delay(failures)
+	if delay == 0 {
+		return nil
+	}
+
+	if failures >= throttle.lockoutAttempts {
+		s.slog.Warn("login locked out", slog.String("key", key), slog.Int64("failures", failures))
+	}
+
+	if err := s.cache.BlockLogin(ctx, key, delay); err != nil {
+		s.slog.Error("error blocking login", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package authentication
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		throttle loginThrottle
+		failures int64
+		want     time.Duration
+	}{
+		{name: "free attempt", throttle: accountThrottle, failures: 3, want: 0},
+		{name: "first delay", throttle: accountThrottle, failures: 4, want: time.Second},
+		{name: "doubled delay", throttle: accountThrottle, failures: 6, want: 4 * time.Second},
+		{name: "lockout", throttle: accountThrottle, failures: 10, want: 15 * time.Minute},
+		{name: "ip capped at lockout", throttle: ipThrottle, failures: 40, want: 15 * time.Minute},
+		{name: "ip shift past int64", throttle: ipThrottle, failures: 70, want: 15 * time.Minute},
+		{name: "ip shift wraps to zero", throttle: ipThrottle, failures: 90, want: 15 * time.Minute},
+		{name: "ip lockout", throttle: ipThrottle, failures: 100, want: 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.throttle.delay(tt.failures); got != tt.want {
+				t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleDelayNeverDecreases(t *testing.T) {
+	for _, throttle := range []loginThrottle{accountThrottle, ipThrottle} {
+		var previous time.Duration
+		for failures := int64(1); failures <= throttle.lockoutAttempts+10; failures++ {
+			delay := throttle.delay(failures)
+			if delay < previous || delay > throttle.lockout {
+				t.Fatalf("delay(%d) = %s after %s, lockout %s", failures, delay, previous, throttle.lockout)
+			}
+			previous = delay
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/mailer"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// emailVerificationTTL is how long the link in the verification email can be used
+	emailVerificationTTL = 24 * time.Hour
+	// maxVerificationEmails is how many verification emails an address can receive inside verificationEmailWindow
+	maxVerificationEmails   = 3
+	verificationEmailWindow = time.Hour
+)
+
+var (
+	ErrEmailNotVerified         = errors.New("email address is not verified, check your inbox for the verification link")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+)
+
+// TooManyEmailsError is returned when an address received too many emails, RetryAfter is when the next one is allowed
+type TooManyEmailsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyEmailsError) Error() string {
+	return fmt.Sprintf("too many emails sent, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// SendVerificationEmail emails a verification link to a user that has just signed up
+func (s *Service) SendVerificationEmail(ctx context.Context, user model.User) error {
+	if !user.EmailVerifiedAt.IsZero() {
+		return nil
+	}
+
+	if err := s.allowVerificationEmail(ctx, user.Email); err != nil {
+		return err
+	}
+
+	return s.sendVerificationEmail(ctx, user.ID, user.Email)
+}
+
+// ResendVerificationEmail sends a new verification link. Nothing is returned when there is no unverified user
+// with the email, so the endpoint can't be used to find out whether an email has an account
+func (s *Service) ResendVerificationEmail(ctx context.Context, email string) error {
+	// an empty email matches any user in GetuserByEmail
+	if email == "" {
+		return nil
+	}
+
+	// the limit is checked before the lookup, so it applies the same way to unknown emails
+	if err := s.allowVerificationEmail(ctx, email); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetuserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		s.slog.Error("error getting user", slog.String("error", err.Error()))
+		return err
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user.ID, user.Email)
+}
+
+// VerifyEmail marks the email of the user as verified, the token is only valid for the email it was sent to
+func (s *Service) VerifyEmail(ctx context.Context, verificationToken string) error {
+	verification, err := s.cache.ConsumeEmailVerificationToken(ctx, token.Hash(verificationToken))
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		s.slog.Error("error getting email verification token", slog.String("error", err.Error()))
+		return err
+	}
+
+	rows, err := s.repo.VerifyUserEmail(ctx, db.VerifyUserEmailParams{ID: verification.UserID, Email: verification.Email})
+	if err != nil {
+		s.slog.Error("error verifying email", slog.String("error", err.Error()))
+		return err
+	}
+
+	// the user changed the email or was deleted after the link was sent
+	if rows == 0 {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+func (s *Service) allowVerificationEmail(ctx context.Context, email string) error {
+	count, retryAfter, err := s.cache.RecordVerificationEmail(ctx, strings.ToLower(email), verificationEmailWindow)
+	if err != nil {
+		s.slog.Error("error recording verification email", slog.String("error", err.Error()))
+		return err
+	}
+
+	if count > maxVerificationEmails {
+		return &TooManyEmailsError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+func (s *Service) sendVerificationEmail(ctx context.Context, userID int64, email string) error {
+	tkn, err := token.New(userID, emailVerificationTTL)
+	if err != nil {
+		s.slog.Error("error creating email verification token", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.cache.SetEmailVerificationToken(ctx, *tkn, email); err != nil {
+		s.slog.Error("error storing email verification token", slog.String("error", err.Error()))
+		return err
+	}
+
+	link := s.linkBaseURL + "/verify-email?token=" + url.QueryEscape(tkn.PlainText)
+	s.sendMail(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome to skeleton!\n\n"+
+			"Open the link below to verify your email address, it expires in %d hours:\n%s\n\n"+
+			"If you didn't sign up, you can ignore this email.\n", int(emailVerificationTTL.Hours()), link),
+	})
+
+	return nil
+}
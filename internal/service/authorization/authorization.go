@@ -0,0 +1,318 @@
+package authorization
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+type authorizationRepo interface {
+	CreateOAuthClient(ctx context.Context, arg db.CreateOAuthClientParams) (db.OauthClient, error)
+	GetOAuthClient(ctx context.Context, id string) (db.OauthClient, error)
+	GetOAuthClients(ctx context.Context) ([]db.OauthClient, error)
+	RevokeOAuthClient(ctx context.Context, id string) (int64, error)
+	UpsertOAuthConsent(ctx context.Context, arg db.UpsertOAuthConsentParams) error
+	GetOAuthConsent(ctx context.Context, arg db.GetOAuthConsentParams) (db.OauthConsent, error)
+	GetOAuthConsentsByUserID(ctx context.Context, userID int64) ([]db.OauthConsent, error)
+	DeleteOAuthConsent(ctx context.Context, arg db.DeleteOAuthConsentParams) (int64, error)
+	GetUser(ctx context.Context, id int64) (db.User, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash []byte) (db.ApiKey, error)
+	RevokeAPIKey(ctx context.Context, arg db.RevokeAPIKeyParams) (int64, error)
+}
+
+type authorizationCache interface {
+	SetAuthorizationCode(ctx context.Context, token token.Token, code cache.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, hash []byte) (cache.AuthorizationCode, error)
+	IsAccessTokenDenied(ctx context.Context, jti string) (bool, error)
+	DenyAccessToken(ctx context.Context, jti string, ttl time.Duration) error
+	TokensRevokedBefore(ctx context.Context, userID int64) (time.Time, error)
+	GetSession(ctx context.Context, id string) (model.Session, error)
+	GetRefreshToken(ctx context.Context, hash []byte) (token.Token, error)
+	IsTokenFamilyRevoked(ctx context.Context, family string) (bool, error)
+	RevokeTokenFamily(ctx context.Context, family string, ttl time.Duration) error
+}
+
+var (
+	ErrClientNotFound     = errors.New("oauth client not found")
+	ErrConsentNotFound    = errors.New("consent not found")
+	ErrInvalidRedirectURI = errors.New("redirect uris must be https, or http on localhost, without a fragment")
+	ErrRedirectURIMissing = errors.New("the authorization_code grant needs at least one redirect uri")
+	ErrPublicClientGrant  = errors.New("public clients can't use the client_credentials grant")
+	ErrUnknownScope       = errors.New("unknown scope")
+	ErrUnknownGrantType   = errors.New("unknown grant type")
+)
+
+// oauth error codes, RFC 6749 section 4.1.2.1 and 5.2
+const (
+	ErrorInvalidRequest       = "invalid_request"
+	ErrorInvalidClient        = "invalid_client"
+	ErrorInvalidGrant         = "invalid_grant"
+	ErrorInvalidScope         = "invalid_scope"
+	ErrorUnauthorizedClient   = "unauthorized_client"
+	ErrorUnsupportedGrantType = "unsupported_grant_type"
+	ErrorUnsupportedResponse  = "unsupported_response_type"
+	ErrorAccessDenied         = "access_denied"
+)
+
+// Error is an oauth error sent back to the client as is, RedirectTo is set when the error
+// must reach the client through its redirect uri instead of being shown to the user
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	RedirectTo  string `json:"redirect_to,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+type Service struct {
+	repo  authorizationRepo
+	cache authorizationCache
+	// keys verify the jwt given to the introspection and revocation endpoints
+	keys *token.KeyManager
+	// issuer is sent back with the authorization code, so the client can tell which server answered (RFC 9207)
+	issuer string
+	slog   *slog.Logger
+}
+
+func NewService(repo authorizationRepo, cache authorizationCache, keys *token.KeyManager, issuer string, slog *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		cache:  cache,
+		keys:   keys,
+		issuer: issuer,
+		slog:   slog,
+	}
+}
+
+// CreateClient registers an oauth client, the secret of a confidential client is only returned here
+func (s *Service) CreateClient(ctx context.Context, name string, redirectURIs, grantTypes, scopes []string, firstParty, public bool) (model.OAuthClient, string, error) {
+	for _, grantType := range grantTypes {
+		if !slices.Contains(model.OAuthGrantTypes, grantType) {
+			return model.OAuthClient{}, "", ErrUnknownGrantType
+		}
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(model.OAuthScopes, scope) {
+			return model.OAuthClient{}, "", ErrUnknownScope
+		}
+	}
+
+	if slices.Contains(grantTypes, model.GrantTypeAuthorizationCode) && len(redirectURIs) == 0 {
+		return model.OAuthClient{}, "", ErrRedirectURIMissing
+	}
+
+	for _, redirectURI := range redirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return model.OAuthClient{}, "", ErrInvalidRedirectURI
+		}
+	}
+
+	if public && slices.Contains(grantTypes, model.GrantTypeClientCredentials) {
+		return model.OAuthClient{}, "", ErrPublicClientGrant
+	}
+
+	id, err := token.New(0, 0)
+	if err != nil {
+		s.slog.Error("error creating oauth client id", slog.String("error", err.Error()))
+		return model.OAuthClient{}, "", err
+	}
+
+	param := db.CreateOAuthClientParams{
+		ID:           strings.ToLower(id.PlainText),
+		Name:         name,
+		RedirectUris: redirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		FirstParty:   firstParty,
+	}
+	if param.RedirectUris == nil {
+		param.RedirectUris = []string{}
+	}
+
+	var secret string
+	if !public {
+		tkn, err := token.New(0, 0)
+		if err != nil {
+			s.slog.Error("error creating oauth client secret", slog.String("error", err.Error()))
+			return model.OAuthClient{}, "", err
+		}
+		secret = tkn.PlainText
+		param.SecretHash = tkn.Hash
+	}
+
+	client, err := s.repo.CreateOAuthClient(ctx, param)
+	if err != nil {
+		s.slog.Error("error creating oauth client", slog.String("error", err.Error()))
+		return model.OAuthClient{}, "", err
+	}
+
+	return model.DBOauthClientToModelOAuthClient(client)[0], secret, nil
+}
+
+func (s *Service) GetClients(ctx context.Context) ([]model.OAuthClient, error) {
+	clients, err := s.repo.GetOAuthClients(ctx)
+	if err != nil {
+		s.slog.Error("error getting oauth clients", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return model.DBOauthClientToModelOAuthClient(clients...), nil
+}
+
+// RevokeClient stops the client from getting new tokens, the tokens it already has stop being accepted
+func (s *Service) RevokeClient(ctx context.Context, id string) error {
+	rows, err := s.repo.RevokeOAuthClient(ctx, id)
+	if err != nil {
+		s.slog.Error("error revoking oauth client", slog.String("error", err.Error()))
+		return err
+	}
+	if rows == 0 {
+		return ErrClientNotFound
+	}
+
+	return nil
+}
+
+// GetConsents returns the clients the user allowed to act on their behalf
+func (s *Service) GetConsents(ctx context.Context, userID int64) ([]model.OAuthConsent, error) {
+	consents, err := s.repo.GetOAuthConsentsByUserID(ctx, userID)
+	if err != nil {
+		s.slog.Error("error getting oauth consents", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return model.DBOauthConsentToModelOAuthConsent(consents...), nil
+}
+
+// RevokeConsent makes the client ask the user again on its next authorization, the tokens it already has
+// for the user stop being accepted
+func (s *Service) RevokeConsent(ctx context.Context, userID int64, clientID string) error {
+	rows, err := s.repo.DeleteOAuthConsent(ctx, db.DeleteOAuthConsentParams{UserID: userID, ClientID: clientID})
+	if err != nil {
+		s.slog.Error("error deleting oauth consent", slog.String("error", err.Error()))
+		return err
+	}
+	if rows == 0 {
+		return ErrConsentNotFound
+	}
+
+	return nil
+}
+
+// IsClientTokenActive tells whether the tokens of the client are still accepted, they stop being active
+// when the client is revoked or, unless the client is first party, when the user revokes their consent
+func (s *Service) IsClientTokenActive(ctx context.Context, clientID string, userID int64) (bool, error) {
+	client, err := s.getClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrClientNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// a client acting on its own behalf doesn't need a consent
+	if userID == 0 || client.FirstParty {
+		return true, nil
+	}
+
+	if _, err := s.repo.GetOAuthConsent(ctx, db.GetOAuthConsentParams{UserID: userID, ClientID: clientID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		s.slog.Error("error getting oauth consent", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AuthenticateClient returns the confidential client the secret belongs to, it must be registered with the scope
+func (s *Service) AuthenticateClient(ctx context.Context, clientID, secret, scope string) (model.OAuthClient, error) {
+	client, err := s.authenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return model.OAuthClient{}, err
+	}
+
+	if len(client.SecretHash) == 0 || !slices.Contains(client.Scopes, scope) {
+		return model.OAuthClient{}, &Error{Code: ErrorInvalidClient, Description: "the client isn't allowed to use this endpoint"}
+	}
+
+	return model.DBOauthClientToModelOAuthClient(client)[0], nil
+}
+
+// getClient returns the client, the revoked clients aren't found
+func (s *Service) getClient(ctx context.Context, id string) (db.OauthClient, error) {
+	client, err := s.repo.GetOAuthClient(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.OauthClient{}, ErrClientNotFound
+		}
+		s.slog.Error("error getting oauth client", slog.String("error", err.Error()))
+		return db.OauthClient{}, err
+	}
+
+	return client, nil
+}
+
+// authenticateClient checks the secret of a confidential client, a public client must not send one
+func (s *Service) authenticateClient(ctx context.Context, clientID, secret string) (db.OauthClient, error) {
+	if clientID == "" {
+		return db.OauthClient{}, &Error{Code: ErrorInvalidClient, Description: "missing client_id"}
+	}
+
+	client, err := s.getClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrClientNotFound) {
+			return db.OauthClient{}, &Error{Code: ErrorInvalidClient}
+		}
+		return db.OauthClient{}, err
+	}
+
+	if len(client.SecretHash) == 0 {
+		if secret != "" {
+			return db.OauthClient{}, &Error{Code: ErrorInvalidClient}
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare(token.Hash(secret), client.SecretHash) != 1 {
+		return db.OauthClient{}, &Error{Code: ErrorInvalidClient}
+	}
+
+	return client, nil
+}
+
+// validRedirectURI accepts absolute https urls, and http on the loopback for apps running on the user device
+func validRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+
+	return false
+}
@@ -0,0 +1,746 @@
+package authorization
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type consentKey struct {
+	userID   int64
+	clientID string
+}
+
+// fakeRepo keeps the rows in memory, the revoked clients are left out like GetOAuthClient does
+type fakeRepo struct {
+	clients  map[string]db.OauthClient
+	consents map[consentKey]db.OauthConsent
+	users    map[int64]db.User
+	// apiKeys are stored by the hex of their hash
+	apiKeys        map[string]db.ApiKey
+	revokedAPIKeys []int64
+}
+
+func (r *fakeRepo) CreateOAuthClient(ctx context.Context, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+	client := db.OauthClient{
+		ID:           arg.ID,
+		Name:         arg.Name,
+		SecretHash:   arg.SecretHash,
+		RedirectUris: arg.RedirectUris,
+		GrantTypes:   arg.GrantTypes,
+		Scopes:       arg.Scopes,
+		FirstParty:   arg.FirstParty,
+	}
+	r.clients[client.ID] = client
+	return client, nil
+}
+
+func (r *fakeRepo) GetOAuthClient(ctx context.Context, id string) (db.OauthClient, error) {
+	client, ok := r.clients[id]
+	if !ok {
+		return db.OauthClient{}, pgx.ErrNoRows
+	}
+	return client, nil
+}
+
+func (r *fakeRepo) GetOAuthClients(ctx context.Context) ([]db.OauthClient, error) {
+	var clients []db.OauthClient
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func (r *fakeRepo) RevokeOAuthClient(ctx context.Context, id string) (int64, error) {
+	if _, ok := r.clients[id]; !ok {
+		return 0, nil
+	}
+	delete(r.clients, id)
+	return 1, nil
+}
+
+func (r *fakeRepo) UpsertOAuthConsent(ctx context.Context, arg db.UpsertOAuthConsentParams) error {
+	r.consents[consentKey{arg.UserID, arg.ClientID}] = db.OauthConsent{UserID: arg.UserID, ClientID: arg.ClientID, Scopes: arg.Scopes}
+	return nil
+}
+
+func (r *fakeRepo) GetOAuthConsent(ctx context.Context, arg db.GetOAuthConsentParams) (db.OauthConsent, error) {
+	consent, ok := r.consents[consentKey{arg.UserID, arg.ClientID}]
+	if !ok {
+		return db.OauthConsent{}, pgx.ErrNoRows
+	}
+	return consent, nil
+}
+
+func (r *fakeRepo) GetOAuthConsentsByUserID(ctx context.Context, userID int64) ([]db.OauthConsent, error) {
+	var consents []db.OauthConsent
+	for key, consent := range r.consents {
+		if key.userID == userID {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+func (r *fakeRepo) DeleteOAuthConsent(ctx context.Context, arg db.DeleteOAuthConsentParams) (int64, error) {
+	key := consentKey{arg.UserID, arg.ClientID}
+	if _, ok := r.consents[key]; !ok {
+		return 0, nil
+	}
+	delete(r.consents, key)
+	return 1, nil
+}
+
+func (r *fakeRepo) GetUser(ctx context.Context, id int64) (db.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (r *fakeRepo) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (db.ApiKey, error) {
+	apiKey, ok := r.apiKeys[hex.EncodeToString(keyHash)]
+	if !ok || slices.Contains(r.revokedAPIKeys, apiKey.ID) {
+		return db.ApiKey{}, pgx.ErrNoRows
+	}
+	return apiKey, nil
+}
+
+func (r *fakeRepo) RevokeAPIKey(ctx context.Context, arg db.RevokeAPIKeyParams) (int64, error) {
+	r.revokedAPIKeys = append(r.revokedAPIKeys, arg.ID)
+	return 1, nil
+}
+
+// fakeCache keeps the tokens in memory by the hex of their hash, like the redis keys
+type fakeCache struct {
+	codes           map[string]cache.AuthorizationCode
+	denied          map[string]bool
+	revokedBefore   map[int64]time.Time
+	sessions        map[string]model.Session
+	refreshTokens   map[string]token.Token
+	usedTokens      map[string]bool
+	revokedFamilies map[string]bool
+}
+
+func (c *fakeCache) SetAuthorizationCode(ctx context.Context, tkn token.Token, code cache.AuthorizationCode) error {
+	c.codes[hex.EncodeToString(tkn.Hash)] = code
+	return nil
+}
+
+func (c *fakeCache) ConsumeAuthorizationCode(ctx context.Context, hash []byte) (cache.AuthorizationCode, error) {
+	key := hex.EncodeToString(hash)
+	code, ok := c.codes[key]
+	if !ok {
+		return cache.AuthorizationCode{}, cache.ErrTokenNotFound
+	}
+	delete(c.codes, key)
+	return code, nil
+}
+
+func (c *fakeCache) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	return c.denied[jti], nil
+}
+
+func (c *fakeCache) DenyAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	c.denied[jti] = true
+	return nil
+}
+
+func (c *fakeCache) TokensRevokedBefore(ctx context.Context, userID int64) (time.Time, error) {
+	return c.revokedBefore[userID], nil
+}
+
+func (c *fakeCache) GetSession(ctx context.Context, id string) (model.Session, error) {
+	session, ok := c.sessions[id]
+	if !ok || c.revokedFamilies[id] {
+		return model.Session{}, cache.ErrTokenNotFound
+	}
+	return session, nil
+}
+
+func (c *fakeCache) GetRefreshToken(ctx context.Context, hash []byte) (token.Token, error) {
+	key := hex.EncodeToString(hash)
+	tkn, ok := c.refreshTokens[key]
+	if !ok {
+		return token.Token{}, cache.ErrTokenNotFound
+	}
+	if c.usedTokens[key] {
+		return tkn, cache.ErrTokenReused
+	}
+	return tkn, nil
+}
+
+func (c *fakeCache) IsTokenFamilyRevoked(ctx context.Context, family string) (bool, error) {
+	return c.revokedFamilies[family], nil
+}
+
+func (c *fakeCache) RevokeTokenFamily(ctx context.Context, family string, ttl time.Duration) error {
+	c.revokedFamilies[family] = true
+	return nil
+}
+
+const (
+	testIssuer      = "https://auth.example.com"
+	testRedirectURI = "https://app.example.com/callback"
+	testSecret      = "secret"
+)
+
+func newTestService(t *testing.T) (*Service, *fakeRepo, *fakeCache) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := token.NewKeyManager("test", &token.Key{ID: "test", Method: jwt.SigningMethodEdDSA, Private: private, Public: public})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &fakeRepo{
+		clients: map[string]db.OauthClient{
+			"confidential": {
+				ID:           "confidential",
+				SecretHash:   token.Hash(testSecret),
+				RedirectUris: []string{testRedirectURI},
+				GrantTypes:   []string{model.GrantTypeAuthorizationCode, model.GrantTypeClientCredentials},
+				Scopes:       []string{model.ScopeOpenID, model.ScopeProfile, model.ScopePostsRead, model.ScopeTokensIntrospect},
+			},
+			"public": {
+				ID:           "public",
+				RedirectUris: []string{testRedirectURI},
+				GrantTypes:   []string{model.GrantTypeAuthorizationCode},
+				Scopes:       []string{model.ScopeOpenID, model.ScopeProfile},
+			},
+			"first-party": {
+				ID:           "first-party",
+				SecretHash:   token.Hash(testSecret),
+				RedirectUris: []string{testRedirectURI},
+				GrantTypes:   []string{model.GrantTypeAuthorizationCode},
+				Scopes:       []string{model.ScopeOpenID, model.ScopeProfile},
+				FirstParty:   true,
+			},
+			"service": {
+				ID:         "service",
+				SecretHash: token.Hash(testSecret),
+				GrantTypes: []string{model.GrantTypeClientCredentials},
+				Scopes:     []string{model.ScopePostsRead},
+			},
+		},
+		consents: make(map[consentKey]db.OauthConsent),
+		users: map[int64]db.User{
+			1: {ID: 1, Email: "alice@example.com", Username: pgtype.Text{String: "alice", Valid: true}, Role: string(model.RolesUser)},
+			2: {ID: 2, Email: "bob@example.com", Username: pgtype.Text{String: "bob", Valid: true}, Role: string(model.RolesUser),
+				SuspendedAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}},
+			3: {ID: 3, Email: "carol@example.com", Username: pgtype.Text{String: "carol", Valid: true}, Role: string(model.RolesUser),
+				DeletedAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}},
+		},
+		apiKeys: make(map[string]db.ApiKey),
+	}
+
+	fc := &fakeCache{
+		codes:           make(map[string]cache.AuthorizationCode),
+		denied:          make(map[string]bool),
+		revokedBefore:   make(map[int64]time.Time),
+		sessions:        make(map[string]model.Session),
+		refreshTokens:   make(map[string]token.Token),
+		usedTokens:      make(map[string]bool),
+		revokedFamilies: make(map[string]bool),
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewService(repo, fc, keys, testIssuer, logger), repo, fc
+}
+
+func s256(verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(challenge[:])
+}
+
+// errorCode returns the oauth error code of err, it's empty when err isn't an oauth error
+func errorCode(err error) string {
+	var oauthErr *Error
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURI string
+		want        bool
+	}{
+		{name: "https", redirectURI: "https://app.example.com/callback", want: true},
+		{name: "https with query", redirectURI: "https://app.example.com/callback?tenant=1", want: true},
+		{name: "http on localhost", redirectURI: "http://localhost:3000/callback", want: true},
+		{name: "http on ipv4 loopback", redirectURI: "http://127.0.0.1:3000/callback", want: true},
+		{name: "http on ipv6 loopback", redirectURI: "http://[::1]:3000/callback", want: true},
+		{name: "http on another host", redirectURI: "http://app.example.com/callback"},
+		{name: "localhost as a subdomain", redirectURI: "http://localhost.example.com/callback"},
+		{name: "fragment", redirectURI: "https://app.example.com/callback#token"},
+		{name: "relative", redirectURI: "/callback"},
+		{name: "custom scheme", redirectURI: "myapp://callback"},
+		{name: "javascript", redirectURI: "javascript:alert(1)"},
+		{name: "empty", redirectURI: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validRedirectURI(tt.redirectURI); got != tt.want {
+				t.Errorf("validRedirectURI(%q) = %v, want %v", tt.redirectURI, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	allowed := []string{model.ScopeOpenID, model.ScopeProfile, model.ScopePostsRead}
+
+	tests := []struct {
+		name   string
+		scope  string
+		want   []string
+		wantOK bool
+	}{
+		{name: "no scope means every allowed scope", scope: "", want: allowed, wantOK: true},
+		{name: "only spaces", scope: "   ", want: allowed, wantOK: true},
+		{name: "subset", scope: "openid profile", want: []string{model.ScopeOpenID, model.ScopeProfile}, wantOK: true},
+		{name: "extra spaces", scope: " openid  posts:read ", want: []string{model.ScopeOpenID, model.ScopePostsRead}, wantOK: true},
+		{name: "scope not allowed", scope: "openid posts:write"},
+		{name: "unknown scope", scope: "admin"},
+		{name: "comma separated", scope: "openid,profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseScopes(tt.scope, allowed)
+			if ok != tt.wantOK {
+				t.Fatalf("parseScopes(%q) ok = %v, want %v", tt.scope, ok, tt.wantOK)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseScopes(%q) = %v, want %v", tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		scope    string
+		wantCode string
+	}{
+		{name: "confidential client", clientID: "confidential", secret: testSecret, scope: model.ScopeTokensIntrospect},
+		{name: "wrong secret", clientID: "confidential", secret: "wrong", scope: model.ScopeTokensIntrospect, wantCode: ErrorInvalidClient},
+		{name: "missing secret", clientID: "confidential", scope: model.ScopeTokensIntrospect, wantCode: ErrorInvalidClient},
+		{name: "not registered with the scope", clientID: "confidential", secret: testSecret, scope: model.ScopeTokensRevoke, wantCode: ErrorInvalidClient},
+		{name: "public client", clientID: "public", scope: model.ScopeProfile, wantCode: ErrorInvalidClient},
+		{name: "public client sending a secret", clientID: "public", secret: testSecret, scope: model.ScopeProfile, wantCode: ErrorInvalidClient},
+		{name: "unknown client", clientID: "unknown", secret: testSecret, scope: model.ScopeTokensIntrospect, wantCode: ErrorInvalidClient},
+		{name: "missing client id", secret: testSecret, scope: model.ScopeTokensIntrospect, wantCode: ErrorInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t)
+
+			client, err := s.AuthenticateClient(context.Background(), tt.clientID, tt.secret, tt.scope)
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("AuthenticateClient() error = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode == "" && client.ID != tt.clientID {
+				t.Errorf("AuthenticateClient() client = %q, want %q", client.ID, tt.clientID)
+			}
+		})
+	}
+}
+
+func TestExchangeAuthorizationCode(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mJ92K9A1UZx7JhyN2ixJ6b6xPWOxpI"
+
+	tests := []struct {
+		name        string
+		code        cache.AuthorizationCode
+		clientID    string
+		secret      string
+		redirectURI string
+		verifier    string
+		wantCode    string
+	}{
+		{
+			name:     "confidential client",
+			code:     cache.AuthorizationCode{ClientID: "confidential", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "confidential", secret: testSecret, redirectURI: testRedirectURI, verifier: verifier,
+		},
+		{
+			name:     "public client without a secret",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: testRedirectURI, verifier: verifier,
+		},
+		{
+			name:     "wrong verifier",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: testRedirectURI, verifier: verifier + "x", wantCode: ErrorInvalidGrant,
+		},
+		{
+			name:     "plain challenge",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: verifier},
+			clientID: "public", redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorInvalidGrant,
+		},
+		{
+			name:     "missing verifier",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: testRedirectURI, wantCode: ErrorInvalidRequest,
+		},
+		{
+			name:     "other redirect uri",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: "https://app.example.com/other", verifier: verifier, wantCode: ErrorInvalidGrant,
+		},
+		{
+			name:     "code of another client",
+			code:     cache.AuthorizationCode{ClientID: "confidential", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorInvalidGrant,
+		},
+		{
+			name:     "wrong secret",
+			code:     cache.AuthorizationCode{ClientID: "confidential", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "confidential", secret: "wrong", redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorInvalidClient,
+		},
+		{
+			name:     "public client sending a secret",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", secret: testSecret, redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorInvalidClient,
+		},
+		{
+			name:     "client without the authorization code grant",
+			code:     cache.AuthorizationCode{ClientID: "service", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "service", secret: testSecret, redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorUnauthorizedClient,
+		},
+		{
+			name:     "suspended user",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 2, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorInvalidGrant,
+		},
+		{
+			name:     "deleted user",
+			code:     cache.AuthorizationCode{ClientID: "public", UserID: 3, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)},
+			clientID: "public", redirectURI: testRedirectURI, verifier: verifier, wantCode: ErrorInvalidGrant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, fc := newTestService(t)
+
+			code, err := token.New(tt.code.UserID, authorizationCodeTTL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.code.Scopes = []string{model.ScopeOpenID}
+			tt.code.Nonce = "nonce"
+			if err := fc.SetAuthorizationCode(context.Background(), *code, tt.code); err != nil {
+				t.Fatal(err)
+			}
+
+			grant, err := s.ExchangeAuthorizationCode(context.Background(), tt.clientID, tt.secret, code.PlainText, tt.redirectURI, tt.verifier)
+			if got := errorCode(err); got != tt.wantCode {
+				t.Fatalf("ExchangeAuthorizationCode() error = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			if grant.ClientID != tt.clientID || grant.User == nil || grant.User.ID != tt.code.UserID {
+				t.Errorf("ExchangeAuthorizationCode() grant = %+v, want client %q and user %d", grant, tt.clientID, tt.code.UserID)
+			}
+			if !slices.Equal(grant.Scopes, tt.code.Scopes) || grant.Nonce != tt.code.Nonce {
+				t.Errorf("ExchangeAuthorizationCode() scopes = %v nonce = %q, want %v %q", grant.Scopes, grant.Nonce, tt.code.Scopes, tt.code.Nonce)
+			}
+		})
+	}
+}
+
+func TestExchangeAuthorizationCodeSingleUse(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mJ92K9A1UZx7JhyN2ixJ6b6xPWOxpI"
+
+	tests := []struct {
+		name          string
+		firstVerifier string
+		wantFirstCode string
+	}{
+		{name: "after a successful exchange", firstVerifier: verifier},
+		// a code can't be retried once a guessed verifier was rejected
+		{name: "after a wrong verifier", firstVerifier: "guess", wantFirstCode: ErrorInvalidGrant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, fc := newTestService(t)
+
+			code, err := token.New(1, authorizationCodeTTL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value := cache.AuthorizationCode{ClientID: "public", UserID: 1, RedirectURI: testRedirectURI, CodeChallenge: s256(verifier)}
+			if err := fc.SetAuthorizationCode(context.Background(), *code, value); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.ExchangeAuthorizationCode(context.Background(), "public", "", code.PlainText, testRedirectURI, tt.firstVerifier)
+			if got := errorCode(err); got != tt.wantFirstCode {
+				t.Fatalf("first ExchangeAuthorizationCode() error = %v, want code %q", err, tt.wantFirstCode)
+			}
+
+			_, err = s.ExchangeAuthorizationCode(context.Background(), "public", "", code.PlainText, testRedirectURI, verifier)
+			if got := errorCode(err); got != ErrorInvalidGrant {
+				t.Errorf("second ExchangeAuthorizationCode() error = %v, want code %q", err, ErrorInvalidGrant)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	request := func(clientID, scope string) AuthorizationRequest {
+		return AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            clientID,
+			RedirectURI:         testRedirectURI,
+			Scope:               scope,
+			State:               "state",
+			CodeChallenge:       s256("verifier"),
+			CodeChallengeMethod: "S256",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		req         AuthorizationRequest
+		consent     []string
+		wantScopes  []string
+		wantConsent bool
+	}{
+		{name: "no consent yet", req: request("confidential", "openid profile"), wantScopes: []string{"openid", "profile"}, wantConsent: true},
+		{name: "scopes already allowed", req: request("confidential", "openid profile"), consent: []string{"openid", "profile", "posts:read"}, wantScopes: []string{"openid", "profile"}},
+		{name: "new scope", req: request("confidential", "openid posts:read"), consent: []string{"openid", "profile"}, wantScopes: []string{"openid", "posts:read"}, wantConsent: true},
+		{name: "first party client", req: request("first-party", "openid"), wantScopes: []string{"openid"}},
+		{name: "every allowed scope", req: request("public", ""), consent: []string{"openid", "profile"}, wantScopes: []string{"openid", "profile"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, _ := newTestService(t)
+			if tt.consent != nil {
+				repo.consents[consentKey{1, tt.req.ClientID}] = db.OauthConsent{UserID: 1, ClientID: tt.req.ClientID, Scopes: tt.consent}
+			}
+
+			prompt, err := s.Authorize(context.Background(), 1, tt.req)
+			if err != nil {
+				t.Fatalf("Authorize() error = %v", err)
+			}
+			if prompt.ConsentRequired != tt.wantConsent {
+				t.Errorf("Authorize() ConsentRequired = %v, want %v", prompt.ConsentRequired, tt.wantConsent)
+			}
+			if !slices.Equal(prompt.Scopes, tt.wantScopes) {
+				t.Errorf("Authorize() Scopes = %v, want %v", prompt.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestAuthorizeInvalidRequest(t *testing.T) {
+	valid := AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            "public",
+		RedirectURI:         testRedirectURI,
+		State:               "state",
+		CodeChallenge:       s256("verifier"),
+		CodeChallengeMethod: "S256",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *AuthorizationRequest)
+		// wantRedirect is false for the errors shown to the user, the redirect uri can't be trusted yet
+		wantCode     string
+		wantRedirect bool
+	}{
+		{name: "unknown client", modify: func(req *AuthorizationRequest) { req.ClientID = "unknown" }, wantCode: ErrorInvalidRequest},
+		{name: "unregistered redirect uri", modify: func(req *AuthorizationRequest) { req.RedirectURI = "https://evil.example.com/callback" }, wantCode: ErrorInvalidRequest},
+		{name: "token response type", modify: func(req *AuthorizationRequest) { req.ResponseType = "token" }, wantCode: ErrorUnsupportedResponse, wantRedirect: true},
+		{name: "missing code challenge", modify: func(req *AuthorizationRequest) { req.CodeChallenge = "" }, wantCode: ErrorInvalidRequest, wantRedirect: true},
+		{name: "plain code challenge method", modify: func(req *AuthorizationRequest) { req.CodeChallengeMethod = "plain" }, wantCode: ErrorInvalidRequest, wantRedirect: true},
+		{name: "scope not allowed", modify: func(req *AuthorizationRequest) { req.Scope = "openid posts:read" }, wantCode: ErrorInvalidScope, wantRedirect: true},
+		{name: "client without redirect uris", modify: func(req *AuthorizationRequest) { req.ClientID = "service" }, wantCode: ErrorInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t)
+
+			req := valid
+			tt.modify(&req)
+
+			_, err := s.Authorize(context.Background(), 1, req)
+			var oauthErr *Error
+			if !errors.As(err, &oauthErr) || oauthErr.Code != tt.wantCode {
+				t.Fatalf("Authorize() error = %v, want code %q", err, tt.wantCode)
+			}
+
+			if !tt.wantRedirect {
+				if oauthErr.RedirectTo != "" {
+					t.Errorf("Authorize() RedirectTo = %q, want the error shown to the user", oauthErr.RedirectTo)
+				}
+				return
+			}
+
+			u, err := url.Parse(oauthErr.RedirectTo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := u.Query()
+			if got := u.Scheme + "://" + u.Host + u.Path; got != testRedirectURI {
+				t.Errorf("RedirectTo = %q, want the registered redirect uri", oauthErr.RedirectTo)
+			}
+			if query.Get("error") != tt.wantCode || query.Get("state") != "state" || query.Get("iss") != testIssuer {
+				t.Errorf("RedirectTo query = %v, want error %q, the state and the issuer", query, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestApproveAndExchange(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mJ92K9A1UZx7JhyN2ixJ6b6xPWOxpI"
+
+	s, repo, _ := newTestService(t)
+	repo.consents[consentKey{1, "confidential"}] = db.OauthConsent{UserID: 1, ClientID: "confidential", Scopes: []string{"profile"}}
+
+	req := AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            "confidential",
+		RedirectURI:         testRedirectURI,
+		Scope:               "openid posts:read",
+		State:               "state",
+		CodeChallenge:       s256(verifier),
+		CodeChallengeMethod: "S256",
+		Nonce:               "nonce",
+	}
+
+	redirectTo, err := s.Approve(context.Background(), 1, req, true)
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+
+	u, err := url.Parse(redirectTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("state") != "state" || query.Get("iss") != testIssuer || query.Get("code") == "" {
+		t.Fatalf("Approve() redirect query = %v, want a code, the state and the issuer", query)
+	}
+
+	// the approved scopes are added to the ones the user already allowed
+	consent := repo.consents[consentKey{1, "confidential"}]
+	if want := []string{"profile", "openid", "posts:read"}; !slices.Equal(consent.Scopes, want) {
+		t.Errorf("consent scopes = %v, want %v", consent.Scopes, want)
+	}
+
+	grant, err := s.ExchangeAuthorizationCode(context.Background(), "confidential", testSecret, query.Get("code"), testRedirectURI, verifier)
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode() error = %v", err)
+	}
+	if grant.User == nil || grant.User.ID != 1 || !slices.Equal(grant.Scopes, []string{"openid", "posts:read"}) || grant.Nonce != "nonce" {
+		t.Errorf("ExchangeAuthorizationCode() grant = %+v, want the approved user, scopes and nonce", grant)
+	}
+}
+
+func TestApproveDenied(t *testing.T) {
+	s, repo, fc := newTestService(t)
+
+	req := AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            "confidential",
+		RedirectURI:         testRedirectURI,
+		State:               "state",
+		CodeChallenge:       s256("verifier"),
+		CodeChallengeMethod: "S256",
+	}
+
+	redirectTo, err := s.Approve(context.Background(), 1, req, false)
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+
+	u, err := url.Parse(redirectTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query := u.Query(); query.Get("error") != ErrorAccessDenied || query.Get("code") != "" || query.Get("state") != "state" {
+		t.Errorf("Approve() redirect query = %v, want access_denied without a code", query)
+	}
+
+	if len(repo.consents) != 0 || len(fc.codes) != 0 {
+		t.Errorf("Approve() recorded %d consents and %d codes, want none", len(repo.consents), len(fc.codes))
+	}
+}
+
+func TestClientCredentialsToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientID   string
+		secret     string
+		scope      string
+		wantScopes []string
+		wantCode   string
+	}{
+		{name: "every allowed api scope", clientID: "service", secret: testSecret, wantScopes: []string{model.ScopePostsRead}},
+		// the openid connect and token scopes of the client are left out, there's no user
+		{name: "only the api scopes", clientID: "confidential", secret: testSecret, wantScopes: []string{model.ScopePostsRead}},
+		{name: "openid scope", clientID: "confidential", secret: testSecret, scope: "openid", wantCode: ErrorInvalidScope},
+		{name: "wrong secret", clientID: "service", secret: "wrong", wantCode: ErrorInvalidClient},
+		{name: "public client", clientID: "public", wantCode: ErrorUnauthorizedClient},
+		{name: "client without the grant", clientID: "first-party", secret: testSecret, wantCode: ErrorUnauthorizedClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t)
+
+			grant, err := s.ClientCredentialsToken(context.Background(), tt.clientID, tt.secret, tt.scope)
+			if got := errorCode(err); got != tt.wantCode {
+				t.Fatalf("ClientCredentialsToken() error = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			if grant.User != nil || grant.ClientID != tt.clientID || !slices.Equal(grant.Scopes, tt.wantScopes) {
+				t.Errorf("ClientCredentialsToken() grant = %+v, want client %q with scopes %v", grant, tt.clientID, tt.wantScopes)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+// authorizationCodeTTL is how long the client has to exchange the code once the user approved it
+const authorizationCodeTTL = 5 * time.Minute
+
+// AuthorizationRequest is the authorization request of the client, as the consent page received it
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// AuthorizationPrompt is what the consent page shows the user
+type AuthorizationPrompt struct {
+	Client model.OAuthClient `json:"client"`
+	Scopes []string          `json:"scopes"`
+	// ConsentRequired is false for first party clients and for scopes the user already allowed,
+	// the consent page can approve the request without asking
+	ConsentRequired bool `json:"consent_required"`
+}
+
+// Authorize checks the authorization request of the client and tells whether the user has to be asked
+func (s *Service) Authorize(ctx context.Context, userID int64, req AuthorizationRequest) (AuthorizationPrompt, error) {
+	client, scopes, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return AuthorizationPrompt{}, err
+	}
+
+	consentRequired := !client.FirstParty
+	if consentRequired {
+		consent, err := s.repo.GetOAuthConsent(ctx, db.GetOAuthConsentParams{UserID: userID, ClientID: client.ID})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("error getting oauth consent", slog.String("error", err.Error()))
+			return AuthorizationPrompt{}, err
+		}
+		consentRequired = !containsAll(consent.Scopes, scopes)
+	}
+
+	return AuthorizationPrompt{
+		Client:          model.DBOauthClientToModelOAuthClient(client)[0],
+		Scopes:          scopes,
+		ConsentRequired: consentRequired,
+	}, nil
+}
+
+// Approve answers the authorization request with the decision of the user, it returns where the user goes back
+// to the client, with an authorization code when the request was approved
+func (s *Service) Approve(ctx context.Context, userID int64, req AuthorizationRequest, approved bool) (string, error) {
+	client, scopes, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if !approved {
+		return s.redirectError(req, ErrorAccessDenied, "the user denied the request").RedirectTo, nil
+	}
+
+	if !client.FirstParty {
+		if err := s.recordConsent(ctx, userID, client.ID, scopes); err != nil {
+			return "", err
+		}
+	}
+
+	code, err := token.New(userID, authorizationCodeTTL)
+	if err != nil {
+		s.slog.Error("error creating authorization code", slog.String("error", err.Error()))
+		return "", err
+	}
+
+	value := cache.AuthorizationCode{
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+	}
+	if err := s.cache.SetAuthorizationCode(ctx, *code, value); err != nil {
+		s.slog.Error("error storing authorization code", slog.String("error", err.Error()))
+		return "", err
+	}
+
+	params := url.Values{"code": {code.PlainText}, "iss": {s.issuer}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	return redirectURL(req.RedirectURI, params), nil
+}
+
+// validateAuthorization returns the client and the requested scopes, the errors found before the redirect uri
+// is known to belong to the client are shown to the user, the other ones are sent to the client
+func (s *Service) validateAuthorization(ctx context.Context, req AuthorizationRequest) (db.OauthClient, []string, error) {
+	client, err := s.getClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, ErrClientNotFound) {
+			return db.OauthClient{}, nil, &Error{Code: ErrorInvalidRequest, Description: "unknown client_id"}
+		}
+		return db.OauthClient{}, nil, err
+	}
+
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return db.OauthClient{}, nil, &Error{Code: ErrorInvalidRequest, Description: "redirect_uri isn't registered for the client"}
+	}
+
+	if req.ResponseType != "code" {
+		return db.OauthClient{}, nil, s.redirectError(req, ErrorUnsupportedResponse, "only the code response type is supported")
+	}
+
+	if !slices.Contains(client.GrantTypes, model.GrantTypeAuthorizationCode) {
+		return db.OauthClient{}, nil, s.redirectError(req, ErrorUnauthorizedClient, "")
+	}
+
+	// pkce is required from every client, confidential ones included (RFC 9700)
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return db.OauthClient{}, nil, s.redirectError(req, ErrorInvalidRequest, "pkce with the S256 code_challenge_method is required")
+	}
+
+	scopes, ok := parseScopes(req.Scope, client.Scopes)
+	if !ok {
+		return db.OauthClient{}, nil, s.redirectError(req, ErrorInvalidScope, "")
+	}
+
+	return client, scopes, nil
+}
+
+// recordConsent adds the scopes to the ones the user already allowed the client to use
+func (s *Service) recordConsent(ctx context.Context, userID int64, clientID string, scopes []string) error {
+	consent, err := s.repo.GetOAuthConsent(ctx, db.GetOAuthConsentParams{UserID: userID, ClientID: clientID})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		s.slog.Error("error getting oauth consent", slog.String("error", err.Error()))
+		return err
+	}
+
+	allowed := slices.Clone(consent.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+
+	param := db.UpsertOAuthConsentParams{UserID: userID, ClientID: clientID, Scopes: allowed}
+	if err := s.repo.UpsertOAuthConsent(ctx, param); err != nil {
+		s.slog.Error("error storing oauth consent", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// redirectError is an error sent to the client through its redirect uri
+func (s *Service) redirectError(req AuthorizationRequest, code, description string) *Error {
+	params := url.Values{"error": {code}, "iss": {s.issuer}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	return &Error{Code: code, Description: description, RedirectTo: redirectURL(req.RedirectURI, params)}
+}
+
+// redirectURL adds the params to the registered redirect uri, keeping its own query
+func redirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// parseScopes splits the space separated scopes, every scope must be allowed, no scope means every allowed scope
+func parseScopes(scope string, allowed []string) ([]string, bool) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return slices.Clone(allowed), true
+	}
+
+	if !containsAll(allowed, scopes) {
+		return nil, false
+	}
+
+	return scopes, true
+}
+
+func containsAll(set, values []string) bool {
+	for _, v := range values {
+		if !slices.Contains(set, v) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,288 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+// token types returned by the introspection endpoint
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+	TokenTypeAPIKey       = "api_key"
+)
+
+// Introspection is the introspection response (RFC 7662), only Active is set when the token isn't active.
+// Role is the current role of the user, which is the one the permissions are checked against,
+// Actor is the admin acting as the user of an impersonation token
+type Introspection struct {
+	Active    bool         `json:"active"`
+	TokenType string       `json:"token_type,omitempty"`
+	Scope     string       `json:"scope,omitempty"`
+	ClientID  string       `json:"client_id,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	UserID    int64        `json:"user_id,omitempty"`
+	Role      model.Roles  `json:"role,omitempty"`
+	Username  string       `json:"username,omitempty"`
+	ExpiresAt int64        `json:"exp,omitempty"`
+	IssuedAt  int64        `json:"iat,omitempty"`
+	Issuer    string       `json:"iss,omitempty"`
+	TokenID   string       `json:"jti,omitempty"`
+	SessionID string       `json:"sid,omitempty"`
+	Actor     *token.Actor `json:"act,omitempty"`
+}
+
+// Introspect tells whether the token is still active and who it belongs to, it accepts the jwt access tokens,
+// the refresh tokens and the api keys. The kind of token is told by its format, so no type hint is needed
+func (s *Service) Introspect(ctx context.Context, tkn string) (Introspection, error) {
+	switch {
+	case strings.HasPrefix(tkn, token.APIKeyPrefix):
+		return s.introspectAPIKey(ctx, tkn)
+	case strings.Count(tkn, ".") == 2:
+		return s.introspectJWT(ctx, tkn)
+	default:
+		return s.introspectRefreshToken(ctx, tkn)
+	}
+}
+
+// Revoke revokes the token (RFC 7009), revoking the access token of a login also ends its session.
+// Unknown and already invalid tokens are ignored
+func (s *Service) Revoke(ctx context.Context, tkn string) error {
+	switch {
+	case strings.HasPrefix(tkn, token.APIKeyPrefix):
+		return s.revokeAPIKey(ctx, tkn)
+	case strings.Count(tkn, ".") == 2:
+		return s.revokeJWT(ctx, tkn)
+	default:
+		return s.revokeRefreshToken(ctx, tkn)
+	}
+}
+
+func (s *Service) introspectJWT(ctx context.Context, tkn string) (Introspection, error) {
+	claims, ok := s.parseAccessToken(tkn)
+	if !ok {
+		return Introspection{}, nil
+	}
+
+	denied, err := s.cache.IsAccessTokenDenied(ctx, claims.ID)
+	if err != nil {
+		s.slog.Error("error checking denied token", slog.String("error", err.Error()))
+		return Introspection{}, err
+	}
+	if denied {
+		return Introspection{}, nil
+	}
+
+	if claims.SessionID != "" {
+		if _, err := s.cache.GetSession(ctx, claims.SessionID); err != nil {
+			if errors.Is(err, cache.ErrTokenNotFound) {
+				return Introspection{}, nil
+			}
+			s.slog.Error("error getting session", slog.String("error", err.Error()))
+			return Introspection{}, err
+		}
+	}
+
+	// the tokens of a revoked client or consent stop being active with it
+	if claims.ClientID != "" {
+		active, err := s.IsClientTokenActive(ctx, claims.ClientID, claims.UserID)
+		if err != nil || !active {
+			return Introspection{}, err
+		}
+	}
+
+	introspection := Introspection{
+		Active:    true,
+		TokenType: TokenTypeAccessToken,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Subject:   claims.Subject,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		Issuer:    claims.Issuer,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+		Actor:     claims.Actor,
+	}
+
+	// a client acting on its own behalf has no user
+	if claims.UserID == 0 {
+		return introspection, nil
+	}
+
+	revokedBefore, err := s.cache.TokensRevokedBefore(ctx, claims.UserID)
+	if err != nil {
+		s.slog.Error("error checking revoked tokens", slog.String("error", err.Error()))
+		return Introspection{}, err
+	}
+	if !revokedBefore.IsZero() && !claims.IssuedAt.After(revokedBefore) {
+		return Introspection{}, nil
+	}
+
+	return s.withUser(ctx, introspection, claims.UserID)
+}
+
+func (s *Service) introspectRefreshToken(ctx context.Context, tkn string) (Introspection, error) {
+	refreshToken, err := s.cache.GetRefreshToken(ctx, token.Hash(tkn))
+	if err != nil {
+		// a rotated token can't be used anymore, even though it's kept to detect its reuse
+		if errors.Is(err, cache.ErrTokenNotFound) || errors.Is(err, cache.ErrTokenReused) {
+			return Introspection{}, nil
+		}
+		s.slog.Error("error getting refresh token", slog.String("error", err.Error()))
+		return Introspection{}, err
+	}
+
+	revoked, err := s.cache.IsTokenFamilyRevoked(ctx, refreshToken.Family)
+	if err != nil {
+		s.slog.Error("error checking token family", slog.String("error", err.Error()))
+		return Introspection{}, err
+	}
+	if revoked {
+		return Introspection{}, nil
+	}
+
+	introspection := Introspection{
+		Active:    true,
+		TokenType: TokenTypeRefreshToken,
+		ExpiresAt: time.Now().Add(refreshToken.Expiry).Unix(),
+		SessionID: refreshToken.Family,
+	}
+
+	return s.withUser(ctx, introspection, refreshToken.User.ID)
+}
+
+func (s *Service) introspectAPIKey(ctx context.Context, tkn string) (Introspection, error) {
+	apiKey, ok, err := s.getAPIKey(ctx, tkn)
+	if err != nil || !ok {
+		return Introspection{}, err
+	}
+
+	introspection := Introspection{
+		Active:    true,
+		TokenType: TokenTypeAPIKey,
+		Scope:     strings.Join(apiKey.Scopes, " "),
+		ExpiresAt: apiKey.ExpiresAt.Time.Unix(),
+		IssuedAt:  apiKey.CreatedAt.Time.Unix(),
+		TokenID:   strconv.FormatInt(apiKey.ID, 10),
+	}
+
+	return s.withUser(ctx, introspection, apiKey.UserID)
+}
+
+// withUser adds the user to the introspection, the token isn't active anymore when the user can't log in
+func (s *Service) withUser(ctx context.Context, introspection Introspection, userID int64) (Introspection, error) {
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Introspection{}, nil
+		}
+		return Introspection{}, err
+	}
+
+	introspection.UserID = user.ID
+	introspection.Role = user.Role
+	introspection.Username = user.Username
+	if introspection.Subject == "" {
+		introspection.Subject = strconv.FormatInt(user.ID, 10)
+	}
+
+	return introspection, nil
+}
+
+func (s *Service) revokeJWT(ctx context.Context, tkn string) error {
+	claims, ok := s.parseAccessToken(tkn)
+	if !ok {
+		return nil
+	}
+
+	if err := s.cache.DenyAccessToken(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		s.slog.Error("error denying access token", slog.String("error", err.Error()))
+		return err
+	}
+
+	if claims.SessionID != "" {
+		if err := s.cache.RevokeTokenFamily(ctx, claims.SessionID, token.RefreshTokenTTL); err != nil {
+			s.slog.Error("error revoking session", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) revokeRefreshToken(ctx context.Context, tkn string) error {
+	// revoking a rotated token still revokes its family
+	refreshToken, err := s.cache.GetRefreshToken(ctx, token.Hash(tkn))
+	if err != nil && !errors.Is(err, cache.ErrTokenReused) {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return nil
+		}
+		s.slog.Error("error getting refresh token", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.cache.RevokeTokenFamily(ctx, refreshToken.Family, token.RefreshTokenTTL); err != nil {
+		s.slog.Error("error revoking token family", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) revokeAPIKey(ctx context.Context, tkn string) error {
+	apiKey, ok, err := s.getAPIKey(ctx, tkn)
+	if err != nil || !ok {
+		return err
+	}
+
+	if _, err := s.repo.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{ID: apiKey.ID, UserID: apiKey.UserID}); err != nil {
+		s.slog.Error("error revoking api key", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// parseAccessToken verifies the signature and the expiry of the jwt, id tokens aren't access tokens
+func (s *Service) parseAccessToken(tkn string) (*token.JwtCustomClaims, bool) {
+	claims := new(token.JwtCustomClaims)
+	if _, err := jwt.ParseWithClaims(tkn, claims, s.keys.Keyfunc); err != nil {
+		return nil, false
+	}
+
+	if !claims.IsAccessToken() || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// getAPIKey returns the api key when it's neither revoked nor expired
+func (s *Service) getAPIKey(ctx context.Context, key string) (db.ApiKey, bool, error) {
+	apiKey, err := s.repo.GetAPIKeyByHash(ctx, token.Hash(key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ApiKey{}, false, nil
+		}
+		s.slog.Error("error getting api key", slog.String("error", err.Error()))
+		return db.ApiKey{}, false, err
+	}
+
+	if !apiKey.ExpiresAt.Time.After(time.Now()) {
+		return db.ApiKey{}, false, nil
+	}
+
+	return apiKey, true, nil
+}
@@ -0,0 +1,419 @@
+package authorization
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestIntrospect(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the token to introspect
+		setup      func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string
+		wantActive bool
+		wantType   string
+	}{
+		{
+			name: "access token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+				return newAccessToken(t, s, 1, "session")
+			},
+			wantActive: true,
+			wantType:   TokenTypeAccessToken,
+		},
+		{
+			name: "denied access token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+				tkn := newAccessToken(t, s, 1, "session")
+				fc.denied[parseClaims(t, s, tkn).ID] = true
+				return tkn
+			},
+		},
+		{
+			name: "access token of a logged out session",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newAccessToken(t, s, 1, "session")
+			},
+		},
+		{
+			name: "access token issued before the tokens of the user were revoked",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+				fc.revokedBefore[1] = time.Now().Add(time.Second)
+				return newAccessToken(t, s, 1, "session")
+			},
+		},
+		{
+			name: "access token issued after the tokens of the user were revoked",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+				fc.revokedBefore[1] = time.Now().Add(-time.Second)
+				return newAccessToken(t, s, 1, "session")
+			},
+			wantActive: true,
+			wantType:   TokenTypeAccessToken,
+		},
+		{
+			name: "access token of a suspended user",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 2}
+				return newAccessToken(t, s, 2, "session")
+			},
+		},
+		{
+			name: "access token of a deleted user",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 3}
+				return newAccessToken(t, s, 3, "session")
+			},
+		},
+		{
+			name: "expired access token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				tkn, err := s.keys.Sign(&token.JwtCustomClaims{
+					UserID: 1,
+					RegisteredClaims: jwt.RegisteredClaims{
+						ID:        "expired",
+						IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tkn
+			},
+		},
+		{
+			name: "id token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				tkn, err := s.keys.NewIDToken(testIssuer, "confidential", 1, "nonce", model.UserClaims{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tkn
+			},
+		},
+		{
+			name: "access token signed by another key",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				other, _, _ := newTestService(t)
+				fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+				return newAccessToken(t, other, 1, "session")
+			},
+		},
+		{
+			name: "oauth token of a user",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				repo.consents[consentKey{1, "confidential"}] = db.OauthConsent{UserID: 1, ClientID: "confidential", Scopes: []string{"profile"}}
+				return newOAuthToken(t, s, 1, "confidential")
+			},
+			wantActive: true,
+			wantType:   TokenTypeAccessToken,
+		},
+		{
+			name: "oauth token after the consent was revoked",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newOAuthToken(t, s, 1, "confidential")
+			},
+		},
+		{
+			name: "oauth token of a revoked client",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				repo.consents[consentKey{1, "confidential"}] = db.OauthConsent{UserID: 1, ClientID: "confidential", Scopes: []string{"profile"}}
+				tkn := newOAuthToken(t, s, 1, "confidential")
+				delete(repo.clients, "confidential")
+				return tkn
+			},
+		},
+		{
+			name: "oauth token of a client acting on its own behalf",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newOAuthToken(t, s, 0, "service")
+			},
+			wantActive: true,
+			wantType:   TokenTypeAccessToken,
+		},
+		{
+			name: "refresh token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newRefreshToken(t, fc, 1, "family")
+			},
+			wantActive: true,
+			wantType:   TokenTypeRefreshToken,
+		},
+		{
+			name: "rotated refresh token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				tkn := newRefreshToken(t, fc, 1, "family")
+				fc.usedTokens[hex.EncodeToString(token.Hash(tkn))] = true
+				return tkn
+			},
+		},
+		{
+			name: "refresh token of a revoked family",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				fc.revokedFamilies["family"] = true
+				return newRefreshToken(t, fc, 1, "family")
+			},
+		},
+		{
+			name: "refresh token of a suspended user",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newRefreshToken(t, fc, 2, "family")
+			},
+		},
+		{
+			name: "unknown refresh token",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return "unknown"
+			},
+		},
+		{
+			name: "api key",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newAPIKey(t, repo, 1, time.Hour)
+			},
+			wantActive: true,
+			wantType:   TokenTypeAPIKey,
+		},
+		{
+			name: "expired api key",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newAPIKey(t, repo, 1, -time.Minute)
+			},
+		},
+		{
+			name: "api key of a suspended user",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return newAPIKey(t, repo, 2, time.Hour)
+			},
+		},
+		{
+			name: "unknown api key",
+			setup: func(t *testing.T, s *Service, repo *fakeRepo, fc *fakeCache) string {
+				return token.APIKeyPrefix + "unknown"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, fc := newTestService(t)
+			tkn := tt.setup(t, s, repo, fc)
+
+			introspection, err := s.Introspect(context.Background(), tkn)
+			if err != nil {
+				t.Fatalf("Introspect() error = %v", err)
+			}
+
+			if !tt.wantActive {
+				// nothing but active is sent back for a token that isn't active
+				if introspection != (Introspection{}) {
+					t.Errorf("Introspect() = %+v, want an inactive token", introspection)
+				}
+				return
+			}
+
+			if !introspection.Active || introspection.TokenType != tt.wantType {
+				t.Errorf("Introspect() active = %v type = %q, want an active %q", introspection.Active, introspection.TokenType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestIntrospectUser(t *testing.T) {
+	s, _, fc := newTestService(t)
+	fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+
+	introspection, err := s.Introspect(context.Background(), newAccessToken(t, s, 1, "session"))
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+
+	if introspection.UserID != 1 || introspection.Subject != "1" || introspection.Username != "alice" || introspection.Role != model.RolesUser {
+		t.Errorf("Introspect() = %+v, want the user, subject, username and role of user 1", introspection)
+	}
+	if introspection.SessionID != "session" || introspection.TokenID == "" || introspection.ExpiresAt == 0 || introspection.IssuedAt == 0 {
+		t.Errorf("Introspect() = %+v, want the session, token id and times of the token", introspection)
+	}
+	if introspection.Actor != nil {
+		t.Errorf("Introspect() Actor = %+v, want none", introspection.Actor)
+	}
+}
+
+func TestIntrospectImpersonation(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	tkn, _, err := s.keys.NewImpersonationJWT(1, model.RolesUser, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	introspection, err := s.Introspect(context.Background(), tkn)
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+
+	if !introspection.Active || introspection.UserID != 1 || introspection.Actor == nil || introspection.Actor.UserID != 4 {
+		t.Errorf("Introspect() = %+v, want user 1 impersonated by user 4", introspection)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	t.Run("access token", func(t *testing.T) {
+		s, _, fc := newTestService(t)
+		fc.sessions["session"] = model.Session{ID: "session", UserID: 1}
+		tkn := newAccessToken(t, s, 1, "session")
+
+		if err := s.Revoke(context.Background(), tkn); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+
+		// the token is denied and its session ends with it
+		if !fc.denied[parseClaims(t, s, tkn).ID] || !fc.revokedFamilies["session"] {
+			t.Errorf("Revoke() denied = %v revoked families = %v, want the token denied and its session revoked", fc.denied, fc.revokedFamilies)
+		}
+		assertInactive(t, s, tkn)
+	})
+
+	t.Run("refresh token", func(t *testing.T) {
+		s, _, fc := newTestService(t)
+		tkn := newRefreshToken(t, fc, 1, "family")
+
+		if err := s.Revoke(context.Background(), tkn); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+
+		if !fc.revokedFamilies["family"] {
+			t.Errorf("Revoke() didn't revoke the token family")
+		}
+		assertInactive(t, s, tkn)
+	})
+
+	t.Run("rotated refresh token", func(t *testing.T) {
+		s, _, fc := newTestService(t)
+		tkn := newRefreshToken(t, fc, 1, "family")
+		fc.usedTokens[hex.EncodeToString(token.Hash(tkn))] = true
+
+		if err := s.Revoke(context.Background(), tkn); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+
+		if !fc.revokedFamilies["family"] {
+			t.Errorf("Revoke() didn't revoke the family of the rotated token")
+		}
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		s, repo, _ := newTestService(t)
+		tkn := newAPIKey(t, repo, 1, time.Hour)
+
+		if err := s.Revoke(context.Background(), tkn); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+
+		if len(repo.revokedAPIKeys) != 1 {
+			t.Errorf("Revoke() revoked api keys = %v, want the key", repo.revokedAPIKeys)
+		}
+		assertInactive(t, s, tkn)
+	})
+
+	t.Run("unknown tokens are ignored", func(t *testing.T) {
+		s, repo, fc := newTestService(t)
+
+		for _, tkn := range []string{"unknown", token.APIKeyPrefix + "unknown", "not.a.jwt"} {
+			if err := s.Revoke(context.Background(), tkn); err != nil {
+				t.Errorf("Revoke(%q) error = %v", tkn, err)
+			}
+		}
+
+		if len(fc.denied) != 0 || len(fc.revokedFamilies) != 0 || len(repo.revokedAPIKeys) != 0 {
+			t.Errorf("Revoke() revoked something for unknown tokens")
+		}
+	})
+}
+
+func newAccessToken(t *testing.T, s *Service, userID int64, sessionID string) string {
+	t.Helper()
+
+	tkn, err := s.keys.NewJWT(userID, model.RolesUser, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tkn
+}
+
+func newOAuthToken(t *testing.T, s *Service, userID int64, clientID string) string {
+	t.Helper()
+
+	subject := clientID
+	if userID != 0 {
+		subject = "1"
+	}
+	tkn, _, err := s.keys.NewOAuthJWT(testIssuer, subject, userID, model.RolesUser, clientID, []string{model.ScopeProfile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tkn
+}
+
+func newRefreshToken(t *testing.T, fc *fakeCache, userID int64, family string) string {
+	t.Helper()
+
+	tkn, err := token.NewRefresh(userID, family, token.RefreshTokenTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.refreshTokens[hex.EncodeToString(tkn.Hash)] = *tkn
+	return tkn.PlainText
+}
+
+func newAPIKey(t *testing.T, repo *fakeRepo, userID int64, ttl time.Duration) string {
+	t.Helper()
+
+	tkn, err := token.NewAPIKey(userID, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.apiKeys[hex.EncodeToString(tkn.Hash)] = db.ApiKey{
+		ID:        int64(len(repo.apiKeys) + 1),
+		UserID:    userID,
+		Scopes:    []string{model.ScopePostsRead},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(ttl), Valid: true},
+	}
+	return tkn.PlainText
+}
+
+func parseClaims(t *testing.T, s *Service, tkn string) *token.JwtCustomClaims {
+	t.Helper()
+
+	claims, ok := s.parseAccessToken(tkn)
+	if !ok {
+		t.Fatalf("parseAccessToken(%q) failed", tkn)
+	}
+	return claims
+}
+
+func assertInactive(t *testing.T, s *Service, tkn string) {
+	t.Helper()
+
+	introspection, err := s.Introspect(context.Background(), tkn)
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+	if introspection.Active {
+		t.Errorf("Introspect() after Revoke() = %+v, want an inactive token", introspection)
+	}
+}
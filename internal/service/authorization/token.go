@@ -0,0 +1,126 @@
+package authorization
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/izzanzahrial/skeleton/internal/domain/authentication/cache"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/izzanzahrial/skeleton/pkg/token"
+	"github.com/jackc/pgx/v5"
+)
+
+// Grant is what the token endpoint issues tokens for, User is nil when the client acts on its own behalf
+type Grant struct {
+	ClientID string
+	User     *model.User
+	Scopes   []string
+	Nonce    string
+}
+
+// ExchangeAuthorizationCode returns the grant of the code, the client must be the one the code was issued to
+// and prove it started the authorization with the pkce verifier
+func (s *Service) ExchangeAuthorizationCode(ctx context.Context, clientID, secret, code, redirectURI, verifier string) (Grant, error) {
+	client, err := s.authenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return Grant{}, err
+	}
+
+	if !slices.Contains(client.GrantTypes, model.GrantTypeAuthorizationCode) {
+		return Grant{}, &Error{Code: ErrorUnauthorizedClient}
+	}
+
+	if code == "" || verifier == "" {
+		return Grant{}, &Error{Code: ErrorInvalidRequest, Description: "code and code_verifier are required"}
+	}
+
+	value, err := s.cache.ConsumeAuthorizationCode(ctx, token.Hash(code))
+	if err != nil {
+		if errors.Is(err, cache.ErrTokenNotFound) {
+			return Grant{}, &Error{Code: ErrorInvalidGrant}
+		}
+		s.slog.Error("error getting authorization code", slog.String("error", err.Error()))
+		return Grant{}, err
+	}
+
+	if value.ClientID != client.ID || value.RedirectURI != redirectURI {
+		return Grant{}, &Error{Code: ErrorInvalidGrant}
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(challenge[:])), []byte(value.CodeChallenge)) != 1 {
+		return Grant{}, &Error{Code: ErrorInvalidGrant, Description: "code_verifier doesn't match the code_challenge"}
+	}
+
+	user, err := s.getActiveUser(ctx, value.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Grant{}, &Error{Code: ErrorInvalidGrant}
+		}
+		return Grant{}, err
+	}
+
+	return Grant{ClientID: client.ID, User: &user, Scopes: value.Scopes, Nonce: value.Nonce}, nil
+}
+
+// ClientCredentialsToken returns the grant of a confidential client acting on its own behalf,
+// the openid connect scopes are left out since there's no user
+func (s *Service) ClientCredentialsToken(ctx context.Context, clientID, secret, scope string) (Grant, error) {
+	client, err := s.authenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return Grant{}, err
+	}
+
+	if len(client.SecretHash) == 0 || !slices.Contains(client.GrantTypes, model.GrantTypeClientCredentials) {
+		return Grant{}, &Error{Code: ErrorUnauthorizedClient}
+	}
+
+	allowed := slices.DeleteFunc(slices.Clone(client.Scopes), func(scope string) bool {
+		return !slices.Contains(model.APIKeyScopes, scope)
+	})
+
+	scopes, ok := parseScopes(scope, allowed)
+	if !ok {
+		return Grant{}, &Error{Code: ErrorInvalidScope}
+	}
+
+	return Grant{ClientID: client.ID, Scopes: scopes}, nil
+}
+
+// UserInfo returns the claims of the user the scopes give access to
+func (s *Service) UserInfo(ctx context.Context, userID int64, scopes []string) (model.UserInfo, error) {
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return model.UserInfo{}, err
+	}
+
+	return model.UserInfo{Subject: strconv.FormatInt(user.ID, 10), UserClaims: model.NewUserClaims(user, scopes)}, nil
+}
+
+// getActiveUser returns the user, deleted and suspended users aren't found
+func (s *Service) getActiveUser(ctx context.Context, userID int64) (model.User, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("error getting user", slog.String("error", err.Error()))
+		}
+		return model.User{}, err
+	}
+
+	if user.DeletedAt.Valid {
+		return model.User{}, pgx.ErrNoRows
+	}
+
+	if _, suspended := model.DBUserSuspension(user, time.Now()); suspended {
+		return model.User{}, pgx.ErrNoRows
+	}
+
+	return model.DBUserToModelUser(user)[0], nil
+}
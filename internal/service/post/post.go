@@ -15,6 +15,9 @@ import (
 
 type postRepo interface {
 	CreatePost(ctx context.Context, arg db.CreatePostParams) (db.Post, error)
+	GetPost(ctx context.Context, id int64) (db.Post, error)
+	UpdatePost(ctx context.Context, arg db.UpdatePostParams) (db.Post, error)
+	DeletePost(ctx context.Context, id int64) error
 	GetPostByUserID(ctx context.Context, userID int64) ([]db.Post, error)
 	GetPostsFullText(ctx context.Context, arg db.GetPostsFullTextParams) ([]db.Post, error)
 }
@@ -101,3 +104,51 @@ func (s *Service) GetPostsFullText(ctx context.Context, limit, offset int, keywo
 	modelPost := model.DBPostToModelPost(posts...)
 	return modelPost, nil
 }
+
+func (s *Service) GetPost(ctx context.Context, id int64) (model.Post, error) {
+	post, err := s.repo.GetPost(ctx, id)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("failed to get post", slog.String("error", err.Error()))
+		}
+		return model.Post{}, err
+	}
+
+	return model.DBPostToModelPost(post)[0], nil
+}
+
+func (s *Service) UpdatePost(ctx context.Context, id int64, title, content *string) (model.Post, error) {
+	post, err := s.repo.GetPost(ctx, id)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("failed to get post", slog.String("error", err.Error()))
+		}
+		return model.Post{}, err
+	}
+
+	if title != nil {
+		post.Title = *title
+	}
+	if content != nil {
+		post.Content = *content
+	}
+
+	updatedPost, err := s.repo.UpdatePost(ctx, db.UpdatePostParams{Title: post.Title, Content: post.Content, ID: id})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("failed to update post", slog.String("error", err.Error()))
+		}
+		return model.Post{}, err
+	}
+
+	return model.DBPostToModelPost(updatedPost)[0], nil
+}
+
+func (s *Service) DeletePost(ctx context.Context, id int64) error {
+	if err := s.repo.DeletePost(ctx, id); err != nil {
+		s.slog.Error("failed to delete post", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	db "github.com/izzanzahrial/skeleton/db/sqlc"
+	"github.com/izzanzahrial/skeleton/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// uniqueViolation is the postgres error code of a unique constraint violation
+	uniqueViolation = "23505"
+	// foreignKeyViolation is the postgres error code of a foreign key constraint violation
+	foreignKeyViolation = "23503"
+)
+
+var (
+	ErrRoleExists        = errors.New("role already exists")
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleInUse         = errors.New("role is still assigned to users")
+	ErrProtectedRole     = errors.New("role can't be changed")
+	ErrUnknownPermission = errors.New("unknown permission")
+)
+
+type roleRepo interface {
+	CreateRole(ctx context.Context, arg db.CreateRoleParams) (db.Role, error)
+	GetRole(ctx context.Context, name string) (db.Role, error)
+	GetRoles(ctx context.Context) ([]db.Role, error)
+	DeleteRole(ctx context.Context, name string) (int64, error)
+	GetPermissions(ctx context.Context) ([]db.Permission, error)
+	GetRolePermissions(ctx context.Context, role string) ([]string, error)
+	GetAllRolePermissions(ctx context.Context) ([]db.RolePermission, error)
+	AddRolePermissions(ctx context.Context, arg db.AddRolePermissionsParams) error
+	DeleteRolePermissions(ctx context.Context, role string) error
+	UserHasPermission(ctx context.Context, arg db.UserHasPermissionParams) (bool, error)
+	UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error)
+}
+
+// txBeginner starts the transaction of the writes that span several role queries
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type Service struct {
+	repo roleRepo
+	conn txBeginner
+	slog *slog.Logger
+}
+
+func NewService(repo roleRepo, conn txBeginner, slog *slog.Logger) *Service {
+	return &Service{
+		repo: repo,
+		conn: conn,
+		slog: slog,
+	}
+}
+
+// HasPermission checks the permission against the current role of the user,
+// so a role change applies to the tokens that were already issued
+func (s *Service) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	ok, err := s.repo.UserHasPermission(ctx, db.UserHasPermissionParams{ID: userID, Permission: permission})
+	if err != nil {
+		s.slog.Error("error checking user permission", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (s *Service) GetPermissions(ctx context.Context) ([]model.Permission, error) {
+	permissions, err := s.repo.GetPermissions(ctx)
+	if err != nil {
+		s.slog.Error("error getting permissions", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return model.DBPermissionToModelPermission(permissions...), nil
+}
+
+func (s *Service) GetRoles(ctx context.Context) ([]model.Role, error) {
+	roles, err := s.repo.GetRoles(ctx)
+	if err != nil {
+		s.slog.Error("error getting roles", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	rolePermissions, err := s.repo.GetAllRolePermissions(ctx)
+	if err != nil {
+		s.slog.Error("error getting role permissions", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	permissions := make(map[string][]string)
+	for _, rp := range rolePermissions {
+		permissions[rp.Role] = append(permissions[rp.Role], rp.Permission)
+	}
+
+	modelRoles := make([]model.Role, 0, len(roles))
+	for _, role := range roles {
+		modelRoles = append(modelRoles, model.DBRoleToModelRole(role, permissions[role.Name]))
+	}
+
+	return modelRoles, nil
+}
+
+func (s *Service) CreateRole(ctx context.Context, name, description string, permissions []string) (model.Role, error) {
+	if err := s.checkPermissions(ctx, permissions); err != nil {
+		return model.Role{}, err
+	}
+
+	var role db.Role
+	err := s.inTx(ctx, func(repo roleRepo) error {
+		var err error
+		role, err = repo.CreateRole(ctx, db.CreateRoleParams{Name: name, Description: description})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return ErrRoleExists
+			}
+			s.slog.Error("error creating role", slog.String("error", err.Error()))
+			return err
+		}
+
+		if err := repo.AddRolePermissions(ctx, db.AddRolePermissionsParams{Role: name, Permissions: permissions}); err != nil {
+			s.slog.Error("error adding role permissions", slog.String("error", err.Error()))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.Role{}, err
+	}
+
+	return model.DBRoleToModelRole(role, permissions), nil
+}
+
+// SetRolePermissions replaces the permissions of the role,
+// the admin role always keeps every permission so it can't lock itself out
+func (s *Service) SetRolePermissions(ctx context.Context, name string, permissions []string) (model.Role, error) {
+	role, err := s.getRole(ctx, name)
+	if err != nil {
+		return model.Role{}, err
+	}
+
+	if role.Name == string(model.RolesAdmin) {
+		return model.Role{}, ErrProtectedRole
+	}
+
+	if err := s.checkPermissions(ctx, permissions); err != nil {
+		return model.Role{}, err
+	}
+
+	err = s.inTx(ctx, func(repo roleRepo) error {
+		if err := repo.DeleteRolePermissions(ctx, name); err != nil {
+			s.slog.Error("error deleting role permissions", slog.String("error", err.Error()))
+			return err
+		}
+
+		if err := repo.AddRolePermissions(ctx, db.AddRolePermissionsParams{Role: name, Permissions: permissions}); err != nil {
+			s.slog.Error("error adding role permissions", slog.String("error", err.Error()))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.Role{}, err
+	}
+
+	return model.DBRoleToModelRole(role, permissions), nil
+}
+
+// DeleteRole deletes a role that isn't builtin and isn't assigned to any user
+func (s *Service) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.getRole(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if role.Builtin {
+		return ErrProtectedRole
+	}
+
+	if _, err := s.repo.DeleteRole(ctx, name); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return ErrRoleInUse
+		}
+		s.slog.Error("error deleting role", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// AssignRole replaces the role of the user
+func (s *Service) AssignRole(ctx context.Context, userID int64, name string) (model.User, error) {
+	if _, err := s.getRole(ctx, name); err != nil {
+		return model.User{}, err
+	}
+
+	user, err := s.repo.UpdateUserRole(ctx, db.UpdateUserRoleParams{Role: name, ID: userID})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.slog.Error("error updating user role", slog.String("error", err.Error()))
+		}
+		return model.User{}, err
+	}
+
+	return model.DBUserToModelUser(user)[0], nil
+}
+
+func (s *Service) getRole(ctx context.Context, name string) (db.Role, error) {
+	role, err := s.repo.GetRole(ctx, name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Role{}, ErrRoleNotFound
+		}
+		s.slog.Error("error getting role", slog.String("error", err.Error()))
+		return db.Role{}, err
+	}
+
+	return role, nil
+}
+
+// inTx runs fn against the queries of one transaction,
+// the transaction is only committed when fn succeeds so a failed write leaves the role as it was
+func (s *Service) inTx(ctx context.Context, fn func(repo roleRepo) error) error {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		s.slog.Error("error beginning transaction", slog.String("error", err.Error()))
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(db.New(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.slog.Error("error committing transaction", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// checkPermissions rejects permissions that aren't in the permissions table
+func (s *Service) checkPermissions(ctx context.Context, permissions []string) error {
+	known, err := s.repo.GetPermissions(ctx)
+	if err != nil {
+		s.slog.Error("error getting permissions", slog.String("error", err.Error()))
+		return err
+	}
+
+	names := make(map[string]bool, len(known))
+	for _, p := range known {
+		names[p.Name] = true
+	}
+
+	for _, p := range permissions {
+		if !names[p] {
+			return ErrUnknownPermission
+		}
+	}
+
+	return nil
+}
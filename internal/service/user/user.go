@@ -24,19 +24,21 @@ type userRepo interface {
 }
 
 type Service struct {
-	repo userRepo
-	slog *slog.Logger
+	repo   userRepo
+	hasher *pass.Hasher
+	slog   *slog.Logger
 }
 
-func NewService(repo userRepo, slog *slog.Logger) *Service {
+func NewService(repo userRepo, hasher *pass.Hasher, slog *slog.Logger) *Service {
 	return &Service{
-		repo: repo,
-		slog: slog,
+		repo:   repo,
+		hasher: hasher,
+		slog:   slog,
 	}
 }
 
 func (s *Service) CreateUser(ctx context.Context, email, username, password string) (model.User, error) {
-	passHash, err := pass.Generate(password)
+	passHash, err := s.hasher.Generate(password)
 	if err != nil {
 		s.slog.Error("failed to generate password hash", slog.String("error", err.Error()))
 		return model.User{}, err
@@ -46,7 +48,7 @@ func (s *Service) CreateUser(ctx context.Context, email, username, password stri
 		Email:        email,
 		Username:     pgtype.Text{String: username, Valid: true},
 		PasswordHash: passHash,
-		Role:         db.RolesUser,
+		Role:         string(model.RolesUser),
 		Origin:       db.OriginsNative,
 	}
 
@@ -61,7 +63,7 @@ func (s *Service) CreateUser(ctx context.Context, email, username, password stri
 }
 
 func (s *Service) CreateAdmin(ctx context.Context, email, username, password string) (model.User, error) {
-	passHash, err := pass.Generate(password)
+	passHash, err := s.hasher.Generate(password)
 	if err != nil {
 		s.slog.Error("failed to generate password hash", slog.String("error", err.Error()))
 		return model.User{}, err
@@ -71,7 +73,7 @@ func (s *Service) CreateAdmin(ctx context.Context, email, username, password str
 		Email:        email,
 		Username:     pgtype.Text{String: username, Valid: true},
 		PasswordHash: passHash,
-		Role:         db.RolesAdmin,
+		Role:         string(model.RolesAdmin),
 		Origin:       db.OriginsNative,
 	}
 
@@ -117,7 +119,7 @@ func (s *Service) GetUsersByRole(ctx context.Context, role model.Roles, limit, o
 		newLimit.Valid = true
 	}
 
-	users, err := s.repo.GetUsersByRole(ctx, db.GetUsersByRoleParams{Role: db.Roles(role), LimitParam: newLimit, Offset: offset})
+	users, err := s.repo.GetUsersByRole(ctx, db.GetUsersByRoleParams{Role: string(role), LimitParam: newLimit, Offset: offset})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("user not found: %w", err)
@@ -161,7 +163,7 @@ func (s *Service) GetuserByEmailOrUsername(ctx context.Context, email, username
 	return model.DBUserToModelUser(user)[0], nil
 }
 
-func (s *Service) UpdateUser(ctx context.Context, id int64, email, username, password *string) (model.User, error) {
+func (s *Service) UpdateUser(ctx context.Context, id int64, email, username, newPassword *string) (model.User, error) {
 	user, err := s.repo.GetUser(ctx, id)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
@@ -171,14 +173,21 @@ func (s *Service) UpdateUser(ctx context.Context, id int64, email, username, pas
 		return model.User{}, err
 	}
 
+	// the request only holds the changed values, so the password is also checked against the stored ones
+	userInputs := []string{user.Email, user.Username.String}
+
 	if email != nil {
 		user.Email = *email
 	}
 	if username != nil {
 		user.Username = pgtype.Text{String: *username, Valid: true}
 	}
-	if password != nil {
-		passHash, err := pass.Generate(*password)
+	if newPassword != nil {
+		if err := pass.CheckSimilar(*newPassword, append(userInputs, user.Email, user.Username.String)...); err != nil {
+			return model.User{}, err
+		}
+
+		passHash, err := s.hasher.Generate(*newPassword)
 		if err != nil {
 			s.slog.Error("failed to generate password hash", slog.String("error", err.Error()))
 			return model.User{}, err
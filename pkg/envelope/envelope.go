@@ -0,0 +1,219 @@
+// Package envelope encrypts fields with AES-GCM using envelope encryption: every value gets its own
+// data key, which is stored next to the value wrapped by a versioned master key.
+// Rotating the master key only needs the data keys to be rewrapped, see Keyring.Rewrap
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// prefix marks an encrypted value, so values stored before the encryption was added can be told apart
+const prefix = "enc:v1:"
+
+const keySize = 32
+
+var (
+	ErrUnknownKeyVersion = errors.New("unknown master key version")
+	ErrMalformed         = errors.New("malformed encrypted value")
+)
+
+// Keyring holds the master keys by version, new values are encrypted with the active one
+type Keyring struct {
+	active uint32
+	keys   map[uint32]cipher.AEAD
+}
+
+// NewKeyring needs 32 bytes master keys, the old keys must be kept until every value was rewrapped
+func NewKeyring(active uint32, keys map[uint32][]byte) (*Keyring, error) {
+	k := &Keyring{active: active, keys: make(map[uint32]cipher.AEAD, len(keys))}
+
+	for version, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("master key %d must be %d bytes", version, keySize)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[version] = aead
+	}
+
+	if _, ok := k.keys[active]; !ok {
+		return nil, fmt.Errorf("active master key %d: %w", active, ErrUnknownKeyVersion)
+	}
+
+	return k, nil
+}
+
+// IsEncrypted reports whether the value was made by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt encrypts the plaintext with a new data key, aad binds the value to its field
+// so it can't be copied into another field
+func (k *Keyring) Encrypt(plaintext, aad []byte) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(aead, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.keys[k.active], dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return format(k.active, wrappedKey, ciphertext), nil
+}
+
+// Decrypt returns the plaintext of a value made by Encrypt, aad must be the one it was encrypted with
+func (k *Keyring) Decrypt(value string, aad []byte) ([]byte, error) {
+	_, dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := open(aead, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+// NeedsRewrap reports whether the value isn't encrypted yet or its data key is wrapped by an old master key
+func (k *Keyring) NeedsRewrap(value string) bool {
+	version, _, _, err := parse(value)
+	return err != nil || version != k.active
+}
+
+// Rewrap wraps the data key of the value with the active master key, the value itself isn't decrypted
+func (k *Keyring) Rewrap(value string) (string, error) {
+	_, dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.keys[k.active], dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return format(k.active, wrappedKey, ciphertext), nil
+}
+
+func (k *Keyring) unwrap(value string) (uint32, []byte, []byte, error) {
+	version, wrappedKey, ciphertext, err := parse(value)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	master, ok := k.keys[version]
+	if !ok {
+		return 0, nil, nil, fmt.Errorf("master key %d: %w", version, ErrUnknownKeyVersion)
+	}
+
+	dataKey, err := open(master, wrappedKey, nil)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	return version, dataKey, ciphertext, nil
+}
+
+// format encodes the value as enc:v1:<master key version>:<wrapped data key>:<ciphertext>
+func format(version uint32, wrappedKey, ciphertext []byte) string {
+	return prefix + strconv.FormatUint(uint64(version), 10) + ":" +
+		base64.RawURLEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext)
+}
+
+func parse(value string) (uint32, []byte, []byte, error) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return 0, nil, nil, ErrMalformed
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return 0, nil, nil, ErrMalformed
+	}
+
+	version, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, nil, nil, ErrMalformed
+	}
+
+	wrappedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, nil, nil, ErrMalformed
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, ErrMalformed
+	}
+
+	return uint32(version), wrappedKey, ciphertext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// seal returns the random nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,219 @@
+package envelope
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func newTestKeyring(t *testing.T, active uint32, versions ...uint32) *Keyring {
+	t.Helper()
+
+	keys := make(map[uint32][]byte, len(versions))
+	for _, version := range versions {
+		keys[version] = testKey(byte(version))
+	}
+
+	k, err := NewKeyring(active, keys)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return k
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  uint32
+		keys    map[uint32][]byte
+		wantErr error
+	}{
+		{name: "valid", active: 2, keys: map[uint32][]byte{1: testKey(1), 2: testKey(2)}},
+		{name: "unknown active key", active: 3, keys: map[uint32][]byte{1: testKey(1)}, wantErr: ErrUnknownKeyVersion},
+		{name: "no keys", active: 1, keys: nil, wantErr: ErrUnknownKeyVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyring(tt.active, tt.keys); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewKeyring() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewKeyring(1, map[uint32][]byte{1: testKey(1)[:16]}); err == nil {
+		t.Error("NewKeyring() error = nil for a 16 bytes master key")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := newTestKeyring(t, 1, 1)
+	aad := []byte("users.refresh_token")
+
+	for _, plaintext := range []string{"", "refresh-token", strings.Repeat("x", 4096)} {
+		value, err := k.Encrypt([]byte(plaintext), aad)
+		if err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		if !IsEncrypted(value) {
+			t.Errorf("IsEncrypted(%q) = false", value)
+		}
+		if plaintext != "" && strings.Contains(value, plaintext) {
+			t.Errorf("Encrypt() = %q, contains the plaintext", value)
+		}
+
+		got, err := k.Decrypt(value, aad)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if string(got) != plaintext {
+			t.Errorf("Decrypt() = %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestEncryptUsesNewDataKeys(t *testing.T) {
+	k := newTestKeyring(t, 1, 1)
+
+	first, err := k.Encrypt([]byte("same"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := k.Encrypt([]byte("same"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Error("Encrypt() returned the same value twice")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	k := newTestKeyring(t, 1, 1)
+	aad := []byte("users.refresh_token")
+
+	value, err := k.Encrypt([]byte("refresh-token"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(value, ":")
+
+	otherKey, err := NewKeyring(1, map[uint32][]byte{1: testKey(0xff)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tamper changes a base64 character in the middle, so the decoded bytes change
+	tamper := func(s string) string {
+		b := []byte(s)
+		if b[len(b)/2] == 'A' {
+			b[len(b)/2] = 'B'
+		} else {
+			b[len(b)/2] = 'A'
+		}
+		return string(b)
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		aad     []byte
+		wantErr error
+	}{
+		{name: "aad mismatch", keyring: k, value: value, aad: []byte("user_totp.secret")},
+		{name: "missing aad", keyring: k, value: value},
+		{name: "wrong master key", keyring: otherKey, value: value, aad: aad},
+		{name: "unknown key version", keyring: newTestKeyring(t, 2, 2), value: value, aad: aad, wantErr: ErrUnknownKeyVersion},
+		{name: "plaintext", keyring: k, value: "refresh-token", aad: aad, wantErr: ErrMalformed},
+		{name: "missing part", keyring: k, value: strings.Join(parts[:len(parts)-1], ":"), aad: aad, wantErr: ErrMalformed},
+		{name: "invalid version", keyring: k, value: strings.Join(append([]string{parts[0], parts[1], "x"}, parts[3:]...), ":"), aad: aad, wantErr: ErrMalformed},
+		{name: "invalid base64", keyring: k, value: value + "!", aad: aad, wantErr: ErrMalformed},
+		{name: "short ciphertext", keyring: k, value: strings.Join(append(parts[:len(parts)-1], "AAAA"), ":"), aad: aad, wantErr: ErrMalformed},
+		{name: "tampered ciphertext", keyring: k, value: strings.Join(append(parts[:len(parts)-1], tamper(parts[len(parts)-1])), ":"), aad: aad},
+		{name: "tampered data key", keyring: k, value: strings.Join(append(parts[:len(parts)-2], tamper(parts[len(parts)-2]), parts[len(parts)-1]), ":"), aad: aad},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(tt.value, tt.aad)
+			if err == nil {
+				t.Fatalf("Decrypt() = %q, want an error", got)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := newTestKeyring(t, 1, 1)
+	rotated := newTestKeyring(t, 2, 1, 2)
+	aad := []byte("users.refresh_token")
+
+	value, err := old.Encrypt([]byte("refresh-token"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rotated.NeedsRewrap(value) {
+		t.Fatal("NeedsRewrap() = false for a value of an old master key")
+	}
+
+	rewrapped, err := rotated.Rewrap(value)
+	if err != nil {
+		t.Fatalf("Rewrap() error = %v", err)
+	}
+	if rotated.NeedsRewrap(rewrapped) {
+		t.Error("NeedsRewrap() = true after Rewrap")
+	}
+
+	// the ciphertext is kept, only the data key is wrapped again
+	if got, want := rewrapped[strings.LastIndex(rewrapped, ":"):], value[strings.LastIndex(value, ":"):]; got != want {
+		t.Errorf("Rewrap() changed the ciphertext")
+	}
+
+	// the old master key can be removed once every value was rewrapped
+	newOnly := newTestKeyring(t, 2, 2)
+	got, err := newOnly.Decrypt(rewrapped, aad)
+	if err != nil || string(got) != "refresh-token" {
+		t.Errorf("Decrypt() = (%q, %v), want (%q, nil)", got, err, "refresh-token")
+	}
+	if _, err := newOnly.Decrypt(value, aad); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Decrypt() of the old value error = %v, want %v", err, ErrUnknownKeyVersion)
+	}
+}
+
+func TestNeedsRewrap(t *testing.T) {
+	k := newTestKeyring(t, 1, 1)
+
+	value, err := k.Encrypt([]byte("refresh-token"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "active key", value: value},
+		{name: "plaintext", value: "refresh-token", want: true},
+		{name: "malformed", value: "enc:v1:1:x", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.NeedsRewrap(tt.value); got != tt.want {
+				t.Errorf("NeedsRewrap() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
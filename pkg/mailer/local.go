@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// File writes every email as an .eml file into a directory, so tests can read the links that were sent
+type File struct {
+	dir string
+}
+
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return &File{dir: dir}, nil
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	if err := os.WriteFile(filepath.Join(f.dir, name), msg.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write email to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
+
+// Log only logs the email, it must never be used in production since the body holds secret links
+type Log struct {
+	slog *slog.Logger
+}
+
+func NewLog(slog *slog.Logger) *Log {
+	return &Log{slog: slog}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	l.slog.Info("email sent", slog.String("to", msg.To), slog.String("subject", msg.Subject), slog.String("body", msg.Body))
+	return nil
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"time"
+)
+
+// Mailer delivers an email, the implementation is chosen by configuration
+// so local and CI environments don't need a real mail server
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	// Body is sent as plain text
+	Body string
+}
+
+// Bytes formats the message as an RFC 5322 email
+func (m Message) Bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(m.Body)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+type SMTP struct {
+	addr string
+	host string
+	auth smtp.Auth
+}
+
+// NewSMTP sends emails through the smtp server at host:port,
+// plain auth is only used when a username is given
+func NewSMTP(host, port, username, password string) *SMTP {
+	s := &SMTP{addr: net.JoinHostPort(host, port), host: host}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	// the from header may have a display name, the envelope only takes the address
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", msg.From, err)
+	}
+
+	// net/smtp doesn't take a context, so the send is abandoned instead of cancelled
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(s.addr, s.auth, from.Address, []string{msg.To}, msg.Bytes())
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to send email to %s: %w", msg.To, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedCorpus checks passwords against a local copy of the Pwned Passwords range files, so no network is needed.
+// The directory holds one file per 5 character SHA-1 prefix, e.g. 21BD1.txt, each line being the rest of the
+// hash and the breach count, e.g. 0018A45C4D1DEF81644B54AB7F969B88D65:21, which is what
+// https://github.com/HaveIBeenPwned/PwnedPasswordsDownloader writes. A missing prefix file means no breach
+type BreachedCorpus struct {
+	dir string
+}
+
+func NewBreachedCorpus(dir string) (*BreachedCorpus, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password corpus: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password corpus %s is not a directory", dir)
+	}
+
+	return &BreachedCorpus{dir: dir}, nil
+}
+
+func (b *BreachedCorpus) IsBreached(plainTextPass string) (bool, error) {
+	sum := sha1.Sum([]byte(plainTextPass))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, _, _ := strings.Cut(scanner.Text(), ":")
+		if strings.EqualFold(strings.TrimSpace(lineSuffix), suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
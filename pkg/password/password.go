@@ -1,31 +1,212 @@
 package password
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
 )
 
-func Check(plainTextPass string, passHash []byte) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(passHash, []byte(plainTextPass))
-	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return false, fmt.Errorf("mismatched hash and password: %w", err)
-		default:
-			return false, err
+type Algorithm string
+
+const (
+	Argon2id Algorithm = "argon2id"
+	Bcrypt   Algorithm = "bcrypt"
+	Scrypt   Algorithm = "scrypt"
+)
+
+var (
+	ErrMismatchedHashAndPassword = errors.New("hashed password is not the hash of the given password")
+	ErrUnknownAlgorithm          = errors.New("unknown password hash algorithm")
+)
+
+// Params are the algorithm and cost used to hash new passwords,
+// hashes made with another algorithm or a lower cost still verify but need a rehash
+type Params struct {
+	Algorithm Algorithm
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+	// ScryptLogN is the log2 of the scrypt cost parameter N
+	ScryptLogN uint8
+	ScryptR    int
+	ScryptP    int
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultParams follow the OWASP password storage recommendations
+var DefaultParams = Params{
+	Algorithm:         Argon2id,
+	Argon2Memory:      64 * 1024,
+	Argon2Iterations:  3,
+	Argon2Parallelism: 2,
+	BcryptCost:        bcrypt.DefaultCost,
+	ScryptLogN:        15,
+	ScryptR:           8,
+	ScryptP:           1,
+	SaltLength:        16,
+	KeyLength:         32,
+}
+
+type Hasher struct {
+	params Params
+}
+
+// New creates a hasher for params, zero fields are set from DefaultParams
+func New(params Params) (*Hasher, error) {
+	if params.Algorithm == "" {
+		params.Algorithm = DefaultParams.Algorithm
+	}
+	if params.Argon2Memory == 0 {
+		params.Argon2Memory = DefaultParams.Argon2Memory
+	}
+	if params.Argon2Iterations == 0 {
+		params.Argon2Iterations = DefaultParams.Argon2Iterations
+	}
+	if params.Argon2Parallelism == 0 {
+		params.Argon2Parallelism = DefaultParams.Argon2Parallelism
+	}
+	if params.BcryptCost == 0 {
+		params.BcryptCost = DefaultParams.BcryptCost
+	}
+	if params.ScryptLogN == 0 {
+		params.ScryptLogN = DefaultParams.ScryptLogN
+	}
+	if params.ScryptR == 0 {
+		params.ScryptR = DefaultParams.ScryptR
+	}
+	if params.ScryptP == 0 {
+		params.ScryptP = DefaultParams.ScryptP
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultParams.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultParams.KeyLength
+	}
+
+	switch params.Algorithm {
+	case Argon2id, Scrypt:
+	case Bcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
 		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownAlgorithm, params.Algorithm)
 	}
 
-	return true, nil
+	return &Hasher{params: params}, nil
 }
 
-func Generate(plaintTextPass string) ([]byte, error) {
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(plaintTextPass), bcrypt.DefaultCost)
+// Generate hashes the password with the configured algorithm, argon2id and scrypt hashes are in PHC string format
+// and bcrypt hashes in their own modular crypt format
+func (h *Hasher) Generate(plainTextPass string) ([]byte, error) {
+	if h.params.Algorithm == Bcrypt {
+		return bcrypt.GenerateFromPassword([]byte(plainTextPass), h.params.BcryptCost)
+	}
+
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	phc := phcHash{algorithm: h.params.Algorithm, params: h.params, salt: salt}
+	key, err := phc.derive(plainTextPass, h.params.KeyLength)
 	if err != nil {
 		return nil, err
 	}
+	phc.key = key
+
+	return []byte(phc.String()), nil
+}
 
-	return passwordHash, nil
+// NeedsRehash reports whether the hash was made with another algorithm or a lower cost than the configured one
+func (h *Hasher) NeedsRehash(passHash []byte) bool {
+	if isBcrypt(passHash) {
+		if h.params.Algorithm != Bcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost(passHash)
+		return err != nil || cost < h.params.BcryptCost
+	}
+
+	phc, err := parsePHC(string(passHash))
+	if err != nil || phc.algorithm != h.params.Algorithm {
+		return true
+	}
+
+	if len(phc.salt) < int(h.params.SaltLength) || len(phc.key) < int(h.params.KeyLength) {
+		return true
+	}
+
+	switch phc.algorithm {
+	case Argon2id:
+		return phc.params.Argon2Memory < h.params.Argon2Memory ||
+			phc.params.Argon2Iterations < h.params.Argon2Iterations ||
+			phc.params.Argon2Parallelism < h.params.Argon2Parallelism
+	case Scrypt:
+		return phc.params.ScryptLogN < h.params.ScryptLogN ||
+			phc.params.ScryptR < h.params.ScryptR ||
+			phc.params.ScryptP < h.params.ScryptP
+	}
+
+	return true
+}
+
+// Check compares the password with a hash made by any supported algorithm
+func Check(plainTextPass string, passHash []byte) (bool, error) {
+	if isBcrypt(passHash) {
+		err := bcrypt.CompareHashAndPassword(passHash, []byte(plainTextPass))
+		if err != nil {
+			switch {
+			case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+				return false, fmt.Errorf("mismatched hash and password: %w", ErrMismatchedHashAndPassword)
+			default:
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	phc, err := parsePHC(string(passHash))
+	if err != nil {
+		return false, err
+	}
+
+	key, err := phc.derive(plainTextPass, uint32(len(phc.key)))
+	if err != nil {
+		return false, err
+	}
+
+	if subtle.ConstantTimeCompare(key, phc.key) != 1 {
+		return false, fmt.Errorf("mismatched hash and password: %w", ErrMismatchedHashAndPassword)
+	}
+
+	return true, nil
+}
+
+func isBcrypt(passHash []byte) bool {
+	return len(passHash) > 3 && passHash[0] == '$' && passHash[1] == '2'
+}
+
+func (p phcHash) derive(plainTextPass string, keyLength uint32) ([]byte, error) {
+	switch p.algorithm {
+	case Argon2id:
+		return argon2.IDKey([]byte(plainTextPass), p.salt, p.params.Argon2Iterations, p.params.Argon2Memory, p.params.Argon2Parallelism, keyLength), nil
+	case Scrypt:
+		key, err := scrypt.Key([]byte(plainTextPass), p.salt, 1<<p.params.ScryptLogN, p.params.ScryptR, p.params.ScryptP, int(keyLength))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive scrypt key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownAlgorithm, p.algorithm)
+	}
 }
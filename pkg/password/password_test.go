@@ -0,0 +1,262 @@
+package password
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastParams keep the tests quick, they are far below what DefaultParams asks for
+var fastParams = Params{
+	Argon2Memory:      1024,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+	BcryptCost:        bcrypt.MinCost,
+	ScryptLogN:        4,
+	ScryptR:           8,
+	ScryptP:           1,
+}
+
+func withAlgorithm(params Params, algorithm Algorithm) Params {
+	params.Algorithm = algorithm
+	return params
+}
+
+func newTestHasher(t *testing.T, params Params) *Hasher {
+	t.Helper()
+
+	hasher, err := New(params)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return hasher
+}
+
+func TestParsePHC(t *testing.T) {
+	tests := []struct {
+		name    string
+		hash    string
+		want    phcHash
+		wantErr error
+	}{
+		{
+			name: "argon2id",
+			hash: "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ$a2V5a2V5",
+			want: phcHash{
+				algorithm: Argon2id,
+				params:    Params{Argon2Memory: 65536, Argon2Iterations: 3, Argon2Parallelism: 2},
+				salt:      []byte("saltsalt"),
+				key:       []byte("keykey"),
+			},
+		},
+		{
+			name: "scrypt",
+			hash: "$scrypt$ln=15,r=8,p=1$c2FsdHNhbHQ$a2V5a2V5",
+			want: phcHash{
+				algorithm: Scrypt,
+				params:    Params{ScryptLogN: 15, ScryptR: 8, ScryptP: 1},
+				salt:      []byte("saltsalt"),
+				key:       []byte("keykey"),
+			},
+		},
+		{name: "unknown algorithm", hash: "$pbkdf2$i=1000$c2FsdA$a2V5", wantErr: ErrUnknownAlgorithm},
+		{name: "no leading dollar", hash: "argon2id$v=19$m=65536,t=3,p=2$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "too few fields", hash: "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA", wantErr: ErrInvalidHash},
+		{name: "argon2id without version", hash: "$argon2id$m=65536,t=3,p=2$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "unknown parameter", hash: "$scrypt$ln=15,r=8,p=1,x=1$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "parameter of the other algorithm", hash: "$scrypt$ln=15,r=8,m=1$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "missing parameter", hash: "$argon2id$v=19$m=65536,t=3$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "zero parameter", hash: "$scrypt$ln=0,r=8,p=1$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "parameter without value", hash: "$scrypt$ln,r=8,p=1$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "negative parameter", hash: "$scrypt$ln=-1,r=8,p=1$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "argon2id parallelism overflow", hash: "$argon2id$v=19$m=65536,t=3,p=256$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "scrypt cost overflow", hash: "$scrypt$ln=64,r=8,p=1$c2FsdA$a2V5", wantErr: ErrInvalidHash},
+		{name: "invalid salt", hash: "$scrypt$ln=15,r=8,p=1$c2Fsd!$a2V5", wantErr: ErrInvalidHash},
+		{name: "empty key", hash: "$scrypt$ln=15,r=8,p=1$c2FsdA$", wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePHC(tt.hash)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parsePHC() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePHC() error = %v", err)
+			}
+
+			if got.algorithm != tt.want.algorithm || got.params != tt.want.params ||
+				string(got.salt) != string(tt.want.salt) || string(got.key) != string(tt.want.key) {
+				t.Errorf("parsePHC() = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.hash {
+				t.Errorf("String() = %s, want %s", got.String(), tt.hash)
+			}
+		})
+	}
+}
+
+func TestParsePHCUnsupportedArgon2Version(t *testing.T) {
+	if _, err := parsePHC("$argon2id$v=16$m=65536,t=3,p=2$c2FsdA$a2V5"); err == nil {
+		t.Error("parsePHC() error = nil, want an error")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// the scrypt test vector of RFC 7914 section 12
+	scryptKey, _ := hex.DecodeString("fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640")
+	rfcScrypt := fmt.Sprintf("$scrypt$ln=10,r=8,p=16$%s$%s",
+		base64.RawStdEncoding.EncodeToString([]byte("NaCl")), base64.RawStdEncoding.EncodeToString(scryptKey))
+
+	// made with argon2 directly, so the hash doesn't depend on phcHash.String
+	argon2Salt := []byte("somesaltsomesalt")
+	argon2Key := argon2.IDKey([]byte("password"), argon2Salt, 1, 1024, 1, 32)
+	argon2Hash := fmt.Sprintf("$argon2id$v=19$m=1024,t=1,p=1$%s$%s",
+		base64.RawStdEncoding.EncodeToString(argon2Salt), base64.RawStdEncoding.EncodeToString(argon2Key))
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		wantErr  error
+	}{
+		{name: "scrypt", password: "password", hash: rfcScrypt, want: true},
+		{name: "scrypt mismatch", password: "Password", hash: rfcScrypt, wantErr: ErrMismatchedHashAndPassword},
+		{name: "argon2id", password: "password", hash: argon2Hash, want: true},
+		{name: "argon2id mismatch", password: "passwore", hash: argon2Hash, wantErr: ErrMismatchedHashAndPassword},
+		{name: "bcrypt", password: "password", hash: string(bcryptHash), want: true},
+		{name: "bcrypt mismatch", password: "", hash: string(bcryptHash), wantErr: ErrMismatchedHashAndPassword},
+		{name: "invalid hash", password: "password", hash: "password", wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Check(tt.password, []byte(tt.hash))
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() = (%t, %v), want (%t, %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, algorithm := range []Algorithm{Argon2id, Bcrypt, Scrypt} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			hasher := newTestHasher(t, withAlgorithm(fastParams, algorithm))
+
+			hash, err := hasher.Generate("correct horse battery staple")
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+
+			if ok, err := Check("correct horse battery staple", hash); !ok || err != nil {
+				t.Errorf("Check() = (%t, %v), want (true, nil)", ok, err)
+			}
+			if ok, err := Check("correct horse battery stapler", hash); ok || !errors.Is(err, ErrMismatchedHashAndPassword) {
+				t.Errorf("Check() = (%t, %v), want (false, %v)", ok, err, ErrMismatchedHashAndPassword)
+			}
+			if hasher.NeedsRehash(hash) {
+				t.Error("NeedsRehash() = true for a hash made with the same params")
+			}
+
+			other, err := hasher.Generate("correct horse battery staple")
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if string(other) == string(hash) {
+				t.Error("Generate() made the same hash twice, the salt isn't random")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hashWith := func(params Params) []byte {
+		hash, err := newTestHasher(t, params).Generate("password")
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		return hash
+	}
+
+	argon2Hash := hashWith(withAlgorithm(fastParams, Argon2id))
+	scryptHash := hashWith(withAlgorithm(fastParams, Scrypt))
+	bcryptHash := hashWith(withAlgorithm(fastParams, Bcrypt))
+
+	stronger := func(algorithm Algorithm, change func(p *Params)) Params {
+		params := withAlgorithm(fastParams, algorithm)
+		change(&params)
+		return params
+	}
+
+	tests := []struct {
+		name   string
+		params Params
+		hash   []byte
+		want   bool
+	}{
+		{name: "argon2id up to date", params: withAlgorithm(fastParams, Argon2id), hash: argon2Hash},
+		{name: "argon2id stronger than configured", params: withAlgorithm(fastParams, Argon2id), hash: hashWith(stronger(Argon2id, func(p *Params) { p.Argon2Memory *= 2 }))},
+		{name: "argon2id more memory", params: stronger(Argon2id, func(p *Params) { p.Argon2Memory *= 2 }), hash: argon2Hash, want: true},
+		{name: "argon2id more iterations", params: stronger(Argon2id, func(p *Params) { p.Argon2Iterations++ }), hash: argon2Hash, want: true},
+		{name: "argon2id more parallelism", params: stronger(Argon2id, func(p *Params) { p.Argon2Parallelism++ }), hash: argon2Hash, want: true},
+		{name: "argon2id longer salt", params: stronger(Argon2id, func(p *Params) { p.SaltLength = 32 }), hash: argon2Hash, want: true},
+		{name: "argon2id longer key", params: stronger(Argon2id, func(p *Params) { p.KeyLength = 64 }), hash: argon2Hash, want: true},
+		{name: "scrypt up to date", params: withAlgorithm(fastParams, Scrypt), hash: scryptHash},
+		{name: "scrypt higher cost", params: stronger(Scrypt, func(p *Params) { p.ScryptLogN++ }), hash: scryptHash, want: true},
+		{name: "bcrypt up to date", params: withAlgorithm(fastParams, Bcrypt), hash: bcryptHash},
+		{name: "bcrypt higher cost", params: stronger(Bcrypt, func(p *Params) { p.BcryptCost++ }), hash: bcryptHash, want: true},
+		{name: "bcrypt to argon2id", params: withAlgorithm(fastParams, Argon2id), hash: bcryptHash, want: true},
+		{name: "scrypt to argon2id", params: withAlgorithm(fastParams, Argon2id), hash: scryptHash, want: true},
+		{name: "argon2id to bcrypt", params: withAlgorithm(fastParams, Bcrypt), hash: argon2Hash, want: true},
+		{name: "invalid hash", params: withAlgorithm(fastParams, Argon2id), hash: []byte("password"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestHasher(t, tt.params).NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Params
+		want    Algorithm
+		wantErr bool
+	}{
+		{name: "defaults", params: Params{}, want: Argon2id},
+		{name: "bcrypt", params: Params{Algorithm: Bcrypt}, want: Bcrypt},
+		{name: "unknown algorithm", params: Params{Algorithm: "md5"}, wantErr: true},
+		{name: "bcrypt cost too high", params: Params{Algorithm: Bcrypt, BcryptCost: bcrypt.MaxCost + 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := New(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && hasher.params.Algorithm != tt.want {
+				t.Errorf("New() algorithm = %s, want %s", hasher.params.Algorithm, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash format")
+
+// phcHash is a hash in the PHC string format, $<id>[$v=<version>]$<param>=<value>(,...)$<salt>$<hash>
+// https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md
+type phcHash struct {
+	algorithm Algorithm
+	params    Params
+	salt      []byte
+	key       []byte
+}
+
+func (p phcHash) String() string {
+	salt := base64.RawStdEncoding.EncodeToString(p.salt)
+	key := base64.RawStdEncoding.EncodeToString(p.key)
+
+	switch p.algorithm {
+	case Argon2id:
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.params.Argon2Memory, p.params.Argon2Iterations, p.params.Argon2Parallelism, salt, key)
+	case Scrypt:
+		return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+			p.params.ScryptLogN, p.params.ScryptR, p.params.ScryptP, salt, key)
+	default:
+		return ""
+	}
+}
+
+func parsePHC(hash string) (phcHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return phcHash{}, ErrInvalidHash
+	}
+
+	p := phcHash{algorithm: Algorithm(parts[1])}
+	fields := parts[2:]
+
+	switch p.algorithm {
+	case Argon2id:
+		if len(fields) != 4 {
+			return phcHash{}, ErrInvalidHash
+		}
+		if fields[0] != "v="+strconv.Itoa(argon2.Version) {
+			return phcHash{}, fmt.Errorf("unsupported argon2 version %q", fields[0])
+		}
+		fields = fields[1:]
+	case Scrypt:
+		if len(fields) != 3 {
+			return phcHash{}, ErrInvalidHash
+		}
+	default:
+		return phcHash{}, fmt.Errorf("%w %q", ErrUnknownAlgorithm, p.algorithm)
+	}
+
+	for _, param := range strings.Split(fields[0], ",") {
+		name, valueString, ok := strings.Cut(param, "=")
+		if !ok {
+			return phcHash{}, ErrInvalidHash
+		}
+
+		value, err := strconv.ParseUint(valueString, 10, 32)
+		if err != nil {
+			return phcHash{}, ErrInvalidHash
+		}
+
+		switch string(p.algorithm) + ":" + name {
+		case "argon2id:m":
+			p.params.Argon2Memory = uint32(value)
+		case "argon2id:t":
+			p.params.Argon2Iterations = uint32(value)
+		case "argon2id:p":
+			if value > 255 {
+				return phcHash{}, ErrInvalidHash
+			}
+			p.params.Argon2Parallelism = uint8(value)
+		case "scrypt:ln":
+			if value > 63 {
+				return phcHash{}, ErrInvalidHash
+			}
+			p.params.ScryptLogN = uint8(value)
+		case "scrypt:r":
+			p.params.ScryptR = int(value)
+		case "scrypt:p":
+			p.params.ScryptP = int(value)
+		default:
+			return phcHash{}, ErrInvalidHash
+		}
+	}
+
+	// argon2 panics on a zero cost, so a hash missing a parameter is rejected here
+	switch p.algorithm {
+	case Argon2id:
+		if p.params.Argon2Memory == 0 || p.params.Argon2Iterations == 0 || p.params.Argon2Parallelism == 0 {
+			return phcHash{}, ErrInvalidHash
+		}
+	case Scrypt:
+		if p.params.ScryptLogN == 0 || p.params.ScryptR == 0 || p.params.ScryptP == 0 {
+			return phcHash{}, ErrInvalidHash
+		}
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(fields[1]); err != nil {
+		return phcHash{}, ErrInvalidHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(fields[2]); err != nil || len(p.key) == 0 {
+		return phcHash{}, ErrInvalidHash
+	}
+
+	return p, nil
+}
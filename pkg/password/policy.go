@@ -0,0 +1,101 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// policy rules, a PolicyViolation tells which one failed
+const (
+	RuleMinLength = "min_length"
+	RuleStrength  = "strength"
+	RuleSimilar   = "similar"
+	RuleBreached  = "breached"
+)
+
+// BreachedChecker reports whether a password appeared in a known data breach
+type BreachedChecker interface {
+	IsBreached(plainTextPass string) (bool, error)
+}
+
+// Policy is what a new password must satisfy, existing passwords are never checked against it
+type Policy struct {
+	MinLength int
+	// MinScore is the minimum Strength score, from 0 (guessable) to 4 (very unguessable)
+	MinScore int
+	// Breached is optional, no breach check is done without it
+	Breached BreachedChecker
+}
+
+// DefaultPolicy follows the NIST SP 800-63B recommendation of a minimum length and a blocklist over composition rules
+var DefaultPolicy = Policy{MinLength: 8, MinScore: 3}
+
+type PolicyViolation struct {
+	Rule    string
+	Message string
+}
+
+func (v *PolicyViolation) Error() string {
+	return v.Message
+}
+
+// Check returns a PolicyViolation for the first rule the password fails, userInputs are values the password
+// must not be similar to, e.g. the username and email
+func (p *Policy) Check(plainTextPass string, userInputs ...string) error {
+	if utf8.RuneCountInString(plainTextPass) < p.MinLength {
+		return &PolicyViolation{Rule: RuleMinLength, Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength)}
+	}
+
+	if err := CheckSimilar(plainTextPass, userInputs...); err != nil {
+		return err
+	}
+
+	if Strength(plainTextPass, userInputs...) < p.MinScore {
+		return &PolicyViolation{Rule: RuleStrength, Message: "Password is too easy to guess, add more words or uncommon characters"}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.IsBreached(plainTextPass)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			return &PolicyViolation{Rule: RuleBreached, Message: "Password has appeared in a data breach, choose another one"}
+		}
+	}
+
+	return nil
+}
+
+// CheckSimilar returns a PolicyViolation when the password is similar to one of the user inputs,
+// it's run on its own where the stored username and email are only known after the request was validated
+func CheckSimilar(plainTextPass string, userInputs ...string) error {
+	if isSimilar(plainTextPass, userInputs) {
+		return &PolicyViolation{Rule: RuleSimilar, Message: "Password must not be similar to your username or email"}
+	}
+
+	return nil
+}
+
+// isSimilar reports whether the password contains a user input, or the local part of an email, or the other way around
+func isSimilar(plainTextPass string, userInputs []string) bool {
+	lowerPass := strings.ToLower(plainTextPass)
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			input = local
+		}
+
+		// short inputs like "al" would match too many passwords
+		if utf8.RuneCountInString(input) < 3 {
+			continue
+		}
+
+		if strings.Contains(lowerPass, input) || strings.Contains(input, lowerPass) {
+			return true
+		}
+	}
+
+	return false
+}
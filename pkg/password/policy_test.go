@@ -0,0 +1,95 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeBreached struct {
+	breached map[string]bool
+	err      error
+}
+
+func (f fakeBreached) IsBreached(plainTextPass string) (bool, error) {
+	return f.breached[plainTextPass], f.err
+}
+
+func TestPolicyCheck(t *testing.T) {
+	breached := fakeBreached{breached: map[string]bool{"Tr0ub4dor&3": true}}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		password   string
+		userInputs []string
+		wantRule   string
+	}{
+		{name: "valid", policy: DefaultPolicy, password: "x7#Kq9!mZ2$w"},
+		{name: "too short", policy: DefaultPolicy, password: "x7#Kq9!", wantRule: RuleMinLength},
+		{name: "length counts runes", policy: Policy{MinLength: 4}, password: "日本語", wantRule: RuleMinLength},
+		{name: "contains the username", policy: DefaultPolicy, password: "alice-x7#Kq9!mZ2", userInputs: []string{"alice"}, wantRule: RuleSimilar},
+		{name: "contains the email local part", policy: DefaultPolicy, password: "alice-x7#Kq9!mZ2", userInputs: []string{"Alice@example.com"}, wantRule: RuleSimilar},
+		{name: "short input is ignored", policy: DefaultPolicy, password: "al-x7#Kq9!mZ2$w", userInputs: []string{"al"}},
+		{name: "too easy to guess", policy: DefaultPolicy, password: "password123", wantRule: RuleStrength},
+		{name: "zero score accepts anything long enough", policy: Policy{MinLength: 8}, password: "password123"},
+		{name: "breached", policy: Policy{MinLength: 8, MinScore: 3, Breached: breached}, password: "Tr0ub4dor&3", wantRule: RuleBreached},
+		{name: "not breached", policy: Policy{MinLength: 8, MinScore: 3, Breached: breached}, password: "x7#Kq9!mZ2$w"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, tt.userInputs...)
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var violation *PolicyViolation
+			if !errors.As(err, &violation) || violation.Rule != tt.wantRule {
+				t.Errorf("Check() error = %v, want a %s violation", err, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckBreachedError(t *testing.T) {
+	checkErr := errors.New("corpus unavailable")
+	policy := Policy{MinLength: 8, Breached: fakeBreached{err: checkErr}}
+
+	err := policy.Check("x7#Kq9!mZ2$w")
+	if !errors.Is(err, checkErr) {
+		t.Errorf("Check() error = %v, want %v", err, checkErr)
+	}
+	if errors.As(err, new(*PolicyViolation)) {
+		t.Error("Check() returned a violation for a failed breach check")
+	}
+}
+
+func TestBreachedCorpus(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	corpus := "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(corpus), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	breached, err := NewBreachedCorpus(dir)
+	if err != nil {
+		t.Fatalf("NewBreachedCorpus() error = %v", err)
+	}
+
+	for password, want := range map[string]bool{"password": true, "x7#Kq9!mZ2$w": false} {
+		got, err := breached.IsBreached(password)
+		if err != nil || got != want {
+			t.Errorf("IsBreached(%q) = (%t, %v), want (%t, nil)", password, got, err, want)
+		}
+	}
+
+	if _, err := NewBreachedCorpus(filepath.Join(dir, "5BAA6.txt")); err == nil {
+		t.Error("NewBreachedCorpus() error = nil for a file")
+	}
+}
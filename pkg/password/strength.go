@@ -0,0 +1,183 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonWords are some of the most used passwords and password words, matched after undoing leet substitutions
+var commonWords = []string{
+	"password", "qwerty", "dragon", "letmein", "monkey", "football", "baseball", "iloveyou", "admin", "welcome",
+	"login", "master", "sunshine", "princess", "shadow", "superman", "batman", "trustno", "starwars", "whatever",
+	"freedom", "secret", "michael", "jennifer", "jordan", "hunter", "ranger", "buster", "soccer", "hockey",
+	"killer", "george", "charlie", "andrew", "thomas", "summer", "winter", "spring", "autumn", "flower",
+	"cheese", "computer", "internet", "samsung", "google", "apple", "orange", "banana", "chocolate", "purple",
+	"love", "hello", "pass", "test", "user", "guest", "root", "access", "abc", "changeme",
+	"skeleton", "qazwsx", "zaq", "asdf", "zxcv", "mustang", "harley", "maggie", "ginger", "pepper",
+	"matrix", "tigger", "robert", "daniel", "jessica", "ashley", "nicole", "hannah", "angel", "lovely",
+}
+
+// keyboardRows are used to find runs of adjacent keys like "qwer" or "asdf"
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+var leetSubstitutions = map[rune]rune{'@': 'a', '4': 'a', '3': 'e', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't'}
+
+// Strength scores how hard the password is to guess, from 0 to 4 like zxcvbn.
+// The password is split into patterns (common words, user inputs, repeats, sequences and keyboard runs)
+// that are much cheaper to guess than random characters, and the guesses of every part are multiplied
+func Strength(plainTextPass string, userInputs ...string) int {
+	guesses := estimateGuesses(plainTextPass, userInputs)
+
+	switch log := math.Log10(guesses); {
+	case log < 3:
+		return 0
+	case log < 6:
+		return 1
+	case log < 8:
+		return 2
+	case log < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func estimateGuesses(plainTextPass string, userInputs []string) float64 {
+	// lower and normalized are mapped rune by rune, so their indexes line up with runes
+	runes := []rune(plainTextPass)
+	lower := make([]rune, len(runes))
+	normalized := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		normalized[i] = lower[i]
+		if sub, ok := leetSubstitutions[lower[i]]; ok {
+			normalized[i] = sub
+		}
+	}
+
+	// the words are compared rune by rune in place, so matching doesn't allocate at every position
+	words := make([][]rune, 0, len(commonWords)+len(userInputs))
+	for _, word := range commonWords {
+		words = append(words, []rune(word))
+	}
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			input = local
+		}
+		if len(input) >= 3 {
+			words = append(words, []rune(input))
+		}
+	}
+
+	guesses := 1.0
+	for i := 0; i < len(runes); {
+		length, patternGuesses := matchPattern(runes, lower, normalized, i, words)
+		guesses *= patternGuesses
+		i += length
+	}
+
+	return guesses
+}
+
+// matchPattern returns the length of the cheapest pattern starting at i and how many guesses it takes
+func matchPattern(runes, lower, normalized []rune, i int, words [][]rune) (int, float64) {
+	for rank, word := range words {
+		if hasRunePrefix(normalized[i:], word) {
+			guesses := float64(rank + 1)
+			// uppercase letters and leet substitutions only double the guesses, they are the first variations tried
+			if !hasRunePrefix(runes[i:], word) {
+				guesses *= 2
+			}
+			return len(word), max(guesses, 10)
+		}
+	}
+
+	if length := repeatLength(runes, i); length >= 3 {
+		return length, charsetSize(runes[i]) * float64(length)
+	}
+
+	if length := sequenceLength(runes, i); length >= 3 {
+		return length, charsetSize(runes[i]) * float64(length)
+	}
+
+	if length := keyboardRunLength(lower, i); length >= 3 {
+		return length, 40 * float64(length)
+	}
+
+	return 1, charsetSize(runes[i])
+}
+
+func hasRunePrefix(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func repeatLength(runes []rune, i int) int {
+	length := 1
+	for i+length < len(runes) && runes[i+length] == runes[i] {
+		length++
+	}
+	return length
+}
+
+// sequenceLength matches runs like "abcd", "4321" or "ACE" with a constant step of at most 2
+func sequenceLength(runes []rune, i int) int {
+	if i+1 >= len(runes) {
+		return 1
+	}
+
+	step := runes[i+1] - runes[i]
+	if step == 0 || step > 2 || step < -2 {
+		return 1
+	}
+
+	length := 2
+	for i+length < len(runes) && runes[i+length]-runes[i+length-1] == step {
+		length++
+	}
+	return length
+}
+
+func keyboardRunLength(lower []rune, i int) int {
+	length := 1
+	for i+length < len(lower) && adjacentKeys(lower[i+length-1], lower[i+length]) {
+		length++
+	}
+	return length
+}
+
+func adjacentKeys(a, b rune) bool {
+	for _, row := range keyboardRows {
+		ia, ib := strings.IndexRune(row, a), strings.IndexRune(row, b)
+		if ia >= 0 && ib >= 0 && (ia-ib == 1 || ib-ia == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+func charsetSize(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r):
+		return 26
+	case unicode.IsUpper(r):
+		return 26
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		// any other unicode letter or symbol
+		return 100
+	}
+}
@@ -0,0 +1,59 @@
+package password
+
+import "testing"
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		password   string
+		userInputs []string
+		want       int
+	}{
+		{password: "", want: 0},
+		{password: "password", want: 0},
+		{password: "P@ssw0rd", want: 0},
+		{password: "aaaaaaaa", want: 0},
+		{password: "12345678", want: 0},
+		{password: "qwertyuiop", want: 1},
+		{password: "zxcvbnm", want: 1},
+		{password: "skeleton123", want: 1},
+		{password: "alice2024", want: 3},
+		{password: "alice2024", userInputs: []string{"alice"}, want: 1},
+		{password: "alice2024", userInputs: []string{"alice@example.com"}, want: 1},
+		{password: "Tr0ub4dor&3", want: 4},
+		{password: "x7#Kq9!mZ2$w", want: 4},
+		{password: "correcthorsebatterystaple", want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := Strength(tt.password, tt.userInputs...); got != tt.want {
+				t.Errorf("Strength() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// the patterns must be cheaper to guess than the same number of random characters
+func TestStrengthPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		random  string
+	}{
+		{name: "common word", pattern: "sunshine", random: "qmzhvekc"},
+		{name: "leet common word", pattern: "$un$h1ne", random: "qmzhvekc"},
+		{name: "repeat", pattern: "zzzzzzzz", random: "qmzhvekc"},
+		{name: "sequence", pattern: "lmnopqrs", random: "qmzhvekc"},
+		{name: "reversed sequence", pattern: "98765432", random: "30618592"},
+		{name: "keyboard run", pattern: "asdfghjk", random: "qmzhvekc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, random := estimateGuesses(tt.pattern, nil), estimateGuesses(tt.random, nil)
+			if pattern >= random {
+				t.Errorf("estimateGuesses(%q) = %g, want less than estimateGuesses(%q) = %g", tt.pattern, pattern, tt.random, random)
+			}
+		})
+	}
+}
@@ -1,34 +1,117 @@
 package token
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/izzanzahrial/skeleton/internal/model"
 )
 
+// AccessTokenTTL is kept short since access tokens can be renewed using a refresh token
+const AccessTokenTTL = 15 * time.Minute
+
+// ImpersonationTokenTTL is how long an admin can act as another user, impersonation tokens can't be refreshed
+const ImpersonationTokenTTL = 10 * time.Minute
+
+// OAuthTokenTTL is how long the tokens issued to oauth clients last, the clients don't get a refresh token
+const OAuthTokenTTL = time.Hour
+
+func init() {
+	// the issue time is compared with the millisecond the tokens of a user were revoked at,
+	// whole seconds would reject a token issued in the same second as the revocation, e.g. the login after a password reset
+	jwt.TimePrecision = time.Millisecond
+}
+
 type JwtCustomClaims struct {
 	UserID int64       `json:"user_id"`
 	Role   model.Roles `json:"role"`
+	// SessionID is the refresh token family the token was issued for, logging out the session revokes the token
+	SessionID string `json:"sid,omitempty"`
+	// Actor is set on impersonation tokens, it's the admin acting as UserID
+	Actor *Actor `json:"act,omitempty"`
+	// ClientID is set on tokens issued to an oauth client, they can only be used for their space separated Scope
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IsAccessToken tells the access tokens apart from the id tokens signed by the same keys, an id token
+// is meant for its audience and carries neither a token id nor a user id
+func (c *JwtCustomClaims) IsAccessToken() bool {
+	return len(c.Audience) == 0 && c.ID != "" && (c.UserID != 0 || c.ClientID != "")
+}
+
+// IDTokenClaims are the claims of an openid connect id token, it tells the client who the user is
+type IDTokenClaims struct {
+	Nonce string `json:"nonce,omitempty"`
+	model.UserClaims
 	jwt.RegisteredClaims
 }
 
-func NewJWT(userID int64, role model.Roles) (string, error) {
-	expiry := time.Now().Add(time.Hour * 24)
+// Actor is the act claim of RFC 8693, identifying who is acting on behalf of the subject
+type Actor struct {
+	UserID int64 `json:"user_id"`
+}
+
+func (m *KeyManager) NewJWT(userID int64, role model.Roles, sessionID string) (string, error) {
+	t, _, err := m.newJWT(&JwtCustomClaims{UserID: userID, Role: role, SessionID: sessionID}, AccessTokenTTL)
+	return t, err
+}
+
+// NewImpersonationJWT issues a token letting the actor act as the user, it returns the token id for the audit log
+func (m *KeyManager) NewImpersonationJWT(userID int64, role model.Roles, actorID int64) (string, string, error) {
+	return m.newJWT(&JwtCustomClaims{UserID: userID, Role: role, Actor: &Actor{UserID: actorID}}, ImpersonationTokenTTL)
+}
+
+// NewOAuthJWT issues the access token of an oauth client, subject is the user id or the client id
+// when the client acts on its own behalf, it returns the token id
+func (m *KeyManager) NewOAuthJWT(issuer, subject string, userID int64, role model.Roles, clientID string, scopes []string) (string, string, error) {
+	claims := &JwtCustomClaims{UserID: userID, Role: role, ClientID: clientID, Scope: strings.Join(scopes, " ")}
+	claims.Issuer = issuer
+	claims.Subject = subject
+
+	return m.newJWT(claims, OAuthTokenTTL)
+}
 
-	claims := &JwtCustomClaims{
-		userID,
-		role,
-		jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expiry)},
+// NewIDToken issues the id token of the user to the client, the id token is only meant for the client
+func (m *KeyManager) NewIDToken(issuer, clientID string, userID int64, nonce string, claims model.UserClaims) (string, error) {
+	now := time.Now()
+	idToken := &IDTokenClaims{
+		Nonce:      nonce,
+		UserClaims: claims,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OAuthTokenTTL)),
+		},
+	}
+
+	return m.Sign(idToken)
+}
+
+// newJWT stamps the id and the lifetime of the token, the other registered claims are kept
+func (m *KeyManager) newJWT(claims *JwtCustomClaims, ttl time.Duration) (string, string, error) {
+	now := time.Now()
+	expiry := now.Add(ttl)
+
+	// jti identifies the token so it can be denied before it expires
+	jti, err := randomString()
+	if err != nil {
+		return "", "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	claims.ID = jti
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(expiry)
 
-	// TODO: create secret keys for jwt
-	t, err := token.SignedString([]byte("secret"))
+	t, err := m.Sign(claims)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return t, nil
+	return t, jti, nil
 }
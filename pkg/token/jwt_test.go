@@ -0,0 +1,97 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/izzanzahrial/skeleton/internal/model"
+)
+
+func TestIsAccessToken(t *testing.T) {
+	edKey, _ := newEd25519Key(t, "ed")
+	m, err := NewKeyManager("ed", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(tkn string) *JwtCustomClaims {
+		claims := new(JwtCustomClaims)
+		if _, err := jwt.ParseWithClaims(tkn, claims, m.Keyfunc); err != nil {
+			t.Fatalf("ParseWithClaims() error = %v", err)
+		}
+		return claims
+	}
+
+	accessToken, err := m.NewJWT(1, model.RolesUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonationToken, _, err := m.NewImpersonationJWT(1, model.RolesUser, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClientToken, _, err := m.NewOAuthJWT("https://example.com", "1", 1, model.RolesUser, "client", []string{"profile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientToken, _, err := m.NewOAuthJWT("https://example.com", "client", 0, "", "client", []string{"posts:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, err := m.NewIDToken("https://example.com", "client", 1, "nonce", model.UserClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noTokenID, err := m.Sign(&JwtCustomClaims{UserID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "access token", token: accessToken, want: true},
+		{name: "impersonation token", token: impersonationToken, want: true},
+		{name: "oauth token of a user", token: userClientToken, want: true},
+		{name: "oauth token of a client", token: clientToken, want: true},
+		{name: "id token", token: idToken},
+		{name: "without token id", token: noTokenID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parse(tt.token).IsAccessToken(); got != tt.want {
+				t.Errorf("IsAccessToken() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssuedAtAfterRevocation(t *testing.T) {
+	edKey, _ := newEd25519Key(t, "ed")
+	m, err := NewKeyManager("ed", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tokens of the user were revoked a few milliseconds before the token is issued, likely in the same second,
+	// the revocation is stored in milliseconds
+	revokedBefore := time.UnixMilli(time.Now().Add(-5 * time.Millisecond).UnixMilli())
+
+	tkn, err := m.NewJWT(1, model.RolesUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := new(JwtCustomClaims)
+	if _, err := jwt.ParseWithClaims(tkn, claims, m.Keyfunc); err != nil {
+		t.Fatalf("ParseWithClaims() error = %v", err)
+	}
+
+	if !claims.IssuedAt.After(revokedBefore) {
+		t.Errorf("IssuedAt = %v, want after the revocation at %v", claims.IssuedAt.Time, revokedBefore)
+	}
+}
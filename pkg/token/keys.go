@@ -0,0 +1,189 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a single asymmetric key used to sign or verify JWTs,
+// retired keys only have the public part so they can still verify tokens issued before a rotation
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeyManager signs JWTs with the active key and verifies them against every known key
+type KeyManager struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeyManager(activeID string, keys ...*Key) (*KeyManager, error) {
+	m := &KeyManager{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		m.keys[k.ID] = k
+	}
+
+	active, ok := m.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	m.active = active
+
+	return m, nil
+}
+
+// LoadKeys reads every PEM file in dir, the file name without extension is used as the key ID.
+// Supported keys are RSA (RS256) and Ed25519 (EdDSA) in PKCS#1, PKCS#8 or PKIX form
+func LoadKeys(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	var keys []*Key
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := ParseKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", path, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// Sign signs the claims with the active key and stamps its ID in the kid header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.active.Method, claims)
+	token.Header["kid"] = m.active.ID
+
+	return token.SignedString(m.active.Private)
+}
+
+// Algorithm is the signing algorithm of the active key
+func (m *KeyManager) Algorithm() string {
+	return m.active.Method.Alg()
+}
+
+// Keyfunc looks up the verification key using the kid header,
+// the token algorithm must match the key so an attacker can't downgrade it
+func (m *KeyManager) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+
+	return key.Public, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public part of every known key, sorted by key ID
+func (m *KeyManager) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(m.keys))}
+	for _, key := range m.keys {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Method.Alg(), Use: "sig"}
+
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID })
+	return jwks
+}
@@ -0,0 +1,237 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newRSAKey(t *testing.T, id string) (*Key, *rsa.PrivateKey) {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, private
+}
+
+func newEd25519Key(t *testing.T, id string) (*Key, ed25519.PrivateKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, private
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+// signWith signs the claims with any method and kid, like an attacker would
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+
+	tkn := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		tkn.Header["kid"] = kid
+	}
+	signed, err := tkn.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestNewKeyManager(t *testing.T) {
+	active, _ := newEd25519Key(t, "2024-01")
+	retired := &Key{ID: "2023-01", Method: active.Method, Public: active.Public}
+
+	if _, err := NewKeyManager("2024-01", active, retired); err != nil {
+		t.Errorf("NewKeyManager() error = %v", err)
+	}
+	if _, err := NewKeyManager("2025-01", active, retired); err == nil {
+		t.Error("NewKeyManager() error = nil for an unknown active key")
+	}
+	if _, err := NewKeyManager("2023-01", active, retired); err == nil {
+		t.Error("NewKeyManager() error = nil for an active key without a private key")
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	rsaKey, rsaPrivate := newRSAKey(t, "rsa")
+	edKey, edPrivate := newEd25519Key(t, "ed")
+	_, unknownPrivate := newEd25519Key(t, "unknown")
+	// only the public part of a retired key is kept
+	retiredKey, retiredPrivate := newEd25519Key(t, "retired")
+	retiredKey.Private = nil
+
+	m, err := NewKeyManager("ed", rsaKey, edKey, retiredKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := m.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	rsaPublicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: mustMarshalPKIX(t, &rsaPrivate.PublicKey)})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "active key", token: active},
+		{name: "other known key", token: signWith(t, jwt.SigningMethodRS256, "rsa", rsaPrivate)},
+		{name: "retired key", token: signWith(t, jwt.SigningMethodEdDSA, "retired", retiredPrivate)},
+		{name: "missing kid", token: signWith(t, jwt.SigningMethodEdDSA, "", edPrivate), wantErr: true},
+		{name: "unknown kid", token: signWith(t, jwt.SigningMethodEdDSA, "unknown", unknownPrivate), wantErr: true},
+		{name: "kid of another key", token: signWith(t, jwt.SigningMethodEdDSA, "ed", unknownPrivate), wantErr: true},
+		{name: "alg of another key", token: signWith(t, jwt.SigningMethodEdDSA, "rsa", edPrivate), wantErr: true},
+		{name: "hmac with the public key as secret", token: signWith(t, jwt.SigningMethodHS256, "rsa", rsaPublicPEM), wantErr: true},
+		{name: "none alg", token: signWith(t, jwt.SigningMethodNone, "ed", jwt.UnsafeAllowNoneSignatureType), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.ParseWithClaims(tt.token, new(jwt.RegisteredClaims), m.Keyfunc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWithClaims() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyfuncUnknownKey(t *testing.T) {
+	edKey, _ := newEd25519Key(t, "ed")
+	_, unknownPrivate := newEd25519Key(t, "unknown")
+
+	m, err := NewKeyManager("ed", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = jwt.Parse(signWith(t, jwt.SigningMethodEdDSA, "unknown", unknownPrivate), m.Keyfunc)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Parse() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func mustMarshalPKIX(t *testing.T, key any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestParseKey(t *testing.T) {
+	_, rsaPrivate := newRSAKey(t, "rsa")
+	_, edPrivate := newEd25519Key(t, "ed")
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		block       *pem.Block
+		wantAlg     string
+		wantPrivate bool
+		wantErr     bool
+	}{
+		{name: "pkcs1 rsa private key", block: &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)}, wantAlg: "RS256", wantPrivate: true},
+		{name: "pkcs8 ed25519 private key", block: &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}, wantAlg: "EdDSA", wantPrivate: true},
+		{name: "rsa public key", block: &pem.Block{Type: "PUBLIC KEY", Bytes: mustMarshalPKIX(t, &rsaPrivate.PublicKey)}, wantAlg: "RS256"},
+		{name: "ed25519 public key", block: &pem.Block{Type: "PUBLIC KEY", Bytes: mustMarshalPKIX(t, edPrivate.Public())}, wantAlg: "EdDSA"},
+		{name: "unsupported block", block: &pem.Block{Type: "CERTIFICATE", Bytes: []byte{0}}, wantErr: true},
+		{name: "invalid der", block: &pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseKey("id", pem.EncodeToMemory(tt.block))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if key.Method.Alg() != tt.wantAlg || (key.Private != nil) != tt.wantPrivate || key.Public == nil {
+				t.Errorf("ParseKey() = %+v, want alg %s and private key %t", key, tt.wantAlg, tt.wantPrivate)
+			}
+		})
+	}
+
+	if _, err := ParseKey("id", []byte("not pem")); err == nil {
+		t.Error("ParseKey() error = nil without a PEM block")
+	}
+}
+
+func TestLoadKeysAndJWKS(t *testing.T) {
+	_, rsaPrivate := newRSAKey(t, "rsa")
+	_, edPrivate := newEd25519Key(t, "ed")
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]*pem.Block{
+		"2024-01.pem": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)},
+		"2024-06.pem": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	}
+	for name, block := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files without the pem extension are ignored
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("keys"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeys(dir)
+	if err != nil {
+		t.Fatalf("LoadKeys() error = %v", err)
+	}
+
+	m, err := NewKeyManager("2024-06", keys...)
+	if err != nil {
+		t.Fatalf("NewKeyManager() error = %v", err)
+	}
+	if m.Algorithm() != "EdDSA" {
+		t.Errorf("Algorithm() = %s, want EdDSA", m.Algorithm())
+	}
+
+	jwks := m.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(jwks.Keys))
+	}
+
+	rsaJWK, edJWK := jwks.Keys[0], jwks.Keys[1]
+	if rsaJWK.KeyID != "2024-01" || rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != "RS256" || rsaJWK.N == "" || rsaJWK.E != "AQAB" {
+		t.Errorf("JWKS() rsa key = %+v", rsaJWK)
+	}
+	if edJWK.KeyID != "2024-06" || edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.X == "" || edJWK.N != "" {
+		t.Errorf("JWKS() ed25519 key = %+v", edJWK)
+	}
+}
@@ -16,6 +16,12 @@ type Token struct {
 	PlainText string
 	Hash      []byte
 	Expiry    time.Duration
+	// Family groups refresh tokens that were rotated from the same login,
+	// it is empty for any other kind of token
+	Family string
+	// HasSession is set on the refresh tokens read from the cache whose family has a session,
+	// it's false for the tokens stored before sessions were recorded
+	HasSession bool
 }
 
 func New(userID int64, ttl time.Duration) (*Token, error) {
@@ -24,15 +30,68 @@ func New(userID int64, ttl time.Duration) (*Token, error) {
 		Expiry: ttl,
 	}
 
-	randomBytes := make([]byte, 16)
-	_, err := rand.Read(randomBytes)
+	plainText, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	token.PlainText = plainText
+	token.Hash = Hash(token.PlainText)
+
+	return token, nil
+}
+
+// RefreshTokenTTL is how long a refresh token stays valid when it's not used
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// NewRefresh creates an opaque refresh token that belongs to the given family,
+// a new family is started when family is empty
+func NewRefresh(userID int64, family string, ttl time.Duration) (*Token, error) {
+	token, err := New(userID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if family == "" {
+		family, err = randomString()
+		if err != nil {
+			return nil, err
+		}
+	}
+	token.Family = family
+
+	return token, nil
+}
+
+// APIKeyPrefix starts every api key, so api keys can be told apart from jwt and found by secret scanners
+const APIKeyPrefix = "sk_"
+
+// NewAPIKey creates a long lived opaque token that a machine client sends as a bearer token
+func NewAPIKey(userID int64, ttl time.Duration) (*Token, error) {
+	token, err := New(userID, ttl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate random bytes: %v", err)
+		return nil, err
 	}
 
-	token.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
-	hash := sha256.Sum256([]byte(token.PlainText))
-	token.Hash = hash[:]
+	token.PlainText = APIKeyPrefix + token.PlainText
+	token.Hash = Hash(token.PlainText)
 
 	return token, nil
 }
+
+// Hash returns the SHA-256 hash of the plain text token,
+// only the hash is stored so a leaked store doesn't leak usable tokens
+func Hash(plainText string) []byte {
+	hash := sha256.Sum256([]byte(plainText))
+	return hash[:]
+}
+
+func randomString() (string, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %v", err)
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
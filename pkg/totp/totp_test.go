@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the sha1 seed of the RFC 6238 appendix B, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// the RFC vectors have 8 digits, the codes are the last 6 of them
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("Code() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Code() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() error = nil, want an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfcSecret, code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "previous step", secret: rfcSecret, code: codeAt(current - Skew), wantStep: current - Skew, wantOK: true},
+		{name: "next step", secret: rfcSecret, code: codeAt(current + Skew), wantStep: current + Skew, wantOK: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "outside the skew", secret: rfcSecret, code: codeAt(current - Skew - 1)},
+		{name: "wrong code", secret: rfcSecret, code: "000000"},
+		{name: "short code", secret: rfcSecret, code: "05047"},
+		{name: "invalid secret", secret: "not base32!", code: codeAt(current)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate() = (%d, %t), want (%d, %t)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret isn't base32: %v", err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI("skeleton", "user@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("invalid uri: %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/skeleton:user@example.com" {
+		t.Errorf("uri = %s, want otpauth://totp/skeleton:user@example.com", u)
+	}
+
+	query := u.Query()
+	for key, want := range map[string]string{"secret": rfcSecret, "issuer": "skeleton", "digits": "6", "period": "30"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %s, want %s", key, got, want)
+		}
+	}
+}
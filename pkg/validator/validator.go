@@ -1,38 +1,78 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/izzanzahrial/skeleton/pkg/password"
 	"github.com/labstack/echo/v4"
 )
 
+// PasswordChecker is implemented by requests holding a new password, which is checked against the password policy
+type PasswordChecker interface {
+	// NewPassword returns the field name and value of the new password, empty when it isn't changed,
+	// and the user inputs it must not be similar to, e.g. the username and email
+	NewPassword() (field, plainTextPass string, userInputs []string)
+}
+
 type CustomValidator struct {
 	Validator *validator.Validate
+	policy    *password.Policy
 }
 
-func New() (*CustomValidator, error) {
+type Config func(cv *CustomValidator) error
+
+func New(cfgs ...Config) (*CustomValidator, error) {
 	validator := validator.New()
-	return &CustomValidator{Validator: validator}, nil
+	cv := &CustomValidator{Validator: validator}
+
+	for _, cfg := range cfgs {
+		if err := cfg(cv); err != nil {
+			return nil, err
+		}
+	}
+
+	return cv, nil
+}
+
+// WithPasswordPolicy checks the new password of every request implementing PasswordChecker
+func WithPasswordPolicy(policy *password.Policy) Config {
+	return func(cv *CustomValidator) error {
+		cv.policy = policy
+		return nil
+	}
 }
 
 func (cv *CustomValidator) Validate(i any) error {
+	mapError := make(map[string]string)
 	if err := cv.Validator.Struct(i); err != nil {
 		validatorErrors := err.(validator.ValidationErrors)
-		return customError(validatorErrors)
+		for _, err := range validatorErrors {
+			mapError[err.Field()] = errorMessage(err)
+		}
 	}
 
-	return nil
-}
+	if checker, ok := i.(PasswordChecker); ok && cv.policy != nil {
+		field, plainTextPass, userInputs := checker.NewPassword()
+		// a missing password is already reported by the struct tags
+		if _, reported := mapError[field]; !reported && plainTextPass != "" {
+			if err := cv.policy.Check(plainTextPass, userInputs...); err != nil {
+				var violation *password.PolicyViolation
+				if !errors.As(err, &violation) {
+					return echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+				}
+				mapError[field] = violation.Message
+			}
+		}
+	}
 
-func customError(e validator.ValidationErrors) error {
-	mapError := make(map[string]string)
-	for _, err := range e {
-		mapError[err.Field()] = errorMessage(err)
+	if len(mapError) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, mapError)
 	}
 
-	return echo.NewHTTPError(http.StatusBadRequest, mapError)
+	return nil
 }
 
 // Handle error message for specific validation tag
@@ -46,6 +86,8 @@ func errorMessage(err validator.FieldError) string {
 		return "Gender must be male or female"
 	case "role":
 		return "Invalid role"
+	case "ip":
+		return "Invalid IP address"
 	case "gt":
 		return fmt.Sprintf("Param %s should be greater than %v", err.Field(), err.Param())
 	case "required_without":
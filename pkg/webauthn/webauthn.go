@@ -0,0 +1,346 @@
+// Package webauthn is the relying party side of the WebAuthn registration and assertion
+// ceremonies (https://www.w3.org/TR/webauthn-2/), enough for passkey login.
+// The responses are verified by github.com/go-webauthn/webauthn, this package keeps the JSON
+// the handlers bind and the options for the browser.
+// Attestation statements aren't trusted, the creation options ask for "none" since the
+// authenticator model isn't trusted anyway
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	challengeSize = 32
+	// DefaultTimeout is how long the browser waits for the user to use the authenticator
+	DefaultTimeout = 5 * time.Minute
+
+	credentialType = "public-key"
+)
+
+var (
+	ErrInvalidResponse  = errors.New("invalid webauthn response")
+	ErrOriginNotAllowed = errors.New("webauthn origin not allowed")
+	ErrInvalidSignature = errors.New("invalid webauthn signature")
+	// ErrSignCountRollback means the signature counter went backwards, the authenticator may have been cloned
+	ErrSignCountRollback = errors.New("webauthn signature counter went backwards")
+)
+
+// URLEncodedBase64 is marshaled as unpadded base64url, the encoding browsers use in PublicKeyCredential.toJSON
+type URLEncodedBase64 []byte
+
+func (b URLEncodedBase64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBase64) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+
+	return nil
+}
+
+// RelyingParty verifies the ceremonies for one rp id, e.g. example.com, and the origins allowed to use it
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+	Timeout time.Duration
+}
+
+func New(id, name string, origins []string) (*RelyingParty, error) {
+	if id == "" || len(origins) == 0 {
+		return nil, errors.New("webauthn relying party needs an id and at least one origin")
+	}
+
+	return &RelyingParty{ID: id, Name: name, Origins: origins, Timeout: DefaultTimeout}, nil
+}
+
+// NewChallenge returns a random challenge, it must be remembered by the server until the ceremony ends
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	return challenge, nil
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity is stored by the authenticator, ID is returned as the user handle when logging in
+type UserEntity struct {
+	ID          URLEncodedBase64 `json:"id"`
+	Name        string           `json:"name"`
+	DisplayName string           `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string           `json:"type"`
+	ID   URLEncodedBase64 `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions,
+// browsers accept it with PublicKeyCredential.parseCreationOptionsFromJSON
+type CreationOptions struct {
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              URLEncodedBase64       `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions,
+// browsers accept it with PublicKeyCredential.parseRequestOptionsFromJSON
+type RequestOptions struct {
+	Challenge        URLEncodedBase64       `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the JSON form of the PublicKeyCredential returned by navigator.credentials.create
+type RegistrationResponse struct {
+	ID       string           `json:"id"`
+	RawID    URLEncodedBase64 `json:"rawId"`
+	Type     string           `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBase64 `json:"clientDataJSON"`
+		AttestationObject URLEncodedBase64 `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of the PublicKeyCredential returned by navigator.credentials.get
+type AssertionResponse struct {
+	ID       string           `json:"id"`
+	RawID    URLEncodedBase64 `json:"rawId"`
+	Type     string           `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBase64 `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBase64 `json:"authenticatorData"`
+		Signature         URLEncodedBase64 `json:"signature"`
+		UserHandle        URLEncodedBase64 `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is what has to be stored after a registration to verify the assertions of the authenticator
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE_Key of the credential
+	PublicKey []byte
+	SignCount uint32
+}
+
+// clientData is what the browser signed, the library doesn't read crossOrigin and topOrigin
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+	TopOrigin   string `json:"topOrigin,omitempty"`
+}
+
+// CreationOptions asks for a discoverable credential with user verification, so it can log in without a password,
+// exclude holds the credentials the user already has so the same authenticator isn't registered twice
+func (rp *RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude [][]byte) CreationOptions {
+	return CreationOptions{
+		RP:        RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:      user,
+		Challenge: challenge,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: credentialType, Alg: int64(webauthncose.AlgES256)},
+			{Type: credentialType, Alg: int64(webauthncose.AlgEdDSA)},
+			{Type: credentialType, Alg: int64(webauthncose.AlgRS256)},
+		},
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions lets the user pick any of their passkeys when allow is empty
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow [][]byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: "required",
+	}
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	credentials := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		credentials = append(credentials, CredentialDescriptor{Type: credentialType, ID: id})
+	}
+
+	return credentials
+}
+
+// Challenge returns the challenge the client data was signed for, so the server can look up the ceremony,
+// it's only trusted once the response is verified with it
+func Challenge(clientDataJSON []byte) ([]byte, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+
+	return challenge, nil
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create against the challenge it was given
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, resp RegistrationResponse) (Credential, error) {
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON); err != nil {
+		return Credential{}, err
+	}
+
+	ccr := protocol.CredentialCreationResponse{
+		PublicKeyCredential: publicKeyCredential(resp.ID, resp.Type, resp.RawID),
+		AttestationResponse: protocol.AuthenticatorAttestationResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: protocol.URLEncodedBase64(resp.Response.ClientDataJSON)},
+			AttestationObject:     protocol.URLEncodedBase64(resp.Response.AttestationObject),
+		},
+	}
+
+	parsed, err := ccr.Parse()
+	if err != nil {
+		return Credential{}, invalidResponse(err)
+	}
+
+	encodedChallenge := base64.RawURLEncoding.EncodeToString(challenge)
+	if err := parsed.Verify(encodedChallenge, true, rp.ID, rp.Origins); err != nil {
+		return Credential{}, invalidResponse(err)
+	}
+
+	authData := parsed.Response.AttestationObject.AuthData
+	if !bytes.Equal(authData.AttData.CredentialID, resp.RawID) {
+		return Credential{}, ErrInvalidResponse
+	}
+
+	if _, err := webauthncose.ParsePublicKey(authData.AttData.CredentialPublicKey); err != nil {
+		return Credential{}, invalidResponse(err)
+	}
+
+	return Credential{ID: authData.AttData.CredentialID, PublicKey: authData.AttData.CredentialPublicKey, SignCount: authData.Counter}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get against the challenge it was given
+// and the stored credential, it returns the new signature counter to store
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, resp AssertionResponse, credential Credential) (uint32, error) {
+	if !bytes.Equal(resp.RawID, credential.ID) {
+		return 0, ErrInvalidResponse
+	}
+
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON); err != nil {
+		return 0, err
+	}
+
+	car := protocol.CredentialAssertionResponse{
+		PublicKeyCredential: publicKeyCredential(resp.ID, resp.Type, resp.RawID),
+		AssertionResponse: protocol.AuthenticatorAssertionResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: protocol.URLEncodedBase64(resp.Response.ClientDataJSON)},
+			AuthenticatorData:     protocol.URLEncodedBase64(resp.Response.AuthenticatorData),
+			Signature:             protocol.URLEncodedBase64(resp.Response.Signature),
+			UserHandle:            protocol.URLEncodedBase64(resp.Response.UserHandle),
+		},
+	}
+
+	parsed, err := car.Parse()
+	if err != nil {
+		return 0, invalidResponse(err)
+	}
+
+	encodedChallenge := base64.RawURLEncoding.EncodeToString(challenge)
+	if err := parsed.Verify(encodedChallenge, rp.ID, rp.Origins, "", true, credential.PublicKey); err != nil {
+		return 0, invalidResponse(err)
+	}
+
+	// authenticators that don't implement the counter always send 0
+	signCount := parsed.Response.AuthenticatorData.Counter
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return 0, ErrSignCountRollback
+	}
+
+	return signCount, nil
+}
+
+func publicKeyCredential(id, credentialType string, rawID []byte) protocol.PublicKeyCredential {
+	return protocol.PublicKeyCredential{
+		Credential: protocol.Credential{ID: id, Type: credentialType},
+		RawID:      protocol.URLEncodedBase64(rawID),
+	}
+}
+
+// verifyClientData rejects the ceremonies made in a cross-origin iframe, the library only checks the origin
+// of the iframe and not the page embedding it
+func (rp *RelyingParty) verifyClientData(clientDataJSON []byte) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return ErrInvalidResponse
+	}
+
+	if data.CrossOrigin || (data.TopOrigin != "" && !slices.Contains(rp.Origins, data.TopOrigin)) {
+		return ErrOriginNotAllowed
+	}
+
+	return nil
+}
+
+// invalidResponse keeps the details of the library error, they are only logged
+func invalidResponse(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) {
+		if protocolErr.Type == protocol.ErrAssertionSignature.Type {
+			return fmt.Errorf("%w: %s", ErrInvalidSignature, protocolErr.Details)
+		}
+		return fmt.Errorf("%w: %s %s", ErrInvalidResponse, protocolErr.Details, protocolErr.DevInfo)
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidResponse, err.Error())
+}
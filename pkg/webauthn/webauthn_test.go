@@ -0,0 +1,441 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent   = 0x01
+	flagUserVerified  = 0x04
+	flagAttestedData  = 0x40
+	flagExtensionData = 0x80
+)
+
+// COSE key parameters, RFC 9053
+const (
+	coseKty = 1
+	coseAlg = 3
+	// the meaning of the negative labels depends on the key type
+	coseCrvOrN = -1
+	coseXOrE   = -2
+	coseY      = -3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+func encodeCBOR(t *testing.T, v any) []byte {
+	t.Helper()
+
+	data, err := webauthncbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// softAuthenticator is a software authenticator holding one credential
+type softAuthenticator struct {
+	credentialID []byte
+	coseKey      []byte
+	sign         func(data []byte) []byte
+}
+
+func newSoftAuthenticator(t *testing.T, alg webauthncose.COSEAlgorithmIdentifier) *softAuthenticator {
+	t.Helper()
+
+	a := &softAuthenticator{credentialID: []byte("credential-" + big.NewInt(int64(alg)).String())}
+
+	switch alg {
+	case webauthncose.AlgES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.coseKey = encodeCBOR(t, map[int]any{
+			coseKty: webauthncose.EllipticKey, coseAlg: alg, coseCrvOrN: crvP256,
+			coseXOrE: key.X.FillBytes(make([]byte, 32)), coseY: key.Y.FillBytes(make([]byte, 32)),
+		})
+		a.sign = func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signature
+		}
+	case webauthncose.AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.coseKey = encodeCBOR(t, map[int]any{coseKty: webauthncose.OctetKey, coseAlg: alg, coseCrvOrN: crvEd25519, coseXOrE: []byte(public)})
+		a.sign = func(data []byte) []byte {
+			return ed25519.Sign(private, data)
+		}
+	case webauthncose.AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.coseKey = encodeCBOR(t, map[int]any{
+			coseKty: webauthncose.RSAKey, coseAlg: alg,
+			coseCrvOrN: key.N.Bytes(), coseXOrE: big.NewInt(int64(key.E)).Bytes(),
+		})
+		a.sign = func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signature
+		}
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+
+	return a
+}
+
+// ceremony is what the browser and the authenticator put in a response, the zero value is a valid one
+type ceremony struct {
+	rpID        string
+	origin      string
+	crossOrigin bool
+	topOrigin   string
+	ceremony    string
+	flags       byte
+	signCount   uint32
+	// authDataSuffix is appended to the authenticator data
+	authDataSuffix []byte
+}
+
+func (c ceremony) withDefaults(ceremonyType string) ceremony {
+	if c.rpID == "" {
+		c.rpID = testRPID
+	}
+	if c.origin == "" {
+		c.origin = testOrigin
+	}
+	if c.ceremony == "" {
+		c.ceremony = ceremonyType
+	}
+	if c.flags == 0 {
+		c.flags = flagUserPresent | flagUserVerified
+	}
+	return c
+}
+
+func clientDataJSON(t *testing.T, challenge []byte, c ceremony) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(clientData{
+		Type:        c.ceremony,
+		Challenge:   base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:      c.origin,
+		CrossOrigin: c.crossOrigin,
+		TopOrigin:   c.topOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) authData(c ceremony, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	flags := c.flags
+	if attested {
+		flags |= flagAttestedData
+	}
+
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, c.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey...)
+	}
+	return append(data, c.authDataSuffix...)
+}
+
+func (a *softAuthenticator) register(t *testing.T, challenge []byte, c ceremony) RegistrationResponse {
+	t.Helper()
+	c = c.withDefaults(string(protocol.CreateCeremony))
+
+	var resp RegistrationResponse
+	resp.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	resp.RawID = a.credentialID
+	resp.Type = credentialType
+	resp.Response.ClientDataJSON = clientDataJSON(t, challenge, c)
+	resp.Response.AttestationObject = encodeCBOR(t, map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(c, true),
+	})
+	return resp
+}
+
+func (a *softAuthenticator) assert(t *testing.T, challenge []byte, c ceremony) AssertionResponse {
+	t.Helper()
+	c = c.withDefaults(string(protocol.AssertCeremony))
+
+	var resp AssertionResponse
+	resp.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	resp.RawID = a.credentialID
+	resp.Type = credentialType
+	resp.Response.ClientDataJSON = clientDataJSON(t, challenge, c)
+	resp.Response.AuthenticatorData = a.authData(c, false)
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	resp.Response.Signature = a.sign(append(slices.Clone(resp.Response.AuthenticatorData), clientDataHash[:]...))
+	return resp
+}
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	t.Helper()
+
+	rp, err := New(testRPID, "Example", []string{testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+var testAlgorithms = map[string]webauthncose.COSEAlgorithmIdentifier{
+	"ES256": webauthncose.AlgES256,
+	"EdDSA": webauthncose.AlgEdDSA,
+	"RS256": webauthncose.AlgRS256,
+}
+
+// sortedAlgorithms keeps the order of the subtests stable
+func sortedAlgorithms() []string {
+	names := make([]string, 0, len(testAlgorithms))
+	for name := range testAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	challenge := []byte("registration-challenge")
+
+	tests := []struct {
+		name     string
+		ceremony ceremony
+		modify   func(resp *RegistrationResponse)
+		wantErr  error
+	}{
+		{name: "valid"},
+		{name: "wrong rp id", ceremony: ceremony{rpID: "evil.com"}, wantErr: ErrInvalidResponse},
+		{name: "wrong origin", ceremony: ceremony{origin: "https://evil.com"}, wantErr: ErrInvalidResponse},
+		{name: "cross origin", ceremony: ceremony{crossOrigin: true, topOrigin: testOrigin}, wantErr: ErrOriginNotAllowed},
+		{name: "wrong top origin", ceremony: ceremony{topOrigin: "https://evil.com"}, wantErr: ErrOriginNotAllowed},
+		{name: "same top origin", ceremony: ceremony{topOrigin: testOrigin}},
+		{name: "wrong ceremony", ceremony: ceremony{ceremony: string(protocol.AssertCeremony)}, wantErr: ErrInvalidResponse},
+		{name: "user not verified", ceremony: ceremony{flags: flagUserPresent}, wantErr: ErrInvalidResponse},
+		{name: "user not present", ceremony: ceremony{flags: flagUserVerified}, wantErr: ErrInvalidResponse},
+		{name: "trailing bytes after the authenticator data", ceremony: ceremony{authDataSuffix: []byte{0x00}}, wantErr: ErrInvalidResponse},
+		{
+			name: "challenge mismatch",
+			modify: func(resp *RegistrationResponse) {
+				c := ceremony{}.withDefaults(string(protocol.CreateCeremony))
+				resp.Response.ClientDataJSON = clientDataJSON(t, []byte("other"), c)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "malformed attestation object",
+			modify: func(resp *RegistrationResponse) {
+				resp.Response.AttestationObject = resp.Response.AttestationObject[:10]
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "raw id mismatch",
+			modify:  func(resp *RegistrationResponse) { resp.RawID = []byte("another-credential") },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "wrong type",
+			modify:  func(resp *RegistrationResponse) { resp.Type = "password" },
+			wantErr: ErrInvalidResponse,
+		},
+	}
+
+	for _, name := range sortedAlgorithms() {
+		authenticator := newSoftAuthenticator(t, testAlgorithms[name])
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				resp := authenticator.register(t, challenge, tt.ceremony)
+				if tt.modify != nil {
+					tt.modify(&resp)
+				}
+
+				credential, err := rp.VerifyRegistration(challenge, resp)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyRegistration() error = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+
+				if !slices.Equal(credential.ID, authenticator.credentialID) || !slices.Equal(credential.PublicKey, authenticator.coseKey) {
+					t.Errorf("VerifyRegistration() = %+v, want the credential of the authenticator", credential)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	challenge := []byte("assertion-challenge")
+
+	tests := []struct {
+		name          string
+		ceremony      ceremony
+		storedCount   uint32
+		modify        func(resp *AssertionResponse)
+		wantSignCount uint32
+		wantErr       error
+	}{
+		{name: "valid", ceremony: ceremony{signCount: 6}, storedCount: 5, wantSignCount: 6},
+		{name: "counter not implemented", ceremony: ceremony{signCount: 0}, storedCount: 0, wantSignCount: 0},
+		{name: "counter rollback", ceremony: ceremony{signCount: 4}, storedCount: 5, wantErr: ErrSignCountRollback},
+		{name: "counter replayed", ceremony: ceremony{signCount: 5}, storedCount: 5, wantErr: ErrSignCountRollback},
+		{name: "counter reset to zero", ceremony: ceremony{signCount: 0}, storedCount: 5, wantErr: ErrSignCountRollback},
+		{name: "wrong rp id", ceremony: ceremony{rpID: "evil.com", signCount: 1}, wantErr: ErrInvalidResponse},
+		{name: "wrong origin", ceremony: ceremony{origin: "https://evil.com", signCount: 1}, wantErr: ErrInvalidResponse},
+		{name: "cross origin", ceremony: ceremony{crossOrigin: true, topOrigin: testOrigin, signCount: 1}, wantErr: ErrOriginNotAllowed},
+		{name: "cross origin without top origin", ceremony: ceremony{crossOrigin: true, signCount: 1}, wantErr: ErrOriginNotAllowed},
+		{name: "wrong top origin", ceremony: ceremony{topOrigin: "https://evil.com", signCount: 1}, wantErr: ErrOriginNotAllowed},
+		{name: "wrong ceremony", ceremony: ceremony{ceremony: string(protocol.CreateCeremony), signCount: 1}, wantErr: ErrInvalidResponse},
+		{name: "user not verified", ceremony: ceremony{flags: flagUserPresent, signCount: 1}, wantErr: ErrInvalidResponse},
+		{name: "trailing bytes after the authenticator data", ceremony: ceremony{signCount: 1, authDataSuffix: []byte{0x00}}, wantErr: ErrInvalidResponse},
+		{
+			name:          "extensions",
+			ceremony:      ceremony{flags: flagUserPresent | flagUserVerified | flagExtensionData, signCount: 1, authDataSuffix: encodeCBOR(t, map[string]bool{"credProps": true})},
+			wantSignCount: 1,
+		},
+		{
+			name:     "short authenticator data",
+			ceremony: ceremony{signCount: 1},
+			modify: func(resp *AssertionResponse) {
+				resp.Response.AuthenticatorData = resp.Response.AuthenticatorData[:36]
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:     "challenge mismatch",
+			ceremony: ceremony{signCount: 1},
+			modify: func(resp *AssertionResponse) {
+				c := ceremony{}.withDefaults(string(protocol.AssertCeremony))
+				resp.Response.ClientDataJSON = clientDataJSON(t, []byte("other"), c)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:     "signature of other client data",
+			ceremony: ceremony{signCount: 1},
+			modify: func(resp *AssertionResponse) {
+				resp.Response.ClientDataJSON = append(resp.Response.ClientDataJSON[:len(resp.Response.ClientDataJSON)-1], ` }`...)
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:     "tampered signature",
+			ceremony: ceremony{signCount: 1},
+			modify: func(resp *AssertionResponse) {
+				resp.Response.Signature = slices.Clone(resp.Response.Signature)
+				resp.Response.Signature[len(resp.Response.Signature)-1] ^= 0xff
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:     "other credential",
+			ceremony: ceremony{signCount: 1},
+			modify:   func(resp *AssertionResponse) { resp.RawID = []byte("another-credential") },
+			wantErr:  ErrInvalidResponse,
+		},
+	}
+
+	for _, name := range sortedAlgorithms() {
+		authenticator := newSoftAuthenticator(t, testAlgorithms[name])
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				resp := authenticator.assert(t, challenge, tt.ceremony)
+				if tt.modify != nil {
+					tt.modify(&resp)
+				}
+
+				credential := Credential{ID: authenticator.credentialID, PublicKey: authenticator.coseKey, SignCount: tt.storedCount}
+				signCount, err := rp.VerifyAssertion(challenge, resp, credential)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyAssertion() error = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && signCount != tt.wantSignCount {
+					t.Errorf("VerifyAssertion() = %d, want %d", signCount, tt.wantSignCount)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyAssertionWithOtherKey(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	challenge := []byte("assertion-challenge")
+
+	authenticator := newSoftAuthenticator(t, webauthncose.AlgES256)
+	other := newSoftAuthenticator(t, webauthncose.AlgES256)
+
+	resp := authenticator.assert(t, challenge, ceremony{signCount: 1})
+	credential := Credential{ID: authenticator.credentialID, PublicKey: other.coseKey}
+	if _, err := rp.VerifyAssertion(challenge, resp, credential); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyAssertion() error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	challenge := []byte("some-challenge")
+
+	got, err := Challenge(clientDataJSON(t, challenge, ceremony{}.withDefaults(string(protocol.AssertCeremony))))
+	if err != nil || !slices.Equal(got, challenge) {
+		t.Errorf("Challenge() = (%q, %v), want (%q, nil)", got, err, challenge)
+	}
+
+	for _, data := range []string{`not json`, `{"challenge":"not base64!"}`} {
+		if _, err := Challenge([]byte(data)); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("Challenge(%s) error = %v, want %v", data, err, ErrInvalidResponse)
+		}
+	}
+}